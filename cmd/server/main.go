@@ -8,6 +8,7 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // timezones of posts don't depend on the host's zoneinfo
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -49,6 +50,9 @@ func main() {
 	client := asynq.NewClient(redisConn)
 	defer client.Close()
 
+	inspector := asynq.NewInspector(redisConn)
+	defer inspector.Close()
+
 	app := fiber.New(fiber.Config{
 		ReadTimeout:  10 * time.Minute,
 		WriteTimeout: 10 * time.Minute,
@@ -64,8 +68,9 @@ func main() {
 		AllowOriginsFunc: func(origin string) bool {
 			return true
 		},
-		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
+		AllowMethods:     "GET,HEAD,POST,PUT,PATCH,DELETE,OPTIONS",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, Tus-Resumable, Upload-Length, Upload-Offset, Upload-Metadata",
+		ExposeHeaders:    "Location, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size, Upload-Length, Upload-Offset, Upload-Expires, Media-Asset-Id",
 		AllowCredentials: true,
 		MaxAge:           3600,
 	}))
@@ -76,20 +81,38 @@ func main() {
 	postMediaRepo := repository.NewPostMediaRepository(db)
 	selectedAccountRepo := repository.NewSelectedAccountRepository(db)
 	mediaAssetRepo := repository.NewMediaAssetRepository(db)
+	mediaUploadRepo := repository.NewMediaUploadRepository(db)
+	resumableUploadRepo := repository.NewResumableUploadRepository(db)
+	mediaVariantRepo := repository.NewMediaVariantRepository(db)
+	mediaRenditionRepo := repository.NewMediaRenditionRepository(db)
 	apiKeyRepository := repository.NewApiKeyRepository(db)
 	subscritpionRepo := repository.NewSubscriptionRepository(db)
 	postingHistoryRepo := repository.NewPostingHistoryRepository(db)
+	settingsRepo := repository.NewSettingsRepository(db)
+	platformOverrideRepo := repository.NewPlatformOverrideRepository(db)
 
 	authService := service.NewAuthService(*cfg, userRepo)
 	userService := service.NewUserService(userRepo)
-	r2Service := service.NewR2Service(*cfg)
-	postService := service.NewPostService(db, postRepo, selectedAccountRepo, mediaAssetRepo, socialAccountRepo, postMediaRepo, subscritpionRepo, *r2Service)
+	storage, err := service.NewStorage(*cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up storage: %v", err)
+	}
+	scheduler := queue.NewScheduler(client, inspector)
+	imageVariants := service.NewImageVariants(mediaVariantRepo, storage)
+	videoRenditions := service.NewVideoRenditions(*cfg, mediaAssetRepo, mediaRenditionRepo, storage, scheduler)
+	postService := service.NewPostService(db, userRepo, postRepo, selectedAccountRepo, platformOverrideRepo, mediaAssetRepo, socialAccountRepo, postMediaRepo, subscritpionRepo, settingsRepo, storage, scheduler, scheduler)
 	platformService := service.NewPlatformService(*cfg, socialAccountRepo)
-	instagramService := service.NewInstagramService(*cfg, socialAccountRepo, postRepo, postMediaRepo, mediaAssetRepo)
-	tiktokService := service.NewTiktokService(*cfg, postRepo, socialAccountRepo, postMediaRepo, mediaAssetRepo)
+	instagramService := service.NewInstagramService(*cfg, socialAccountRepo, postRepo, postMediaRepo, mediaAssetRepo, imageVariants, videoRenditions)
+	tiktokService := service.NewTiktokService(*cfg, postRepo, socialAccountRepo, postMediaRepo, mediaAssetRepo, imageVariants, videoRenditions)
 	youtbeService := service.NewYoutubeService(*cfg, postRepo, socialAccountRepo, postMediaRepo, mediaAssetRepo)
 	apiKeyService := service.NewApiKeyService(apiKeyRepository)
 	subscriptionService := service.NewSubscriptionService(*cfg, userRepo, subscritpionRepo)
+	historyService := service.NewHistoryService(postingHistoryRepo, userRepo)
+	settingsService := service.NewSettingsService(settingsRepo, socialAccountRepo)
+	mediaService := service.NewMediaService(mediaAssetRepo, mediaUploadRepo, postRepo, storage, scheduler, imageVariants, videoRenditions)
+	resumableUploadService := service.NewResumableUploadService(db, resumableUploadRepo, mediaAssetRepo, storage, scheduler)
+	thumbnailService := service.NewThumbnailService(*cfg, mediaAssetRepo, storage)
+	mediaGC := service.NewMediaGC(*cfg, mediaAssetRepo, mediaUploadRepo, resumableUploadRepo, storage)
 
 	authMiddleware := middleware.NewAuthMiddleware(*cfg, apiKeyService)
 
@@ -101,6 +124,13 @@ func main() {
 	app.Get("/auth/:platform", platform.AddSocialAccount)
 	app.Get("/auth/:platform/callback", platform.CallbackHandler)
 
+	// The local storage driver serves its files itself
+	if local, ok := storage.(*service.LocalStorage); ok {
+		files := handlers.NewFilesHandler(local)
+		app.Get("/files/:key", files.ServeFile)
+		app.Put("/files/:key", files.UploadFile)
+	}
+
 	payment := handlers.NewPaymentHandler(subscriptionService)
 	app.Post("/payment/webhook", payment.PaymentWebhook)
 
@@ -112,6 +142,7 @@ func main() {
 	user := handlers.NewUserHandler(userService)
 	userRoutes.Get("/info", user.GetUserInfo)
 	userRoutes.Post("/delete", user.DeleteAccount)
+	userRoutes.Put("/timezone", user.UpdateTimezone)
 
 	apiKeysRoutes := app.Group("/api_key")
 	apiKeysRoutes.Use(authMiddleware.AuthMiddleware())
@@ -122,10 +153,46 @@ func main() {
 
 	postsRoutes := app.Group("/posts")
 	postsRoutes.Use(authMiddleware.AuthMiddleware())
-	post := handlers.NewPostHandler(postService, client)
+	post := handlers.NewPostHandler(postService)
 	postsRoutes.Get("/", post.ListPosts)
+	postsRoutes.Get("/drafts", post.ListDrafts)
 	postsRoutes.Post("/create", post.CreatePost)
+	postsRoutes.Post("/preview", post.PreviewPost)
 	postsRoutes.Post("/remove", post.RemovePost)
+	postsRoutes.Put("/:id", post.UpdatePost)
+	postsRoutes.Post("/:id/retry", post.RetryPost)
+	postsRoutes.Post("/:id/schedule", post.ScheduleDraft)
+	postsRoutes.Post("/:id/skip", post.SkipPost)
+
+	historyRoutes := app.Group("/history")
+	historyRoutes.Use(authMiddleware.AuthMiddleware())
+	history := handlers.NewHistoryHandler(historyService)
+	historyRoutes.Get("/", history.ListHistory)
+
+	slotsRoutes := app.Group("/slots")
+	slotsRoutes.Use(authMiddleware.AuthMiddleware())
+	slots := handlers.NewSettingsHandler(settingsService)
+	slotsRoutes.Get("/", slots.ListSlots)
+	slotsRoutes.Post("/new", slots.CreateSlot)
+	slotsRoutes.Post("/remove", slots.RemoveSlot)
+
+	mediaRoutes := app.Group("/media")
+	mediaRoutes.Use(authMiddleware.AuthMiddleware())
+	media := handlers.NewMediaHandler(mediaService)
+	mediaRoutes.Get("/", media.ListMedia)
+	mediaRoutes.Post("/upload", media.UploadMedia)
+	mediaRoutes.Post("/presign", media.PresignUpload)
+	mediaRoutes.Post("/complete", media.CompleteUpload)
+	mediaRoutes.Post("/remove", media.RemoveMedia)
+	mediaRoutes.Post("/cover", media.SetCover)
+	mediaRoutes.Post("/transcode", media.RetryTranscode)
+
+	tus := handlers.NewTusHandler(resumableUploadService)
+	mediaRoutes.Options("/tus", tus.Options)
+	mediaRoutes.Post("/tus", tus.CreateUpload)
+	mediaRoutes.Head("/tus/:id", tus.UploadStatus)
+	mediaRoutes.Patch("/tus/:id", tus.UploadChunk)
+	mediaRoutes.Delete("/tus/:id", tus.TerminateUpload)
 
 	// social accounts api routes
 	accountsRoutes := app.Group("/accounts")
@@ -135,21 +202,26 @@ func main() {
 
 	// cron jobs
 	refreshTokenJob := job.NewtokenRefreshJob(socialAccountRepo, youtbeService, tiktokService, instagramService)
+	mediaGCJob := job.NewMediaGCJob(*cfg, mediaGC)
 
 	//queue
-	queueW := queue.NewQueue(postRepo, postingHistoryRepo, selectedAccountRepo, mediaAssetRepo, socialAccountRepo, postMediaRepo, youtbeService, tiktokService, instagramService)
+	queueW := queue.NewQueue(postRepo, postingHistoryRepo, selectedAccountRepo, platformOverrideRepo, mediaAssetRepo, socialAccountRepo, postMediaRepo, postService, youtbeService, tiktokService, instagramService, thumbnailService, videoRenditions)
 
 	c := cron.New()
 	c.AddFunc("@every 00h10m00s", refreshTokenJob.RefreshTokens)
+	c.AddFunc("@daily", mediaGCJob.CollectMedia)
 	c.Start()
 
 	go func() {
 		server := asynq.NewServer(redisConn, asynq.Config{
-			Concurrency: 10,
+			Concurrency:    10,
+			RetryDelayFunc: queue.RetryDelay,
 		})
 
 		mux := asynq.NewServeMux()
 		mux.HandleFunc(queue.TaskTypeSchedulePost, queueW.HandleSchedulePostTask)
+		mux.HandleFunc(queue.TaskTypeGenerateThumbnail, queueW.HandleThumbnailTask)
+		mux.HandleFunc(queue.TaskTypeTranscodeVideo, queueW.HandleTranscodeTask)
 
 		log.Println("Starting the Asynq server...")
 		if err := server.Run(mux); err != nil {
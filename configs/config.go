@@ -1,12 +1,29 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"time"
+)
 
 type R2 struct {
 	AccountID  string
 	AccessKey  string
 	SecretKey  string
 	BucketName string
+	Endpoint   string // other S3-compatible services, R2 of AccountID when empty
+}
+
+type Storage struct {
+	Driver    string // r2 or local
+	PublicURL string // files are served from PublicURL/key
+	LocalPath string // directory of the local driver
+}
+
+// MediaGC sets the removal of media that nothing uses anymore.
+type MediaGC struct {
+	GracePeriod time.Duration // how long unused media are kept
+	DryRun      bool          // only report what would be removed
 }
 
 type Config struct {
@@ -26,11 +43,20 @@ type Config struct {
 	RedisPassword          string
 	FrontendURL            string
 	R2                     R2
+	Storage                Storage
+	FFmpegPath             string // makes the poster frames of videos
+	MediaGC                MediaGC
 	SecretKey              string
 	CookieName             string
 }
 
 func LoadConfig() *Config {
+	storageDriver := getEnv("STORAGE_DRIVER", "r2")
+	defaultPublicURL := "https://pub-f8f43aa198a449518df6744ec9ce452c.r2.dev"
+	if storageDriver == "local" {
+		defaultPublicURL = "http://localhost:3000/files"
+	}
+
 	return &Config{
 		InstagramClientID:      getEnv("INSTAGRAM_CLIENT_ID", ""),
 		InstagramClientSecret:  getEnv("INSTAGRAM_CLIENT_SECRET", ""),
@@ -52,6 +78,17 @@ func LoadConfig() *Config {
 			AccessKey:  getEnv("R2_ACCESS_KEY", ""),
 			SecretKey:  getEnv("R2_SECRET_KEY", ""),
 			BucketName: getEnv("R2_BUCKET_NAME", ""),
+			Endpoint:   getEnv("R2_ENDPOINT", ""),
+		},
+		Storage: Storage{
+			Driver:    storageDriver,
+			PublicURL: getEnv("STORAGE_PUBLIC_URL", defaultPublicURL),
+			LocalPath: getEnv("STORAGE_LOCAL_PATH", "./uploads"),
+		},
+		FFmpegPath: getEnv("FFMPEG_PATH", "ffmpeg"),
+		MediaGC: MediaGC{
+			GracePeriod: getDuration("MEDIA_GC_GRACE_PERIOD", 7*24*time.Hour),
+			DryRun:      getBool("MEDIA_GC_DRY_RUN", false),
 		},
 		SecretKey:  getEnv("SECRET_KEY", ""),
 		CookieName: getEnv("COOKIE_NAME", ""),
@@ -64,3 +101,17 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getBool(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
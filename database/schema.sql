@@ -9,8 +9,13 @@ CREATE TYPE public.platform AS ENUM (
 -- Sequences
 CREATE SEQUENCE public.api_keys_id_seq START 1;
 CREATE SEQUENCE public.media_assets_id_seq START 1;
+CREATE SEQUENCE public.media_renditions_id_seq START 1;
+CREATE SEQUENCE public.media_uploads_id_seq START 1;
+CREATE SEQUENCE public.media_variants_id_seq START 1;
 CREATE SEQUENCE public.posting_history_id_seq START 1;
 CREATE SEQUENCE public.posts_id_seq START 1;
+CREATE SEQUENCE public.resumable_uploads_id_seq START 1;
+CREATE SEQUENCE public.settings_id_seq START 1;
 CREATE SEQUENCE public.social_accounts_id_seq START 1;
 CREATE SEQUENCE public.subscriptions_id_seq START 1;
 CREATE SEQUENCE public.users_id_seq START 1;
@@ -30,12 +35,67 @@ CREATE TABLE public.media_assets (
     user_id integer,
     file_name varchar(255) NOT NULL,
     file_type varchar(50) NOT NULL,
-    file_size integer NOT NULL,
+    file_size bigint NOT NULL,
     file_url text NOT NULL,
     thumbnail_url text,
+    width integer,
+    height integer,
     duration integer,
+    frame_rate real,
+    codec varchar(20),
+    cover_time_ms integer,
+    content_hash char(64),
+    library boolean NOT NULL DEFAULT false,
+    released_at timestamp,
     created_at timestamp DEFAULT CURRENT_TIMESTAMP,
-    CONSTRAINT media_assets_pkey PRIMARY KEY (id)
+    CONSTRAINT media_assets_pkey PRIMARY KEY (id),
+    CONSTRAINT media_assets_user_id_content_hash_key UNIQUE (user_id, content_hash)
+);
+
+CREATE TABLE public.media_renditions (
+    id integer NOT NULL DEFAULT nextval('public.media_renditions_id_seq'::regclass),
+    asset_id integer NOT NULL,
+    platform public.platform NOT NULL,
+    status varchar(20) NOT NULL DEFAULT 'pending',
+    progress integer NOT NULL DEFAULT 0,
+    file_key varchar(150),
+    file_url text,
+    file_size bigint,
+    width integer,
+    height integer,
+    duration integer,
+    error_message text,
+    created_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT media_renditions_pkey PRIMARY KEY (id),
+    CONSTRAINT media_renditions_asset_id_platform_key UNIQUE (asset_id, platform)
+);
+
+CREATE TABLE public.media_uploads (
+    id integer NOT NULL DEFAULT nextval('public.media_uploads_id_seq'::regclass),
+    user_id integer NOT NULL,
+    file_key varchar(100) NOT NULL,
+    file_name varchar(255),
+    file_type varchar(50) NOT NULL,
+    file_size bigint NOT NULL,
+    expires_at timestamp NOT NULL,
+    created_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT media_uploads_pkey PRIMARY KEY (id),
+    CONSTRAINT media_uploads_file_key_key UNIQUE (file_key)
+);
+
+CREATE TABLE public.media_variants (
+    id integer NOT NULL DEFAULT nextval('public.media_variants_id_seq'::regclass),
+    asset_id integer NOT NULL,
+    name varchar(50) NOT NULL,
+    file_key varchar(150) NOT NULL,
+    file_url text NOT NULL,
+    file_size bigint NOT NULL,
+    width integer NOT NULL,
+    height integer NOT NULL,
+    created_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT media_variants_pkey PRIMARY KEY (id),
+    CONSTRAINT media_variants_asset_id_name_key UNIQUE (asset_id, name)
 );
 
 CREATE TABLE public.post_media (
@@ -51,6 +111,7 @@ CREATE TABLE public.posting_history (
     user_id integer NOT NULL,
     post_id integer NOT NULL,
     account_id integer NOT NULL,
+    attempt integer DEFAULT 1,
     error_message text,
     created_at timestamp DEFAULT CURRENT_TIMESTAMP,
     CONSTRAINT posting_history_pkey PRIMARY KEY (id)
@@ -61,22 +122,84 @@ CREATE TABLE public.posts (
     user_id integer NOT NULL,
     post_type varchar(50) NOT NULL,
     caption text,
-    scheduled_time timestamp NOT NULL,
+    scheduled_time timestamp,
     status varchar(20) DEFAULT 'scheduled',
     created_at timestamp DEFAULT CURRENT_TIMESTAMP,
     updated_at timestamp DEFAULT CURRENT_TIMESTAMP,
     title text,
-    CONSTRAINT posts_pkey PRIMARY KEY (id)
+    task_id varchar(100),
+    recurrence text,
+    series_id integer,
+    series_start timestamp,
+    occurrence integer DEFAULT 1,
+    queued boolean DEFAULT false,
+    category varchar(50),
+    timezone varchar(64),
+    CONSTRAINT posts_pkey PRIMARY KEY (id),
+    CONSTRAINT posts_series_id_occurrence_key UNIQUE (series_id, occurrence)
+);
+
+CREATE TABLE public.resumable_uploads (
+    id integer NOT NULL DEFAULT nextval('public.resumable_uploads_id_seq'::regclass),
+    user_id integer NOT NULL,
+    file_key varchar(100) NOT NULL,
+    file_name varchar(255),
+    file_type varchar(50),
+    file_size bigint NOT NULL,
+    upload_offset bigint NOT NULL DEFAULT 0,
+    multipart_id text NOT NULL,
+    parts text[] NOT NULL DEFAULT '{}',
+    hash_state bytea,
+    asset_id integer,
+    expires_at timestamp NOT NULL,
+    created_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT resumable_uploads_pkey PRIMARY KEY (id),
+    CONSTRAINT resumable_uploads_file_key_key UNIQUE (file_key)
 );
 
 CREATE TABLE public.selected_accounts (
     post_id integer NOT NULL,
     account_id integer NOT NULL,
+    status varchar(20) DEFAULT 'pending',
+    remote_media_id varchar(100),
+    permalink text,
+    error_message text,
+    attempts integer DEFAULT 0,
+    published_at timestamp,
+    caption_override text,
+    title_override text,
+    hashtags text[],
+    options jsonb,
     created_at timestamp DEFAULT CURRENT_TIMESTAMP,
     updated_at timestamp DEFAULT CURRENT_TIMESTAMP,
     CONSTRAINT selected_accounts_pkey PRIMARY KEY (post_id, account_id)
 );
 
+CREATE TABLE public.platform_overrides (
+    post_id integer NOT NULL,
+    platform public.platform NOT NULL,
+    caption text,
+    title text,
+    hashtags text[],
+    options jsonb,
+    created_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT platform_overrides_pkey PRIMARY KEY (post_id, platform)
+);
+
+CREATE TABLE public.settings (
+    id integer NOT NULL DEFAULT nextval('public.settings_id_seq'::regclass),
+    user_id integer NOT NULL,
+    account_id integer,
+    weekday smallint NOT NULL,
+    posting_time time NOT NULL,
+    category varchar(50),
+    created_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT settings_pkey PRIMARY KEY (id),
+    CONSTRAINT settings_weekday_check CHECK (weekday BETWEEN 0 AND 6)
+);
+
 CREATE TABLE public.social_accounts (
     id integer NOT NULL DEFAULT nextval('public.social_accounts_id_seq'::regclass),
     user_id integer,
@@ -113,6 +236,7 @@ CREATE TABLE public.users (
     email varchar(255) NOT NULL,
     name varchar(100) NOT NULL,
     profile_picture varchar(255) NOT NULL,
+    timezone varchar(64) DEFAULT 'UTC',
     created_at timestamp DEFAULT CURRENT_TIMESTAMP,
     updated_at timestamp DEFAULT CURRENT_TIMESTAMP,
     CONSTRAINT users_pkey PRIMARY KEY (id),
@@ -126,10 +250,22 @@ ALTER TABLE public.api_keys
 ALTER TABLE public.media_assets
     ADD CONSTRAINT media_assets_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE;
 
+ALTER TABLE public.media_renditions
+    ADD CONSTRAINT media_renditions_asset_id_fkey FOREIGN KEY (asset_id) REFERENCES public.media_assets(id) ON DELETE CASCADE;
+
+ALTER TABLE public.media_uploads
+    ADD CONSTRAINT media_uploads_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE;
+
+ALTER TABLE public.media_variants
+    ADD CONSTRAINT media_variants_asset_id_fkey FOREIGN KEY (asset_id) REFERENCES public.media_assets(id) ON DELETE CASCADE;
+
 ALTER TABLE public.post_media
     ADD CONSTRAINT post_media_asset_id_fkey FOREIGN KEY (asset_id) REFERENCES public.media_assets(id) ON DELETE CASCADE,
     ADD CONSTRAINT post_media_post_id_fkey FOREIGN KEY (post_id) REFERENCES public.posts(id) ON DELETE CASCADE;
 
+ALTER TABLE public.platform_overrides
+    ADD CONSTRAINT platform_overrides_post_id_fkey FOREIGN KEY (post_id) REFERENCES public.posts(id) ON DELETE CASCADE;
+
 ALTER TABLE public.posting_history
     ADD CONSTRAINT posting_history_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.social_accounts(id) ON DELETE CASCADE,
     ADD CONSTRAINT posting_history_post_id_fkey FOREIGN KEY (post_id) REFERENCES public.posts(id) ON DELETE CASCADE,
@@ -139,10 +275,18 @@ ALTER TABLE public.posts
     ADD CONSTRAINT posts_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE;
 
 
+ALTER TABLE public.resumable_uploads
+    ADD CONSTRAINT resumable_uploads_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE,
+    ADD CONSTRAINT resumable_uploads_asset_id_fkey FOREIGN KEY (asset_id) REFERENCES public.media_assets(id) ON DELETE SET NULL;
+
 ALTER TABLE public.selected_accounts
     ADD CONSTRAINT selected_accounts_post_id_fkey FOREIGN KEY (post_id) REFERENCES public.posts(id) ON DELETE CASCADE,
     ADD CONSTRAINT selected_accounts_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.social_accounts(id) ON DELETE CASCADE;
 
+ALTER TABLE public.settings
+    ADD CONSTRAINT settings_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE,
+    ADD CONSTRAINT settings_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.social_accounts(id) ON DELETE CASCADE;
+
 ALTER TABLE public.social_accounts
     ADD CONSTRAINT social_accounts_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE;
 
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/h2non/filetype"
+	"github.com/maheshrc27/scheduling-api/internal/service"
+)
+
+// FilesHandler serves the files of the local storage driver, the way a
+// bucket would.
+type FilesHandler struct {
+	s *service.LocalStorage
+}
+
+func NewFilesHandler(storage *service.LocalStorage) *FilesHandler {
+	return &FilesHandler{s: storage}
+}
+
+func (h *FilesHandler) ServeFile(c *fiber.Ctx) error {
+	path, err := h.s.Path(c.Params("key"))
+	if err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return c.SendStatus(fiber.StatusNotFound)
+		}
+		return err
+	}
+	head := make([]byte, 262)
+	n, _ := io.ReadFull(file, head)
+	file.Close()
+
+	if err := c.SendFile(path); err != nil {
+		return err
+	}
+	// Keys have no extension to tell the type from
+	if kind, err := filetype.Match(head[:n]); err == nil && kind != filetype.Unknown {
+		c.Set(fiber.HeaderContentType, kind.MIME.Value)
+	}
+	return nil
+}
+
+// UploadFile stores a file sent to a URL given by LocalStorage.PresignPut.
+func (h *FilesHandler) UploadFile(c *fiber.Ctx) error {
+	key := c.Params("key")
+	body := c.Body()
+
+	if !h.s.CheckPresigned(key, c.Get(fiber.HeaderContentType), int64(len(body)), c.Query("expires"), c.Query("signature")) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Upload URL is invalid or expired",
+		})
+	}
+
+	if err := h.s.Put(c.Context(), key, bytes.NewReader(body), int64(len(body)), c.Get(fiber.HeaderContentType)); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Unable to save file",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
@@ -1,12 +1,27 @@
 package handlers
 
 import (
+	"errors"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/maheshrc27/scheduling-api/internal/service"
 )
 
 func GetUserID(c *fiber.Ctx) int64 {
 	userID, _ := strconv.Atoi(c.Locals("user_id").(string))
 	return int64(userID)
 }
+
+// validationErrorResponse answers with the issues of a post that failed
+// validation. It tells whether err was a validation error and it answered.
+func validationErrorResponse(c *fiber.Ctx, err error) (bool, error) {
+	var invalid *service.ValidationError
+	if !errors.As(err, &invalid) {
+		return false, nil
+	}
+	return true, c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+		"error":  err.Error(),
+		"issues": invalid.Issues,
+	})
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/maheshrc27/scheduling-api/internal/service"
+	"github.com/maheshrc27/scheduling-api/internal/transfer"
+)
+
+type HistoryHandler struct {
+	s service.HistoryService
+}
+
+func NewHistoryHandler(service service.HistoryService) *HistoryHandler {
+	return &HistoryHandler{s: service}
+}
+
+func (h *HistoryHandler) ListHistory(c *fiber.Ctx) error {
+	userID := GetUserID(c)
+
+	query := transfer.HistoryQuery{
+		PostID:    int64(c.QueryInt("post_id", 0)),
+		AccountID: int64(c.QueryInt("account_id", 0)),
+		Platform:  c.Query("platform"),
+		Status:    c.Query("status"),
+		From:      c.Query("from"),
+		To:        c.Query("to"),
+		Page:      c.QueryInt("page", 1),
+		PerPage:   c.QueryInt("per_page", 0),
+	}
+
+	history, total, err := h.s.List(c.Context(), userID, &query)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"history":  history,
+		"total":    total,
+		"page":     query.Page,
+		"per_page": query.PerPage,
+	})
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/maheshrc27/scheduling-api/internal/service"
+	"github.com/maheshrc27/scheduling-api/internal/transfer"
+)
+
+type MediaHandler struct {
+	s service.MediaService
+}
+
+func NewMediaHandler(service service.MediaService) *MediaHandler {
+	return &MediaHandler{s: service}
+}
+
+func (h *MediaHandler) UploadMedia(c *fiber.Ctx) error {
+	userID := GetUserID(c)
+	form, err := c.MultipartForm()
+	if err != nil {
+		slog.Error(err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unable to parse form",
+		})
+	}
+
+	files := form.File["files"]
+	if len(files) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "No files selected",
+		})
+	}
+
+	assets, err := h.s.Upload(c.Context(), userID, files)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Media uploaded successfully",
+		"assets":  assets,
+	})
+}
+
+func (h *MediaHandler) ListMedia(c *fiber.Ctx) error {
+	userID := GetUserID(c)
+	assetID := c.QueryInt("id", 0)
+
+	if assetID != 0 {
+		asset, posts, err := h.s.Info(c.Context(), userID, int64(assetID))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"asset": asset,
+			"posts": posts,
+		})
+	}
+
+	query := transfer.MediaQuery{
+		Type:    c.Query("type"),
+		MinSize: int64(c.QueryInt("min_size", 0)),
+		MaxSize: int64(c.QueryInt("max_size", 0)),
+		From:    c.Query("from"),
+		To:      c.Query("to"),
+		Page:    c.QueryInt("page", 1),
+		PerPage: c.QueryInt("per_page", 0),
+	}
+
+	assets, total, err := h.s.List(c.Context(), userID, &query)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"assets":   assets,
+		"total":    total,
+		"page":     query.Page,
+		"per_page": query.PerPage,
+	})
+}
+
+func (h *MediaHandler) RemoveMedia(c *fiber.Ctx) error {
+	userID := GetUserID(c)
+	assetID := c.QueryInt("id", 0)
+
+	err := h.s.Remove(c.Context(), userID, int64(assetID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// PresignUpload returns a URL the client uploads a file to, the file is
+// added to the library by CompleteUpload.
+func (h *MediaHandler) PresignUpload(c *fiber.Ctx) error {
+	userID := GetUserID(c)
+
+	var uploadRequest transfer.UploadRequest
+	if err := c.BodyParser(&uploadRequest); err != nil {
+		slog.Error(err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unable to parse request body",
+		})
+	}
+
+	upload, err := h.s.PresignUpload(c.Context(), userID, &uploadRequest)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(upload)
+}
+
+func (h *MediaHandler) CompleteUpload(c *fiber.Ctx) error {
+	userID := GetUserID(c)
+	uploadID := c.QueryInt("id", 0)
+
+	asset, err := h.s.CompleteUpload(c.Context(), userID, int64(uploadID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Media uploaded successfully",
+		"asset":   asset,
+	})
+}
+
+// SetCover picks the frame of a video used as cover on the platforms and as
+// its thumbnail.
+func (h *MediaHandler) SetCover(c *fiber.Ctx) error {
+	userID := GetUserID(c)
+	assetID := c.QueryInt("id", 0)
+
+	var coverRequest transfer.CoverRequest
+	if err := c.BodyParser(&coverRequest); err != nil {
+		slog.Error(err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unable to parse request body",
+		})
+	}
+
+	asset, err := h.s.SetCover(c.Context(), userID, int64(assetID), coverRequest.TimeMs)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"asset": asset,
+	})
+}
+
+func (h *MediaHandler) RetryTranscode(c *fiber.Ctx) error {
+	userID := GetUserID(c)
+	assetID := c.QueryInt("id", 0)
+
+	asset, err := h.s.RetryTranscode(c.Context(), userID, int64(assetID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"asset": asset,
+	})
+}
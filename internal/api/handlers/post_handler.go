@@ -2,24 +2,26 @@ package handlers
 
 import (
 	"log/slog"
+	"mime/multipart"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/hibiken/asynq"
-	"github.com/maheshrc27/scheduling-api/internal/queue"
 	"github.com/maheshrc27/scheduling-api/internal/service"
 	"github.com/maheshrc27/scheduling-api/internal/transfer"
 )
 
 type PostHandler struct {
-	s           service.PostService
-	AsynqClient *asynq.Client
+	s service.PostService
 }
 
-func NewPostHandler(service service.PostService, asynqClient *asynq.Client) *PostHandler {
-	return &PostHandler{s: service, AsynqClient: asynqClient}
+func NewPostHandler(service service.PostService) *PostHandler {
+	return &PostHandler{s: service}
 }
 
 func (h *PostHandler) CreatePost(c *fiber.Ctx) error {
+	if c.Is("json") {
+		return h.createPostJSON(c)
+	}
+
 	userID := GetUserID(c)
 	form, err := c.MultipartForm()
 	if err != nil {
@@ -29,43 +31,142 @@ func (h *PostHandler) CreatePost(c *fiber.Ctx) error {
 		})
 	}
 
-	caption := c.FormValue("caption")
-	title := c.FormValue("title")
-	scheduledTime := c.FormValue("scheduling_time")
-	selectedAccountsStr := c.FormValue("selected_accounts")
+	pc := postCreationForm(c)
 
 	files := form.File["files"]
-	if len(files) == 0 {
+	if len(files) == 0 && !pc.Draft {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "No files selected",
 		})
 	}
 
-	postID, delay, err := h.s.CreatePost(c.Context(), userID, &transfer.PostCreation{
-		Caption:          caption,
-		Title:            title,
-		ScheduledTime:    scheduledTime,
-		SelectedAccounts: selectedAccountsStr},
-		files)
-
+	postID, err := h.s.CreatePost(c.Context(), userID, pc, files)
 	if err != nil {
+		if ok, err := validationErrorResponse(c, err); ok {
+			return err
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
-	err = queue.EnqueuePost(h.AsynqClient, queue.SchedulePostPayload{PostID: postID}, delay)
+	if pc.Draft {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Draft saved successfully",
+			"post_id": postID,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Post scheduled successfully",
+		"post_id": postID,
+	})
+}
+
+// createPostJSON creates a post from a JSON body referencing media assets
+// or remote files, for clients that don't upload their media with it.
+func (h *PostHandler) createPostJSON(c *fiber.Ctx) error {
+	userID := GetUserID(c)
+
+	var postCreation transfer.PostCreationJSON
+	if err := c.BodyParser(&postCreation); err != nil {
+		slog.Error(err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unable to parse request body",
+		})
+	}
+
+	if len(postCreation.Media) == 0 && !postCreation.Draft {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "No media selected",
+		})
+	}
+
+	postID, err := h.s.CreatePostJSON(c.Context(), userID, &postCreation)
 	if err != nil {
+		if ok, err := validationErrorResponse(c, err); ok {
+			return err
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if postCreation.Draft {
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"error": "Error scheduling post",
+			"message": "Draft saved successfully",
+			"post_id": postID,
 		})
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "Post scheduled successfully",
+		"post_id": postID,
 	})
 }
 
+// PreviewPost takes the same form or JSON body as CreatePost and returns what would be
+// published to each selected account, nothing is saved or uploaded.
+func (h *PostHandler) PreviewPost(c *fiber.Ctx) error {
+	if c.Is("json") {
+		return h.previewPostJSON(c)
+	}
+
+	userID := GetUserID(c)
+	form, err := c.MultipartForm()
+	if err != nil {
+		slog.Error(err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unable to parse form",
+		})
+	}
+
+	preview, err := h.s.Preview(c.Context(), userID, postCreationForm(c), form.File["files"])
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(preview)
+}
+
+func (h *PostHandler) previewPostJSON(c *fiber.Ctx) error {
+	userID := GetUserID(c)
+
+	var postCreation transfer.PostCreationJSON
+	if err := c.BodyParser(&postCreation); err != nil {
+		slog.Error(err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unable to parse request body",
+		})
+	}
+
+	preview, err := h.s.PreviewJSON(c.Context(), userID, &postCreation)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(preview)
+}
+
+func postCreationForm(c *fiber.Ctx) *transfer.PostCreation {
+	return &transfer.PostCreation{
+		Caption:          c.FormValue("caption"),
+		Title:            c.FormValue("title"),
+		ScheduledTime:    c.FormValue("scheduling_time"),
+		SelectedAccounts: c.FormValue("selected_accounts"),
+		Draft:            c.FormValue("draft") == "true",
+		Recurrence:       c.FormValue("recurrence"),
+		Queue:            c.FormValue("queue") == "true",
+		Category:         c.FormValue("category"),
+		Timezone:         c.FormValue("timezone"),
+		Overrides:        c.FormValue("overrides"),
+	}
+}
+
 func (h *PostHandler) ListPosts(c *fiber.Ctx) error {
 	userId := GetUserID(c)
 	postId := c.QueryInt("id", 0)
@@ -92,6 +193,136 @@ func (h *PostHandler) ListPosts(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(posts)
 }
 
+func (h *PostHandler) ListDrafts(c *fiber.Ctx) error {
+	userID := GetUserID(c)
+
+	posts, err := h.s.ListDrafts(c.Context(), userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unable to list drafts",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(posts)
+}
+
+func (h *PostHandler) ScheduleDraft(c *fiber.Ctx) error {
+	userID := GetUserID(c)
+	postID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid post id",
+		})
+	}
+
+	// Files are optional, they are appended to the draft's media
+	var files []*multipart.FileHeader
+	if form, err := c.MultipartForm(); err == nil {
+		files = form.File["files"]
+	}
+
+	err = h.s.ScheduleDraft(c.Context(), userID, int64(postID), c.FormValue("scheduling_time"), c.FormValue("timezone"), files)
+	if err != nil {
+		if ok, err := validationErrorResponse(c, err); ok {
+			return err
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Post scheduled successfully",
+		"post_id": postID,
+	})
+}
+
+func (h *PostHandler) UpdatePost(c *fiber.Ctx) error {
+	userID := GetUserID(c)
+	postID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid post id",
+		})
+	}
+
+	var postUpdate transfer.PostUpdate
+	if err := c.BodyParser(&postUpdate); err != nil {
+		slog.Error(err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unable to parse request body",
+		})
+	}
+
+	err = h.s.Update(c.Context(), userID, int64(postID), &postUpdate)
+	if err != nil {
+		if ok, err := validationErrorResponse(c, err); ok {
+			return err
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Post updated successfully",
+	})
+}
+
+func (h *PostHandler) RetryPost(c *fiber.Ctx) error {
+	userID := GetUserID(c)
+	postID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid post id",
+		})
+	}
+
+	var postRetry transfer.PostRetry
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&postRetry); err != nil {
+			slog.Error(err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Unable to parse request body",
+			})
+		}
+	}
+
+	accountIDs, err := h.s.Retry(c.Context(), userID, int64(postID), postRetry.AccountIDs)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":     "Retry scheduled successfully",
+		"account_ids": accountIDs,
+	})
+}
+
+func (h *PostHandler) SkipPost(c *fiber.Ctx) error {
+	userID := GetUserID(c)
+	postID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid post id",
+		})
+	}
+
+	nextPostID, err := h.s.Skip(c.Context(), userID, int64(postID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":      "Post skipped successfully",
+		"next_post_id": nextPostID,
+	})
+}
+
 func (h *PostHandler) RemovePost(c *fiber.Ctx) error {
 	userID := GetUserID(c)
 	postId := c.QueryInt("id", 0)
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/maheshrc27/scheduling-api/internal/service"
+	"github.com/maheshrc27/scheduling-api/internal/transfer"
+)
+
+type SettingsHandler struct {
+	s service.SettingsService
+}
+
+func NewSettingsHandler(service service.SettingsService) *SettingsHandler {
+	return &SettingsHandler{s: service}
+}
+
+func (h *SettingsHandler) CreateSlot(c *fiber.Ctx) error {
+	userID := GetUserID(c)
+
+	var slotCreation transfer.SlotCreation
+	if err := c.BodyParser(&slotCreation); err != nil {
+		slog.Error(err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unable to parse request body",
+		})
+	}
+
+	slotID, err := h.s.CreateSlot(c.Context(), userID, &slotCreation)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Posting slot created successfully",
+		"slot_id": slotID,
+	})
+}
+
+func (h *SettingsHandler) ListSlots(c *fiber.Ctx) error {
+	userID := GetUserID(c)
+
+	slots, err := h.s.ListSlots(c.Context(), userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unable to list posting slots",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(slots)
+}
+
+func (h *SettingsHandler) RemoveSlot(c *fiber.Ctx) error {
+	userID := GetUserID(c)
+	slotID := c.QueryInt("id", 0)
+
+	err := h.s.RemoveSlot(c.Context(), userID, int64(slotID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unable to remove posting slot",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/maheshrc27/scheduling-api/internal/models"
+	"github.com/maheshrc27/scheduling-api/internal/service"
+)
+
+// tusVersion is the version of the tus resumable upload protocol served,
+// see https://tus.io/protocols/resumable-upload
+const tusVersion = "1.0.0"
+
+const tusContentType = "application/offset+octet-stream"
+
+// TusHandler serves resumable uploads of media files with the tus protocol.
+// Completed uploads become media assets, the asset ID is sent in the
+// Media-Asset-Id header.
+type TusHandler struct {
+	s service.ResumableUploadService
+}
+
+func NewTusHandler(service service.ResumableUploadService) *TusHandler {
+	return &TusHandler{s: service}
+}
+
+// Options describes what the server supports.
+func (h *TusHandler) Options(c *fiber.Ctx) error {
+	c.Set("Tus-Resumable", tusVersion)
+	c.Set("Tus-Version", tusVersion)
+	c.Set("Tus-Extension", "creation,creation-with-upload,expiration,termination")
+	c.Set("Tus-Max-Size", strconv.FormatInt(service.MaxResumableUploadSize, 10))
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// CreateUpload starts an upload of Upload-Length bytes. The file name and
+// type can be given in the filename and filetype metadata.
+func (h *TusHandler) CreateUpload(c *fiber.Ctx) error {
+	if !checkTusVersion(c) {
+		return c.SendStatus(fiber.StatusPreconditionFailed)
+	}
+	userID := GetUserID(c)
+
+	size, err := strconv.ParseInt(c.Get("Upload-Length"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Upload-Length is missing or invalid",
+		})
+	}
+
+	metadata, err := parseUploadMetadata(c.Get("Upload-Metadata"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	upload, err := h.s.Create(c.Context(), userID, metadata["filename"], metadata["filetype"], size)
+	if err != nil {
+		return tusError(c, err)
+	}
+
+	location := fmt.Sprintf("%s%s/%d", c.BaseURL(), strings.TrimSuffix(c.Path(), "/"), upload.ID)
+	// Clients authenticating with an API key can use the location as it is
+	if query := string(c.Request().URI().QueryString()); query != "" {
+		location += "?" + query
+	}
+	c.Set("Location", location)
+
+	// creation-with-upload, the request may carry the first chunk
+	if len(c.Body()) > 0 {
+		if c.Get(fiber.HeaderContentType) != tusContentType {
+			return c.SendStatus(fiber.StatusUnsupportedMediaType)
+		}
+		upload, err = h.s.Append(c.Context(), userID, upload.ID, 0, c.Body())
+		if err != nil {
+			return tusError(c, err)
+		}
+	}
+
+	setUploadHeaders(c, upload)
+	return c.SendStatus(fiber.StatusCreated)
+}
+
+// UploadStatus tells how much of a file was received.
+func (h *TusHandler) UploadStatus(c *fiber.Ctx) error {
+	if !checkTusVersion(c) {
+		return c.SendStatus(fiber.StatusPreconditionFailed)
+	}
+	userID := GetUserID(c)
+
+	uploadID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	upload, err := h.s.Get(c.Context(), userID, int64(uploadID))
+	if err != nil {
+		if errors.Is(err, service.ErrUploadNotFound) {
+			return c.SendStatus(fiber.StatusNotFound)
+		}
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	c.Set("Cache-Control", "no-store")
+	c.Set("Upload-Length", strconv.FormatInt(upload.FileSize, 10))
+	setUploadHeaders(c, upload)
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// UploadChunk adds a chunk at Upload-Offset.
+func (h *TusHandler) UploadChunk(c *fiber.Ctx) error {
+	if !checkTusVersion(c) {
+		return c.SendStatus(fiber.StatusPreconditionFailed)
+	}
+	userID := GetUserID(c)
+
+	if c.Get(fiber.HeaderContentType) != tusContentType {
+		return c.SendStatus(fiber.StatusUnsupportedMediaType)
+	}
+
+	uploadID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	offset, err := strconv.ParseInt(c.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Upload-Offset is missing or invalid",
+		})
+	}
+
+	upload, err := h.s.Append(c.Context(), userID, int64(uploadID), offset, c.Body())
+	if err != nil {
+		return tusError(c, err)
+	}
+
+	setUploadHeaders(c, upload)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// TerminateUpload cancels an upload.
+func (h *TusHandler) TerminateUpload(c *fiber.Ctx) error {
+	if !checkTusVersion(c) {
+		return c.SendStatus(fiber.StatusPreconditionFailed)
+	}
+	userID := GetUserID(c)
+
+	uploadID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	if err := h.s.Terminate(c.Context(), userID, int64(uploadID)); err != nil {
+		return tusError(c, err)
+	}
+
+	c.Set("Tus-Resumable", tusVersion)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// checkTusVersion tells whether the client speaks the served version of the
+// protocol.
+func checkTusVersion(c *fiber.Ctx) bool {
+	if c.Get("Tus-Resumable") != tusVersion {
+		c.Set("Tus-Version", tusVersion)
+		return false
+	}
+	return true
+}
+
+func setUploadHeaders(c *fiber.Ctx, upload *models.ResumableUpload) {
+	c.Set("Tus-Resumable", tusVersion)
+	c.Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	if upload.AssetID != 0 {
+		c.Set("Media-Asset-Id", strconv.FormatInt(upload.AssetID, 10))
+	} else {
+		c.Set("Upload-Expires", upload.ExpiresAt.Format(http.TimeFormat))
+	}
+}
+
+// tusError answers with the status of err. Failures of storage or of the
+// database are 500, which tus clients retry.
+func tusError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrUploadChunk), errors.Is(err, service.ErrUploadLength):
+		status = fiber.StatusBadRequest
+	case errors.Is(err, service.ErrUploadNotFound):
+		status = fiber.StatusNotFound
+	case errors.Is(err, service.ErrUploadOffset):
+		status = fiber.StatusConflict
+	case errors.Is(err, service.ErrUploadExpired):
+		status = fiber.StatusGone
+	case errors.Is(err, service.ErrUploadTooLarge):
+		status = fiber.StatusRequestEntityTooLarge
+	case errors.Is(err, service.ErrUploadType):
+		status = fiber.StatusUnsupportedMediaType
+	}
+
+	c.Set("Tus-Resumable", tusVersion)
+	return c.Status(status).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// parseUploadMetadata decodes an Upload-Metadata header, a comma separated
+// list of keys each followed by a base64 encoded value.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if header == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("Upload-Metadata is invalid")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("Upload-Metadata value of %s is invalid", key)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"log/slog"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/maheshrc27/scheduling-api/internal/service"
+	"github.com/maheshrc27/scheduling-api/internal/transfer"
 )
 
 type UserHandler struct {
@@ -26,6 +29,27 @@ func (h *UserHandler) GetUserInfo(c *fiber.Ctx) error {
 	return c.JSON(userInfo)
 }
 
+func (h *UserHandler) UpdateTimezone(c *fiber.Ctx) error {
+	userId := GetUserID(c)
+
+	var timezoneUpdate transfer.TimezoneUpdate
+	if err := c.BodyParser(&timezoneUpdate); err != nil {
+		slog.Error(err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unable to parse request body",
+		})
+	}
+
+	err := h.s.SetTimezone(c.Context(), userId, timezoneUpdate.Timezone)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
 func (h *UserHandler) DeleteAccount(c *fiber.Ctx) error {
 	userId := GetUserID(c)
 	confirmation := c.FormValue("confirmation")
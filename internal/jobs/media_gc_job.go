@@ -0,0 +1,48 @@
+package job
+
+import (
+	"context"
+	"log/slog"
+
+	config "github.com/maheshrc27/scheduling-api/configs"
+	"github.com/maheshrc27/scheduling-api/internal/service"
+)
+
+type MediaGCJob struct {
+	gc     service.MediaGC
+	dryRun bool
+}
+
+func NewMediaGCJob(cfg config.Config, gc service.MediaGC) *MediaGCJob {
+	return &MediaGCJob{
+		gc:     gc,
+		dryRun: cfg.MediaGC.DryRun,
+	}
+}
+
+// CollectMedia removes the media nothing uses anymore and logs them. In a
+// dry run they are only logged.
+func (j *MediaGCJob) CollectMedia() {
+	ctx := context.Background()
+
+	report, err := j.gc.Collect(ctx, j.dryRun)
+	if err != nil {
+		slog.Error("unable to collect unused media", "error", err)
+		return
+	}
+
+	for _, asset := range report.Assets {
+		slog.Info("unused media asset", "dry_run", report.DryRun, "asset_id", asset.ID, "user_id", asset.UserID, "file", asset.FileName, "size", asset.FileSize)
+	}
+	for _, file := range report.Files {
+		slog.Info("unreferenced file", "dry_run", report.DryRun, "file", file.Key, "size", file.Size, "modified_at", file.ModifiedAt)
+	}
+	slog.Info("media collected",
+		"dry_run", report.DryRun,
+		"assets", len(report.Assets),
+		"uploads", report.Uploads,
+		"files", len(report.Files),
+		"bytes", report.Bytes,
+		"failed", report.Failed,
+	)
+}
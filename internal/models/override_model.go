@@ -0,0 +1,34 @@
+package models
+
+// Override replaces parts of a post when it is published to one account or
+// to every account of a platform. Empty fields keep the value of the post.
+type Override struct {
+	Caption  string         `json:"caption,omitempty"`
+	Title    string         `json:"title,omitempty"`
+	Hashtags []string       `json:"hashtags,omitempty"`
+	Options  PublishOptions `json:"options"`
+}
+
+// PublishOptions are platform settings of a publish. Unset fields use the
+// platform defaults.
+type PublishOptions struct {
+	PrivacyLevel   string `json:"privacy_level,omitempty"` // TikTok privacy level or YouTube privacy status
+	DisableComment *bool  `json:"disable_comment,omitempty"`
+	DisableDuet    *bool  `json:"disable_duet,omitempty"`
+	DisableStitch  *bool  `json:"disable_stitch,omitempty"`
+	CategoryID     string `json:"category_id,omitempty"` // YouTube video category
+	ImageFit       string `json:"image_fit,omitempty"`   // crop or pad images to the aspect ratios of the platform
+}
+
+// PlatformOverride is an override for every account of a platform in a post.
+// Overrides of a single account take precedence over it.
+type PlatformOverride struct {
+	PostID   int64  `db:"post_id" json:"post_id"`
+	Platform string `db:"platform" json:"platform"`
+	Override
+}
+
+// IsEmpty reports whether the override doesn't change anything.
+func (o *Override) IsEmpty() bool {
+	return o == nil || (o.Caption == "" && o.Title == "" && len(o.Hashtags) == 0 && o.Options == (PublishOptions{}))
+}
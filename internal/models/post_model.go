@@ -9,20 +9,124 @@ type Post struct {
 	Caption       string    `db:"caption" json:"caption"`
 	Title         string    `db:"title" json:"title"`
 	ScheduledTime time.Time `db:"scheduled_time" json:"scheduled_time"`
-	Status        string    `db:"status" json:"status"` // posted, scheduled, failed, draft
+	Status        string    `db:"status" json:"status"` // draft, scheduled, publishing, posted, partially_published, failed, skipped
+	TaskID        string    `db:"task_id" json:"task_id"`
+	Recurrence    string    `db:"recurrence" json:"recurrence,omitempty"`     // RRULE, e.g. FREQ=WEEKLY;BYDAY=MO
+	SeriesID      int64     `db:"series_id" json:"series_id,omitempty"`       // first post of a recurring series, 0 for the first post itself
+	SeriesStart   time.Time `db:"series_start" json:"series_start,omitempty"` // time of the first occurrence, the rule is counted from it
+	Occurrence    int       `db:"occurrence" json:"occurrence"`
+	Queued        bool      `db:"queued" json:"queued"` // time was picked from the posting slots
+	Category      string    `db:"category" json:"category,omitempty"`
+	Timezone      string    `db:"timezone" json:"timezone"` // zone the time was given in, times are stored in UTC
 	CreatedAt     time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt     time.Time `db:"updated_at" json:"updated_at"`
+
+	Deliveries        []*SelectedAccount  `json:"deliveries,omitempty"`
+	PlatformOverrides []*PlatformOverride `json:"platform_overrides,omitempty"`
+
+	// Options are the platform options of a single publish, see
+	// service.PostForDelivery.
+	Options PublishOptions `json:"-"`
 }
 
 type MediaAsset struct {
-	ID           int64     `db:"id"`
-	UserID       int64     `db:"user_id"`
-	FileName     string    `db:"file_name"`
-	FileType     string    `db:"file_type"`
-	FileSize     int64     `db:"file_size"`
-	FileURL      string    `db:"file_url"`
-	ThumbnailURL string    `db:"thumbnail_url"`
-	CreatedAt    time.Time `db:"created_at"`
+	ID           int64     `db:"id" json:"id"`
+	UserID       int64     `db:"user_id" json:"user_id"`
+	FileName     string    `db:"file_name" json:"file_name"`
+	FileType     string    `db:"file_type" json:"file_type"`
+	FileSize     int64     `db:"file_size" json:"file_size"`
+	FileURL      string    `db:"file_url" json:"file_url"`
+	ThumbnailURL string    `db:"thumbnail_url" json:"thumbnail_url,omitempty"`
+	Width        int       `db:"width" json:"width,omitempty"` // as displayed, rotation applied
+	Height       int       `db:"height" json:"height,omitempty"`
+	AspectRatio  float64   `db:"-" json:"aspect_ratio,omitempty"`    // width over height
+	Duration     int       `db:"duration" json:"duration,omitempty"` // seconds, videos only
+	FrameRate    float64   `db:"frame_rate" json:"frame_rate,omitempty"`
+	Codec        string    `db:"codec" json:"codec,omitempty"`                 // video codec, e.g. h264
+	CoverTimeMs  int       `db:"cover_time_ms" json:"cover_time_ms,omitempty"` // frame of videos used as cover and thumbnail
+	ContentHash  string    `db:"content_hash" json:"content_hash,omitempty"`   // SHA-256, the same content is stored once per user
+	Library      bool      `db:"library" json:"library"`                       // uploaded to the library, not only with a post
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+
+	Renditions []*MediaRendition `json:"renditions,omitempty"`
+}
+
+// MediaVariant is a copy of an image made to fit the rules of a platform,
+// see service.ImageVariants.
+type MediaVariant struct {
+	ID        int64     `db:"id" json:"id"`
+	AssetID   int64     `db:"asset_id" json:"asset_id"`
+	Name      string    `db:"name" json:"name"` // platform and fit, e.g. instagram-crop
+	FileKey   string    `db:"file_key" json:"-"`
+	FileURL   string    `db:"file_url" json:"file_url"`
+	FileSize  int64     `db:"file_size" json:"file_size"`
+	Width     int       `db:"width" json:"width"`
+	Height    int       `db:"height" json:"height"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// MediaRendition is a video converted to be published to a platform, see
+// service.VideoRenditions.
+type MediaRendition struct {
+	ID           int64     `db:"id" json:"id"`
+	AssetID      int64     `db:"asset_id" json:"asset_id"`
+	Platform     string    `db:"platform" json:"platform"`
+	Status       string    `db:"status" json:"status"`     // pending, processing, ready or failed
+	Progress     int       `db:"progress" json:"progress"` // percent
+	FileKey      string    `db:"file_key" json:"-"`
+	FileURL      string    `db:"file_url" json:"file_url,omitempty"`
+	FileSize     int64     `db:"file_size" json:"file_size,omitempty"`
+	Width        int       `db:"width" json:"width,omitempty"`
+	Height       int       `db:"height" json:"height,omitempty"`
+	Duration     int       `db:"duration" json:"duration,omitempty"` // seconds, shorter than the video when it is trimmed
+	ErrorMessage string    `db:"error_message" json:"error_message,omitempty"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// MediaUpload is a file a client was allowed to upload straight to storage.
+// It becomes a MediaAsset once the upload is completed and checked.
+type MediaUpload struct {
+	ID        int64     `db:"id" json:"id"`
+	UserID    int64     `db:"user_id" json:"user_id"`
+	FileKey   string    `db:"file_key" json:"file_key"`
+	FileName  string    `db:"file_name" json:"file_name"`
+	FileType  string    `db:"file_type" json:"file_type"`
+	FileSize  int64     `db:"file_size" json:"file_size"`
+	ExpiresAt time.Time `db:"expires_at" json:"expires_at"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// ResumableUpload is a file uploaded in chunks with the tus protocol. The
+// chunks are stored as the parts of a multipart upload, data that is too
+// small to be a part yet is kept in Pending.
+type ResumableUpload struct {
+	ID          int64     `db:"id" json:"id"`
+	UserID      int64     `db:"user_id" json:"user_id"`
+	FileKey     string    `db:"file_key" json:"file_key"`
+	FileName    string    `db:"file_name" json:"file_name"`
+	FileType    string    `db:"file_type" json:"file_type"` // detected from the first part
+	FileSize    int64     `db:"file_size" json:"file_size"`
+	Offset      int64     `db:"upload_offset" json:"offset"`
+	MultipartID string    `db:"multipart_id" json:"-"`
+	Parts       []string  `db:"parts" json:"-"`                     // ETags of the uploaded parts, in order
+	HashState   []byte    `db:"hash_state" json:"-"`                // SHA-256 state of the uploaded parts
+	AssetID     int64     `db:"asset_id" json:"asset_id,omitempty"` // set once the upload is complete
+	ExpiresAt   time.Time `db:"expires_at" json:"expires_at"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// MediaFilter narrows down the media assets of a user. Zero values are
+// ignored.
+type MediaFilter struct {
+	Type    string // image or video
+	MinSize int64
+	MaxSize int64
+	From    time.Time
+	To      time.Time
+	Limit   int
+	Offset  int
 }
 
 type PostMedia struct {
@@ -33,8 +137,27 @@ type PostMedia struct {
 }
 
 const (
-	PostStatusScheduled = "scheduled"
-	PostStatusPosted    = "posted"
-	PostStatusFailed    = "failed"
-	PostStatusDraft     = "draft"
+	RenditionStatusPending    = "pending"
+	RenditionStatusProcessing = "processing"
+	RenditionStatusReady      = "ready"
+	RenditionStatusFailed     = "failed"
 )
+
+const (
+	PostStatusScheduled          = "scheduled"
+	PostStatusPublishing         = "publishing"
+	PostStatusPosted             = "posted"
+	PostStatusPartiallyPublished = "partially_published"
+	PostStatusFailed             = "failed"
+	PostStatusDraft              = "draft"
+	PostStatusSkipped            = "skipped"
+)
+
+// SeriesRootID returns the ID of the first post of the series the post
+// belongs to.
+func (p *Post) SeriesRootID() int64 {
+	if p.SeriesID != 0 {
+		return p.SeriesID
+	}
+	return p.ID
+}
@@ -7,6 +7,22 @@ type PostingHistory struct {
 	UserID       int64     `db:"user_id" json:"user_id"`
 	PostID       int64     `db:"post_id" json:"post_id"`
 	AccountID    int64     `db:"account_id" json:"account_id"`
+	AccountName  string    `db:"account_name" json:"account_name,omitempty"`
+	Platform     string    `db:"platform" json:"platform,omitempty"`
+	Attempt      int       `db:"attempt" json:"attempt"`
 	ErrorMessage string    `db:"error_message" json:"error_message"`
 	CreatedAt    time.Time `db:"created_at" json:"created_at"`
 }
+
+// HistoryFilter narrows down the posting history of a user. Zero values are
+// ignored.
+type HistoryFilter struct {
+	PostID    int64
+	AccountID int64
+	Platform  string
+	Succeeded *bool
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Offset    int
+}
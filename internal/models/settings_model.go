@@ -2,10 +2,14 @@ package models
 
 import "time"
 
+// Settings is a weekly posting slot. Slots without an account apply to all
+// the accounts of the user.
 type Settings struct {
 	ID          int64     `db:"id" json:"id"`
-	UserID      string    `db:"user_id" json:"user_id"`
-	PostingTime time.Time `db:"posting_time" json:"posting_time"`
+	UserID      int64     `db:"user_id" json:"user_id"`
+	AccountID   int64     `db:"account_id" json:"account_id,omitempty"`
+	Weekday     int       `db:"weekday" json:"weekday"`           // 0 is Sunday, as time.Weekday
+	PostingTime time.Time `db:"posting_time" json:"posting_time"` // only the time of day is used
 	Category    string    `db:"category" json:"category"`
 	CreatedAt   time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
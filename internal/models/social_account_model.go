@@ -20,9 +20,29 @@ type SocialAccount struct {
 	UpdatedAt       time.Time `db:"updated_at" json:"updated_at"`
 }
 
+const AccountStatusActive = "active"
+
+// SelectedAccount links a post to an account it is published to and tracks
+// the delivery to that account.
 type SelectedAccount struct {
-	PostID    int64     `db:"post_id" json:"post_id"`
-	AccountID int64     `db:"account_id" json:"account_id"`
-	CreatedAt time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	PostID        int64      `db:"post_id" json:"post_id"`
+	AccountID     int64      `db:"account_id" json:"account_id"`
+	Platform      string     `db:"platform" json:"platform"`
+	AccountName   string     `db:"account_name" json:"account_name"`
+	Status        string     `db:"status" json:"status"` // pending, publishing, published, failed
+	RemoteMediaID string     `db:"remote_media_id" json:"remote_media_id"`
+	Permalink     string     `db:"permalink" json:"permalink"`
+	ErrorMessage  string     `db:"error_message" json:"error_message"`
+	Attempts      int        `db:"attempts" json:"attempts"`
+	PublishedAt   *time.Time `db:"published_at" json:"published_at"`
+	Override      *Override  `json:"override,omitempty"`
+	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time  `db:"updated_at" json:"updated_at"`
 }
+
+const (
+	DeliveryStatusPending    = "pending"
+	DeliveryStatusPublishing = "publishing"
+	DeliveryStatusPublished  = "published"
+	DeliveryStatusFailed     = "failed"
+)
@@ -8,6 +8,7 @@ type User struct {
 	Email          string    `db:"email" json:"email"`
 	Name           string    `db:"name" json:"name"`
 	ProfilePicture string    `db:"profile_picture" json:"profile_picture"`
+	Timezone       string    `db:"timezone" json:"timezone"`
 	CreatedAt      time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt      time.Time `db:"updated_at" json:"updated_at"`
 }
@@ -1,6 +1,8 @@
 package queue
 
 import (
+	"time"
+
 	"github.com/maheshrc27/scheduling-api/internal/repository"
 	"github.com/maheshrc27/scheduling-api/internal/service"
 )
@@ -9,39 +11,72 @@ type Queue struct {
 	pr repository.PostRepository
 	ph repository.PostingHistoryRepository
 	sa repository.SelectedAccountRepository
+	po repository.PlatformOverrideRepository
 	ac repository.SocialAccountRepository
 	ma repository.MediaAssetRepository
 	pm repository.PostMediaRepository
+	ps service.PostService
 	yt service.YoutubeService
 	tt service.TiktokService
 	ig service.InstagramService
+	th service.ThumbnailService
+	vr service.VideoRenditions
 }
 
 func NewQueue(
 	pr repository.PostRepository,
 	ph repository.PostingHistoryRepository,
 	sa repository.SelectedAccountRepository,
+	po repository.PlatformOverrideRepository,
 	ma repository.MediaAssetRepository,
 	ac repository.SocialAccountRepository,
 	pm repository.PostMediaRepository,
+	ps service.PostService,
 	yt service.YoutubeService,
 	tt service.TiktokService,
-	ig service.InstagramService) *Queue {
+	ig service.InstagramService,
+	th service.ThumbnailService,
+	vr service.VideoRenditions) *Queue {
 	return &Queue{
 		pr: pr,
 		ph: ph,
 		sa: sa,
+		po: po,
 		ac: ac,
 		ma: ma,
 		pm: pm,
+		ps: ps,
 		yt: yt,
 		tt: tt,
 		ig: ig,
+		th: th,
+		vr: vr,
 	}
 }
 
-const TaskTypeSchedulePost = "schedule:post"
+const (
+	TaskTypeSchedulePost      = "schedule:post"
+	TaskTypeGenerateThumbnail = "media:thumbnail"
+	TaskTypeTranscodeVideo    = "media:transcode"
+	DefaultQueue              = "default"
+
+	// MaxPublishAttempts is how many times a post is sent to an account
+	// before its delivery is marked as failed.
+	MaxPublishAttempts = 5
+
+	// renditionWait is how long a post waits for its videos to be converted
+	// before the next check.
+	renditionWait = time.Minute
+
+	// maxRenditionWait is how long a delivery waits for its videos to be
+	// converted before it fails.
+	maxRenditionWait = 6 * time.Hour
+)
 
 type SchedulePostPayload struct {
 	PostID int64 `json:"post_id"`
 }
+
+type MediaAssetPayload struct {
+	AssetID int64 `json:"asset_id"`
+}
@@ -0,0 +1,41 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"github.com/maheshrc27/scheduling-api/internal/service"
+)
+
+func (j *Queue) HandleThumbnailTask(ctx context.Context, task *asynq.Task) error {
+	var payload MediaAssetPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return err
+	}
+
+	if err := j.th.Generate(ctx, payload.AssetID); err != nil {
+		if errors.Is(err, service.ErrAssetNotFound) || errors.Is(err, service.ErrImageTooLarge) {
+			return fmt.Errorf("asset %d: %v: %w", payload.AssetID, err, asynq.SkipRetry)
+		}
+		return fmt.Errorf("asset %d: %w", payload.AssetID, err)
+	}
+	return nil
+}
+
+func (j *Queue) HandleTranscodeTask(ctx context.Context, task *asynq.Task) error {
+	var payload MediaAssetPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return err
+	}
+
+	if err := j.vr.Transcode(ctx, payload.AssetID); err != nil {
+		if errors.Is(err, service.ErrAssetNotFound) {
+			return fmt.Errorf("asset %d: %v: %w", payload.AssetID, err, asynq.SkipRetry)
+		}
+		return fmt.Errorf("asset %d: %w", payload.AssetID, err)
+	}
+	return nil
+}
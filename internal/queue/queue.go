@@ -2,13 +2,14 @@ package queue
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"time"
 
 	"github.com/hibiken/asynq"
 )
 
-func EnqueuePost(asynqClient *asynq.Client, payload SchedulePostPayload, delay time.Duration) error {
+func EnqueuePost(asynqClient *asynq.Client, payload SchedulePostPayload, taskID string, delay time.Duration) error {
 	taskPayload, err := json.Marshal(payload)
 	if err != nil {
 		return err
@@ -16,11 +17,61 @@ func EnqueuePost(asynqClient *asynq.Client, payload SchedulePostPayload, delay t
 
 	task := asynq.NewTask(TaskTypeSchedulePost, taskPayload)
 
-	_, err = asynqClient.Enqueue(task, asynq.ProcessIn(delay))
+	info, err := asynqClient.Enqueue(task, asynq.TaskID(taskID), asynq.ProcessIn(delay), asynq.MaxRetry(MaxPublishAttempts-1))
 	if err != nil {
 		return err
 	}
 
-	log.Printf("Task scheduled: %+v", payload)
+	log.Printf("Task scheduled: %+v (task %s)", payload, info.ID)
 	return nil
 }
+
+// EnqueueThumbnail queues the generation of the thumbnail of an asset.
+func EnqueueThumbnail(asynqClient *asynq.Client, payload MediaAssetPayload) error {
+	taskPayload, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	task := asynq.NewTask(TaskTypeGenerateThumbnail, taskPayload)
+
+	_, err = asynqClient.Enqueue(task, asynq.MaxRetry(3), asynq.Timeout(5*time.Minute))
+	return err
+}
+
+// EnqueueTranscode queues the conversion of a video to the renditions it
+// needs. An asset is only queued once at a time, asynq.ErrTaskIDConflict is
+// returned while its task exists.
+func EnqueueTranscode(asynqClient *asynq.Client, payload MediaAssetPayload) error {
+	taskPayload, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	task := asynq.NewTask(TaskTypeTranscodeVideo, taskPayload)
+
+	_, err = asynqClient.Enqueue(task,
+		asynq.TaskID(transcodeTaskID(payload.AssetID)),
+		asynq.MaxRetry(2),
+		asynq.Timeout(time.Hour),
+	)
+	return err
+}
+
+func transcodeTaskID(assetID int64) string {
+	return fmt.Sprintf("transcode:%d", assetID)
+}
+
+// RetryDelay backs off exponentially between publish attempts, starting at
+// one minute and capped at an hour. Other tasks keep asynq's default delay.
+func RetryDelay(n int, err error, task *asynq.Task) time.Duration {
+	if task.Type() != TaskTypeSchedulePost {
+		return asynq.DefaultRetryDelayFunc(n, err, task)
+	}
+
+	delay := time.Minute << n
+	if n > 6 || delay > time.Hour {
+		delay = time.Hour
+	}
+	return delay
+}
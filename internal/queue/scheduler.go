@@ -0,0 +1,78 @@
+package queue
+
+import (
+	"errors"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// Scheduler implements service.PostScheduler and service.MediaProcessor on
+// top of asynq.
+type Scheduler struct {
+	client    *asynq.Client
+	inspector *asynq.Inspector
+}
+
+func NewScheduler(client *asynq.Client, inspector *asynq.Inspector) *Scheduler {
+	return &Scheduler{
+		client:    client,
+		inspector: inspector,
+	}
+}
+
+func (s *Scheduler) Schedule(postID int64, taskID string, delay time.Duration) error {
+	return EnqueuePost(s.client, SchedulePostPayload{PostID: postID}, taskID, delay)
+}
+
+func (s *Scheduler) Cancel(taskID string) error {
+	if taskID == "" {
+		return nil
+	}
+	err := s.inspector.DeleteTask(DefaultQueue, taskID)
+	if err != nil && !errors.Is(err, asynq.ErrTaskNotFound) {
+		return err
+	}
+	return nil
+}
+
+func (s *Scheduler) GenerateThumbnail(assetID int64) error {
+	return EnqueueThumbnail(s.client, MediaAssetPayload{AssetID: assetID})
+}
+
+// Transcode queues the conversion of a video unless it is already queued. A
+// conversion that asynq gave up on keeps its task ID, it is replaced.
+func (s *Scheduler) Transcode(assetID int64) error {
+	payload := MediaAssetPayload{AssetID: assetID}
+	err := EnqueueTranscode(s.client, payload)
+	if !errors.Is(err, asynq.ErrTaskIDConflict) {
+		return err
+	}
+
+	info, err := s.inspector.GetTaskInfo(DefaultQueue, transcodeTaskID(assetID))
+	if err != nil {
+		// It just finished
+		if errors.Is(err, asynq.ErrTaskNotFound) {
+			return s.requeueTranscode(payload)
+		}
+		return err
+	}
+	if info.State != asynq.TaskStateArchived {
+		return nil
+	}
+
+	if err := s.inspector.DeleteTask(DefaultQueue, info.ID); err != nil && !errors.Is(err, asynq.ErrTaskNotFound) {
+		return err
+	}
+	return s.requeueTranscode(payload)
+}
+
+// requeueTranscode queues a conversion whose previous task is gone. Another
+// call may have queued it meanwhile.
+func (s *Scheduler) requeueTranscode(payload MediaAssetPayload) error {
+	err := EnqueueTranscode(s.client, payload)
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		return nil
+	}
+	return err
+}
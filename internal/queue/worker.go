@@ -4,11 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/hibiken/asynq"
 	"github.com/maheshrc27/scheduling-api/internal/models"
+	"github.com/maheshrc27/scheduling-api/internal/service"
+	"github.com/maheshrc27/scheduling-api/internal/transfer"
 )
 
 func (j *Queue) HandleSchedulePostTask(ctx context.Context, task *asynq.Task) error {
@@ -17,19 +21,41 @@ func (j *Queue) HandleSchedulePostTask(ctx context.Context, task *asynq.Task) er
 		return err
 	}
 
-	j.PublishPost(payload.PostID)
+	post, err := j.pr.GetByID(ctx, payload.PostID)
+	if err != nil {
+		return err
+	}
 
-	return nil
+	taskID, _ := asynq.GetTaskID(ctx)
+	if staleTask(post, taskID) {
+		log.Printf("Skipping stale task %s for PostID %d", taskID, payload.PostID)
+		return nil
+	}
+
+	return j.PublishPost(ctx, payload.PostID)
 }
 
-func (j *Queue) PublishPost(postID int64) error {
-	ctx := context.Background()
+// staleTask tells whether the post was removed or rescheduled after the task
+// was queued. The ID of a task is stored on its post before it is queued.
+func staleTask(post *models.Post, taskID string) bool {
+	return post == nil || (post.TaskID != "" && post.TaskID != taskID)
+}
 
+// PublishPost sends the post to every selected account that hasn't received
+// it yet. It returns an error while some deliveries can still be retried so
+// that asynq runs the task again after a backoff; accounts that already
+// succeeded are skipped on those runs. A delivery is never sent twice: one
+// whose outcome wasn't recorded is settled instead, and what is still pending
+// on the last run fails.
+func (j *Queue) PublishPost(ctx context.Context, postID int64) error {
 	// Fetch the post
 	post, err := j.pr.GetByID(ctx, postID)
 	if err != nil {
 		return err
 	}
+	if post == nil {
+		return fmt.Errorf("post %d not found: %w", postID, asynq.SkipRetry)
+	}
 
 	// Fetch accounts associated with the post
 	accountsSelected, err := j.sa.ListByPostID(ctx, postID)
@@ -37,25 +63,46 @@ func (j *Queue) PublishPost(postID int64) error {
 		return err
 	}
 	if accountsSelected == nil {
-		return errors.New("no accounts selected for publishing")
+		return fmt.Errorf("no accounts selected for publishing: %w", asynq.SkipRetry)
+	}
+
+	platformOverrides, err := j.po.ListByPostID(ctx, postID)
+	if err != nil {
+		return err
+	}
+
+	if err := j.pr.UpdatePostStatus(ctx, models.PostStatusPublishing, postID); err != nil {
+		log.Printf("Error updating status for PostID %d: %v", postID, err)
 	}
 
 	var wg sync.WaitGroup
 	semaphore := make(chan struct{}, 10) // Concurrency limit
 
 	// Helper to handle posting
-	postToPlatform := func(post *models.Post, socialAcc *models.SocialAccount) {
+	postToPlatform := func(post *models.Post, delivery *models.SelectedAccount, socialAcc *models.SocialAccount) {
 		defer wg.Done()
 		defer func() { <-semaphore }()
 
-		var err error
+		attempt, err := j.sa.StartDeliveryAttempt(ctx, delivery.PostID, delivery.AccountID)
+		if err != nil {
+			log.Printf("Error starting delivery of PostID %d to AccountID %d: %v", post.ID, socialAcc.ID, err)
+			return
+		}
+		delivery.Attempts = attempt
+
+		// Publish the post as overridden for this account
+		accountPost := service.PostForDelivery(post, delivery, platformOverrides)
+
+		var result *transfer.PublishResult
 		switch socialAcc.Platform {
 		case "tiktok":
-			err = j.tt.HandleTiktokPost(ctx, post, socialAcc)
+			result, err = j.tt.HandleTiktokPost(ctx, accountPost, socialAcc)
 		case "instagram":
-			err = j.ig.HandleInstagramPost(ctx, post, socialAcc)
+			result, err = j.ig.HandleInstagramPost(ctx, accountPost, socialAcc)
 		case "youtube":
-			err = j.yt.PostYoutubeVideo(ctx, post, socialAcc)
+			result, err = j.yt.PostYoutubeVideo(ctx, accountPost, socialAcc)
+		default:
+			err = fmt.Errorf("unsupported platform %s", socialAcc.Platform)
 		}
 
 		// Log posting history
@@ -63,19 +110,42 @@ func (j *Queue) PublishPost(postID int64) error {
 			UserID:       socialAcc.UserID,
 			PostID:       postID,
 			AccountID:    socialAcc.ID,
+			Attempt:      attempt,
 			ErrorMessage: "",
 		}
 		if err != nil {
 			postingHistory.ErrorMessage = err.Error()
-			log.Printf("Error posting to %s for PostID %d: %v", socialAcc.Platform, post.ID, err)
+			log.Printf("Error posting to %s for PostID %d (attempt %d/%d): %v", socialAcc.Platform, post.ID, attempt, MaxPublishAttempts, err)
+			if errors.Is(err, service.ErrImageTooLarge) {
+				// Another attempt would fail the same way
+				j.setDeliveryStatus(ctx, delivery, models.DeliveryStatusFailed, nil, err)
+			} else {
+				j.failDelivery(ctx, delivery, err)
+			}
+		} else {
+			j.setDeliveryStatus(ctx, delivery, models.DeliveryStatusPublished, result, nil)
 		}
 		if _, err := j.ph.Create(ctx, &postingHistory); err != nil {
 			log.Printf("Error saving posting history for PostID %d: %v", post.ID, err)
 		}
 	}
 
-	// Process each account
+	// Deliveries to platforms whose videos are still converted wait for a
+	// later run, without using an attempt
+	var waiting int
+	ready := make(map[string]bool)
+
+	// Process each account that still has to receive the post
 	for _, acc := range accountsSelected {
+		switch acc.Status {
+		case models.DeliveryStatusPublished, models.DeliveryStatusFailed:
+			continue
+		case models.DeliveryStatusPublishing:
+			// A previous run didn't get to record the outcome
+			j.reconcileDelivery(ctx, acc)
+			continue
+		}
+
 		socialAcc, err := j.ac.GetByID(ctx, acc.AccountID)
 		if err != nil {
 			log.Printf("Error retrieving social account for AccountID %d: %v", acc.AccountID, err)
@@ -83,14 +153,183 @@ func (j *Queue) PublishPost(postID int64) error {
 		}
 		if socialAcc == nil {
 			log.Printf("Social account for AccountID %d is nil", acc.AccountID)
+			j.setDeliveryStatus(ctx, acc, models.DeliveryStatusFailed, nil, errors.New("social account not found"))
+			continue
+		}
+
+		if _, ok := ready[socialAcc.Platform]; !ok {
+			ready[socialAcc.Platform] = j.renditionsReady(ctx, postID, socialAcc.Platform)
+		}
+		if !ready[socialAcc.Platform] {
+			if time.Since(waitingSince(post, acc)) > maxRenditionWait {
+				log.Printf("Videos of PostID %d were not converted for %s in time", postID, socialAcc.Platform)
+				j.setDeliveryStatus(ctx, acc, models.DeliveryStatusFailed, nil, errRenditionTimeout)
+				continue
+			}
+			waiting++
 			continue
 		}
 
 		wg.Add(1)
 		semaphore <- struct{}{}
-		go postToPlatform(post, socialAcc)
+		go postToPlatform(post, acc, socialAcc)
 	}
 
 	wg.Wait() // Wait for all goroutines to finish
+
+	deliveries, err := j.sa.ListByPostID(ctx, postID)
+	if err != nil {
+		return err
+	}
+
+	// Nothing retries what is left once asynq gave up on the task
+	if waiting == 0 && lastRun(ctx) {
+		for _, d := range deliveries {
+			switch d.Status {
+			case models.DeliveryStatusPending:
+				j.setDeliveryStatus(ctx, d, models.DeliveryStatusFailed, nil, errNoAttemptsLeft)
+			case models.DeliveryStatusPublishing:
+				j.reconcileDelivery(ctx, d)
+			}
+		}
+	}
+
+	if err := j.pr.UpdatePostStatus(ctx, service.AggregatePostStatus(deliveries), postID); err != nil {
+		return err
+	}
+
+	if waiting > 0 {
+		if err := j.ps.Defer(ctx, postID, renditionWait); err != nil {
+			return err
+		}
+		log.Printf("PostID %d: %d deliveries wait for their videos to be converted", postID, waiting)
+		return nil
+	}
+
+	var retrying, unrecorded, failed int
+	for _, d := range deliveries {
+		switch d.Status {
+		case models.DeliveryStatusPending:
+			retrying++
+		case models.DeliveryStatusPublishing:
+			unrecorded++
+		case models.DeliveryStatusFailed:
+			failed++
+		}
+	}
+
+	// The next run settles these without sending them again
+	if unrecorded > 0 {
+		return fmt.Errorf("post %d: the outcome of %d deliveries could not be recorded", postID, unrecorded)
+	}
+	if retrying > 0 {
+		return fmt.Errorf("post %d: %d deliveries will be retried", postID, retrying)
+	}
+
+	// This occurrence is done, queue the next one of a recurring post
+	if nextID, err := j.ps.ScheduleNextOccurrence(ctx, postID); err != nil {
+		log.Printf("Error scheduling next occurrence of PostID %d: %v", postID, err)
+	} else if nextID != 0 {
+		log.Printf("Scheduled next occurrence of PostID %d as PostID %d", postID, nextID)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("post %d: %d deliveries failed: %w", postID, failed, asynq.SkipRetry)
+	}
 	return nil
 }
+
+// renditionsReady tells whether the videos of a post can be published to a
+// platform, that none of them is still converted for it. Other errors are
+// left to the publishing.
+func (j *Queue) renditionsReady(ctx context.Context, postID int64, platform string) bool {
+	postMedias, err := j.pm.ListByPostID(ctx, postID)
+	if err != nil {
+		log.Printf("Error retrieving media of PostID %d: %v", postID, err)
+		return true
+	}
+
+	for _, postMedia := range postMedias {
+		asset, err := j.ma.GetByID(ctx, postMedia.AssetID)
+		if err != nil || asset == nil {
+			continue
+		}
+		if _, err := j.vr.URL(ctx, asset, platform); errors.Is(err, service.ErrRenditionPending) {
+			return false
+		}
+	}
+	return true
+}
+
+// errNoAttemptsLeft fails the deliveries that are still pending when the
+// publish task runs for the last time, because they couldn't be started.
+var errNoAttemptsLeft = errors.New("the post could not be published in the attempts allowed")
+
+// errInterruptedDelivery fails a delivery whose publishing was interrupted
+// before its outcome was recorded.
+var errInterruptedDelivery = errors.New("publishing was interrupted and the post may have reached the account, check it before retrying")
+
+// errRenditionTimeout fails a delivery whose videos weren't converted for
+// its platform within maxRenditionWait.
+var errRenditionTimeout = errors.New("the videos were not converted for the platform in time")
+
+// waitingSince returns when a delivery became due: when the post was
+// scheduled, or when the delivery was retried after that.
+func waitingSince(post *models.Post, delivery *models.SelectedAccount) time.Time {
+	if delivery.UpdatedAt.After(post.ScheduledTime) {
+		return delivery.UpdatedAt
+	}
+	return post.ScheduledTime
+}
+
+// lastRun tells whether asynq gives up on the task if this run fails.
+func lastRun(ctx context.Context) bool {
+	retried, ok := asynq.GetRetryCount(ctx)
+	if !ok {
+		return false
+	}
+	maxRetry, ok := asynq.GetMaxRetry(ctx)
+	return ok && retried >= maxRetry
+}
+
+// reconcileDelivery settles a delivery that a previous run left publishing,
+// because it stopped or couldn't write the outcome. The post may have reached
+// the account, so it is never sent again: the delivery counts as published
+// when the platform's media was recorded and fails otherwise.
+func (j *Queue) reconcileDelivery(ctx context.Context, delivery *models.SelectedAccount) {
+	if delivery.RemoteMediaID != "" {
+		j.setDeliveryStatus(ctx, delivery, models.DeliveryStatusPublished, nil, nil)
+		return
+	}
+	log.Printf("Delivery of PostID %d to AccountID %d was interrupted, not publishing it again", delivery.PostID, delivery.AccountID)
+	j.setDeliveryStatus(ctx, delivery, models.DeliveryStatusFailed, nil, errInterruptedDelivery)
+}
+
+// failDelivery puts a failed delivery back to pending so the next run of the
+// task retries it, or marks it as failed once it used all its attempts.
+func (j *Queue) failDelivery(ctx context.Context, delivery *models.SelectedAccount, publishErr error) {
+	status := models.DeliveryStatusPending
+	if delivery.Attempts >= MaxPublishAttempts {
+		status = models.DeliveryStatusFailed
+	}
+	j.setDeliveryStatus(ctx, delivery, status, nil, publishErr)
+}
+
+// setDeliveryStatus records the outcome of publishing a post to one account.
+func (j *Queue) setDeliveryStatus(ctx context.Context, delivery *models.SelectedAccount, status string, result *transfer.PublishResult, publishErr error) {
+	delivery.Status = status
+	delivery.ErrorMessage = ""
+	if publishErr != nil {
+		delivery.ErrorMessage = publishErr.Error()
+	}
+	if result != nil {
+		now := time.Now().UTC()
+		delivery.RemoteMediaID = result.MediaID
+		delivery.Permalink = result.Permalink
+		delivery.PublishedAt = &now
+	}
+
+	if err := j.sa.UpdateDelivery(ctx, delivery); err != nil {
+		log.Printf("Error updating delivery of PostID %d to AccountID %d: %v", delivery.PostID, delivery.AccountID, err)
+	}
+}
@@ -0,0 +1,162 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/maheshrc27/scheduling-api/internal/models"
+	"github.com/maheshrc27/scheduling-api/internal/repository"
+	"github.com/maheshrc27/scheduling-api/internal/service"
+)
+
+type fakePosts struct {
+	repository.PostRepository
+	post *models.Post
+}
+
+func (f *fakePosts) CheckByUserID(ctx context.Context, postID, userID int64) (bool, error) {
+	return postID == f.post.ID && userID == f.post.UserID, nil
+}
+
+func (f *fakePosts) GetByID(ctx context.Context, id int64) (*models.Post, error) {
+	if id != f.post.ID {
+		return nil, nil
+	}
+	post := *f.post
+	return &post, nil
+}
+
+func (f *fakePosts) SetTaskID(ctx context.Context, postID int64, taskID string) error {
+	f.post.TaskID = taskID
+	return nil
+}
+
+func (f *fakePosts) UpdatePostStatus(ctx context.Context, status string, postID int64) error {
+	f.post.Status = status
+	return nil
+}
+
+type fakeDeliveries struct {
+	repository.SelectedAccountRepository
+	deliveries []*models.SelectedAccount
+}
+
+func (f *fakeDeliveries) ListByPostID(ctx context.Context, postID int64) ([]*models.SelectedAccount, error) {
+	var deliveries []*models.SelectedAccount
+	for _, d := range f.deliveries {
+		delivery := *d
+		deliveries = append(deliveries, &delivery)
+	}
+	return deliveries, nil
+}
+
+func (f *fakeDeliveries) ResetDelivery(ctx context.Context, postID, accountID int64) error {
+	for _, d := range f.deliveries {
+		if d.AccountID == accountID {
+			d.Status = models.DeliveryStatusPending
+		}
+	}
+	return nil
+}
+
+type fakeOverrides struct {
+	repository.PlatformOverrideRepository
+}
+
+func (fakeOverrides) ListByPostID(ctx context.Context, postID int64) ([]*models.PlatformOverride, error) {
+	return nil, nil
+}
+
+type fakeAccounts struct {
+	repository.SocialAccountRepository
+}
+
+func (fakeAccounts) GetByID(ctx context.Context, id int64) (*models.SocialAccount, error) {
+	return &models.SocialAccount{
+		ID:             id,
+		AccountStatus:  models.AccountStatusActive,
+		TokenExpiresAt: time.Now().Add(time.Hour),
+	}, nil
+}
+
+type fakeUsers struct {
+	repository.UserRepository
+}
+
+func (fakeUsers) GetByID(ctx context.Context, id int64) (*models.User, bool, error) {
+	return nil, false, nil
+}
+
+// runningScheduler runs the tasks it is given as soon as they are queued,
+// as asynq may for a task that is due.
+type runningScheduler struct {
+	posts *fakePosts
+	stale []bool
+}
+
+func (s *runningScheduler) Schedule(postID int64, taskID string, delay time.Duration) error {
+	post, _ := s.posts.GetByID(context.Background(), postID)
+	s.stale = append(s.stale, staleTask(post, taskID))
+	return nil
+}
+
+func (s *runningScheduler) Cancel(taskID string) error {
+	return nil
+}
+
+func TestRetriedPostIsNotStale(t *testing.T) {
+	posts := &fakePosts{post: &models.Post{
+		ID:     1,
+		UserID: 2,
+		Status: models.PostStatusFailed,
+		TaskID: "first-run",
+	}}
+	deliveries := &fakeDeliveries{deliveries: []*models.SelectedAccount{
+		{PostID: 1, AccountID: 3, Status: models.DeliveryStatusPublished},
+		{PostID: 1, AccountID: 4, Status: models.DeliveryStatusFailed},
+	}}
+	scheduler := &runningScheduler{posts: posts}
+
+	ps := service.NewPostService(nil, fakeUsers{}, posts, deliveries, fakeOverrides{}, nil, fakeAccounts{}, nil, nil, nil, nil, scheduler, nil)
+
+	retried, err := ps.Retry(context.Background(), 2, 1, nil)
+	if err != nil {
+		t.Fatalf("Retry() error = %v", err)
+	}
+	if len(retried) != 1 || retried[0] != 4 {
+		t.Errorf("Retry() = %v, want [4]", retried)
+	}
+
+	if len(scheduler.stale) != 1 {
+		t.Fatalf("Retry() queued %d tasks, want 1", len(scheduler.stale))
+	}
+	if scheduler.stale[0] {
+		t.Errorf("retry task was skipped as stale, post has task %q", posts.post.TaskID)
+	}
+	if post, _ := posts.GetByID(context.Background(), 1); !staleTask(post, "first-run") {
+		t.Errorf("task of the first run isn't stale after the retry")
+	}
+}
+
+func TestStaleTask(t *testing.T) {
+	tests := []struct {
+		name   string
+		post   *models.Post
+		taskID string
+		want   bool
+	}{
+		{name: "removed post", post: nil, taskID: "a", want: true},
+		{name: "current task", post: &models.Post{TaskID: "a"}, taskID: "a", want: false},
+		{name: "replaced task", post: &models.Post{TaskID: "b"}, taskID: "a", want: true},
+		{name: "post without task", post: &models.Post{}, taskID: "a", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := staleTask(tt.post, tt.taskID); got != tt.want {
+				t.Errorf("staleTask() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,15 +3,29 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"log/slog"
+	"math"
+	"strings"
+	"time"
 
 	"github.com/maheshrc27/scheduling-api/internal/models"
 )
 
 type MediaAssetRepository interface {
-	Create(ctx context.Context, tx *sql.Tx, ma *models.MediaAsset) (int64, error)
+	Create(ctx context.Context, tx *sql.Tx, ma *models.MediaAsset) (int64, bool, error)
 	GetByID(ctx context.Context, id int64) (*models.MediaAsset, error)
-	Remove(ctx context.Context, id int64) error
+	GetByHash(ctx context.Context, userID int64, hash string) (*models.MediaAsset, error)
+	List(ctx context.Context, userID int64, filter *models.MediaFilter) ([]*models.MediaAsset, int, error)
+	CheckByUserID(ctx context.Context, id, userID int64) (bool, error)
+	CountUsage(ctx context.Context, id int64) (int, error)
+	UpdateThumbnail(ctx context.Context, id int64, thumbnailURL string) error
+	UpdateCover(ctx context.Context, id int64, coverTimeMs int) error
+	AddToLibrary(ctx context.Context, id int64) error
+	Remove(ctx context.Context, id int64) (bool, error)
+	ListOrphans(ctx context.Context, before time.Time) ([]*models.MediaAsset, error)
+	RemoveOrphan(ctx context.Context, id int64, before time.Time) (bool, error)
+	ListFileReferences(ctx context.Context) ([]string, error)
 }
 
 type mediaAssetRepository struct {
@@ -22,45 +36,69 @@ func NewMediaAssetRepository(db *sql.DB) MediaAssetRepository {
 	return &mediaAssetRepository{db: db}
 }
 
-func (r *mediaAssetRepository) Create(ctx context.Context, tx *sql.Tx, ma *models.MediaAsset) (int64, error) {
-	var id int64
-	var err error
+const mediaAssetColumns = `id, COALESCE(user_id, 0), file_name, file_type, file_size, file_url,
+	COALESCE(thumbnail_url, ''), COALESCE(width, 0), COALESCE(height, 0), COALESCE(duration, 0),
+	COALESCE(frame_rate, 0), COALESCE(codec, ''), COALESCE(cover_time_ms, 0), COALESCE(content_hash, ''),
+	library, created_at`
 
+func scanMediaAsset(row interface{ Scan(dest ...any) error }) (*models.MediaAsset, error) {
+	var ma models.MediaAsset
+	err := row.Scan(
+		&ma.ID,
+		&ma.UserID,
+		&ma.FileName,
+		&ma.FileType,
+		&ma.FileSize,
+		&ma.FileURL,
+		&ma.ThumbnailURL,
+		&ma.Width,
+		&ma.Height,
+		&ma.Duration,
+		&ma.FrameRate,
+		&ma.Codec,
+		&ma.CoverTimeMs,
+		&ma.ContentHash,
+		&ma.Library,
+		&ma.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if ma.Width > 0 && ma.Height > 0 {
+		ma.AspectRatio = math.Round(float64(ma.Width)/float64(ma.Height)*1000) / 1000
+	}
+	return &ma, nil
+}
+
+// Create saves an asset and returns its ID. When the user already has an
+// asset with the same content hash, saved meanwhile by another upload, that
+// asset is returned instead and created is false.
+func (r *mediaAssetRepository) Create(ctx context.Context, tx *sql.Tx, ma *models.MediaAsset) (id int64, created bool, err error) {
 	query := `
-		INSERT INTO media_assets (user_id, file_name, file_type, file_size, file_url)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id
+		INSERT INTO media_assets (user_id, file_name, file_type, file_size, file_url, width, height, duration, frame_rate, codec, cover_time_ms, content_hash, library)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), NULLIF($7, 0), NULLIF($8, 0), NULLIF($9, 0), NULLIF($10, ''), $11, NULLIF($12, ''), $13)
+		ON CONFLICT (user_id, content_hash) DO UPDATE SET library = media_assets.library OR EXCLUDED.library
+		RETURNING id, xmax = 0
 	`
+	args := []any{ma.UserID, ma.FileName, ma.FileType, ma.FileSize, ma.FileURL, ma.Width, ma.Height, ma.Duration, ma.FrameRate, ma.Codec, ma.CoverTimeMs, ma.ContentHash, ma.Library}
 	if tx != nil {
-		err = tx.QueryRowContext(ctx, query, ma.UserID, ma.FileName, ma.FileType, ma.FileSize, ma.FileURL).Scan(&id)
+		err = tx.QueryRowContext(ctx, query, args...).Scan(&id, &created)
 	} else {
-		err = r.db.QueryRowContext(ctx, query, ma.UserID, ma.FileName, ma.FileType, ma.FileSize, ma.FileURL).Scan(&id)
+		err = r.db.QueryRowContext(ctx, query, args...).Scan(&id, &created)
 	}
 
 	if err != nil {
 		slog.Info(err.Error())
-		return 0, err
+		return 0, false, err
 	}
 
-	return id, nil
+	return id, created, nil
 }
 
 func (r *mediaAssetRepository) GetByID(ctx context.Context, id int64) (*models.MediaAsset, error) {
-	query := `
-		SELECT id, user_id, file_name, file_type, file_url, created_at
-		FROM media_assets
-		WHERE id = $1
-	`
+	query := `SELECT ` + mediaAssetColumns + ` FROM media_assets WHERE id = $1`
 
-	var ma models.MediaAsset
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&ma.ID,
-		&ma.UserID,
-		&ma.FileName,
-		&ma.FileType,
-		&ma.FileURL,
-		&ma.CreatedAt,
-	)
+	ma, err := scanMediaAsset(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -69,14 +107,165 @@ func (r *mediaAssetRepository) GetByID(ctx context.Context, id int64) (*models.M
 		return nil, err
 	}
 
-	return &ma, nil
+	return ma, nil
+}
+
+// GetByHash returns the asset of a user with the given content hash, nil
+// when the user has none.
+func (r *mediaAssetRepository) GetByHash(ctx context.Context, userID int64, hash string) (*models.MediaAsset, error) {
+	query := `SELECT ` + mediaAssetColumns + ` FROM media_assets WHERE user_id = $1 AND content_hash = $2`
+
+	ma, err := scanMediaAsset(r.db.QueryRowContext(ctx, query, userID, hash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		slog.Info(err.Error())
+		return nil, err
+	}
+
+	return ma, nil
+}
+
+// List returns the filtered media assets of a user, newest first, along
+// with the total number of matching assets.
+func (r *mediaAssetRepository) List(ctx context.Context, userID int64, filter *models.MediaFilter) ([]*models.MediaAsset, int, error) {
+	conditions := []string{"user_id = $1"}
+	args := []interface{}{userID}
+
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Type != "" {
+		addCondition("file_type LIKE $%d", filter.Type+"/%")
+	}
+	if filter.MinSize > 0 {
+		addCondition("file_size >= $%d", filter.MinSize)
+	}
+	if filter.MaxSize > 0 {
+		addCondition("file_size <= $%d", filter.MaxSize)
+	}
+	if !filter.From.IsZero() {
+		addCondition("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("created_at < $%d", filter.To)
+	}
+
+	from := ` FROM media_assets WHERE ` + strings.Join(conditions, " AND ")
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*)"+from, args...).Scan(&total); err != nil {
+		slog.Info(err.Error())
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + mediaAssetColumns + from +
+		fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+
+	rows, err := r.db.QueryContext(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		slog.Info(err.Error())
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var assets []*models.MediaAsset
+	for rows.Next() {
+		ma, err := scanMediaAsset(rows)
+		if err != nil {
+			slog.Info(err.Error())
+			return nil, 0, err
+		}
+		assets = append(assets, ma)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Info(err.Error())
+		return nil, 0, err
+	}
+
+	return assets, total, nil
+}
+
+func (r *mediaAssetRepository) CheckByUserID(ctx context.Context, id, userID int64) (bool, error) {
+	query := "SELECT 1 FROM media_assets WHERE id = $1 AND user_id = $2"
+
+	var result int
+	err := r.db.QueryRowContext(ctx, query, id, userID).Scan(&result)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		slog.Info(err.Error())
+		return false, err
+	}
+
+	return result == 1, nil
 }
 
-func (r *mediaAssetRepository) Remove(ctx context.Context, id int64) error {
+// CountUsage returns how many posts use the asset.
+func (r *mediaAssetRepository) CountUsage(ctx context.Context, id int64) (int, error) {
+	query := `SELECT COUNT(DISTINCT post_id) FROM post_media WHERE asset_id = $1`
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&count); err != nil {
+		slog.Info(err.Error())
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *mediaAssetRepository) UpdateThumbnail(ctx context.Context, id int64, thumbnailURL string) error {
+	query := `UPDATE media_assets SET thumbnail_url = NULLIF($2, '') WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id, thumbnailURL)
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *mediaAssetRepository) UpdateCover(ctx context.Context, id int64, coverTimeMs int) error {
+	query := `UPDATE media_assets SET cover_time_ms = $2 WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id, coverTimeMs)
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
+
+// Remove deletes an asset unless a post uses it, and tells whether it did.
+// Its variants and renditions go with it.
+func (r *mediaAssetRepository) Remove(ctx context.Context, id int64) (bool, error) {
 	query := `
-		DELETE FROM post_media
-		WHERE id = $1
+		DELETE FROM media_assets
+		WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM post_media WHERE asset_id = $1)
 	`
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		slog.Info(err.Error())
+		return false, err
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		slog.Info(err.Error())
+		return false, err
+	}
+	return removed > 0, nil
+}
+
+// AddToLibrary keeps an asset that was uploaded with a post once no post
+// uses it anymore.
+func (r *mediaAssetRepository) AddToLibrary(ctx context.Context, id int64) error {
+	query := `UPDATE media_assets SET library = true WHERE id = $1`
+
 	_, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
 		slog.Info(err.Error())
@@ -84,3 +273,95 @@ func (r *mediaAssetRepository) Remove(ctx context.Context, id int64) error {
 	}
 	return nil
 }
+
+// orphanCondition matches the assets that were only uploaded with posts and
+// that no post used since the time of the numbered parameter.
+const orphanCondition = `NOT library
+	AND NOT EXISTS (SELECT 1 FROM post_media WHERE post_media.asset_id = media_assets.id)
+	AND COALESCE(released_at, created_at) < $%d`
+
+// ListOrphans returns the assets that no post or library uses anymore, and
+// that were released before the given time.
+func (r *mediaAssetRepository) ListOrphans(ctx context.Context, before time.Time) ([]*models.MediaAsset, error) {
+	query := `SELECT ` + mediaAssetColumns + ` FROM media_assets WHERE ` +
+		fmt.Sprintf(orphanCondition, 1) + ` ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query, before.UTC())
+	if err != nil {
+		slog.Info(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assets []*models.MediaAsset
+	for rows.Next() {
+		ma, err := scanMediaAsset(rows)
+		if err != nil {
+			slog.Info(err.Error())
+			return nil, err
+		}
+		assets = append(assets, ma)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Info(err.Error())
+		return nil, err
+	}
+	return assets, nil
+}
+
+// RemoveOrphan deletes an asset listed by ListOrphans, unless a post started
+// using it since. It reports whether the asset was deleted.
+func (r *mediaAssetRepository) RemoveOrphan(ctx context.Context, id int64, before time.Time) (bool, error) {
+	query := `DELETE FROM media_assets WHERE id = $1 AND ` + fmt.Sprintf(orphanCondition, 2)
+
+	result, err := r.db.ExecContext(ctx, query, id, before.UTC())
+	if err != nil {
+		slog.Info(err.Error())
+		return false, err
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		slog.Info(err.Error())
+		return false, err
+	}
+	return removed > 0, nil
+}
+
+// ListFileReferences returns what the media tables refer to files in
+// storage by: the keys of assets, variants, renditions and uploads, and the
+// URLs of thumbnails.
+func (r *mediaAssetRepository) ListFileReferences(ctx context.Context) ([]string, error) {
+	query := `
+		SELECT file_name FROM media_assets
+		UNION ALL SELECT thumbnail_url FROM media_assets WHERE thumbnail_url IS NOT NULL
+		UNION ALL SELECT file_key FROM media_variants
+		UNION ALL SELECT file_key FROM media_renditions WHERE file_key IS NOT NULL
+		UNION ALL SELECT file_key FROM media_uploads
+		UNION ALL SELECT file_key FROM resumable_uploads
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		slog.Info(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var references []string
+	for rows.Next() {
+		var reference string
+		if err := rows.Scan(&reference); err != nil {
+			slog.Info(err.Error())
+			return nil, err
+		}
+		references = append(references, reference)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Info(err.Error())
+		return nil, err
+	}
+	return references, nil
+}
@@ -1 +1,28 @@
 package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/maheshrc27/scheduling-api/internal/models"
+)
+
+// marshalOptions encodes publish options for a jsonb column, unset options
+// are stored as NULL.
+func marshalOptions(options models.PublishOptions) (sql.NullString, error) {
+	if options == (models.PublishOptions{}) {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(options)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+func unmarshalOptions(data []byte, options *models.PublishOptions) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, options)
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/maheshrc27/scheduling-api/internal/models"
+)
+
+type MediaRenditionRepository interface {
+	Create(ctx context.Context, mr *models.MediaRendition) error
+	Get(ctx context.Context, assetID int64, platform string) (*models.MediaRendition, error)
+	ListByAssetID(ctx context.Context, assetID int64) ([]*models.MediaRendition, error)
+	Update(ctx context.Context, mr *models.MediaRendition) error
+	UpdateProgress(ctx context.Context, id int64, progress int) error
+	RemoveByAssetID(ctx context.Context, assetID int64) error
+}
+
+type mediaRenditionRepository struct {
+	db *sql.DB
+}
+
+func NewMediaRenditionRepository(db *sql.DB) MediaRenditionRepository {
+	return &mediaRenditionRepository{db: db}
+}
+
+const mediaRenditionColumns = `id, asset_id, platform, status, progress, COALESCE(file_key, ''),
+	COALESCE(file_url, ''), COALESCE(file_size, 0), COALESCE(width, 0), COALESCE(height, 0),
+	COALESCE(duration, 0), COALESCE(error_message, ''), created_at, updated_at`
+
+func scanMediaRendition(row interface{ Scan(dest ...any) error }) (*models.MediaRendition, error) {
+	var mr models.MediaRendition
+	err := row.Scan(
+		&mr.ID,
+		&mr.AssetID,
+		&mr.Platform,
+		&mr.Status,
+		&mr.Progress,
+		&mr.FileKey,
+		&mr.FileURL,
+		&mr.FileSize,
+		&mr.Width,
+		&mr.Height,
+		&mr.Duration,
+		&mr.ErrorMessage,
+		&mr.CreatedAt,
+		&mr.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &mr, nil
+}
+
+// Create saves a pending rendition, unless the asset already has one for
+// the platform.
+func (r *mediaRenditionRepository) Create(ctx context.Context, mr *models.MediaRendition) error {
+	query := `
+		INSERT INTO media_renditions (asset_id, platform, status)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (asset_id, platform) DO NOTHING
+	`
+
+	_, err := r.db.ExecContext(ctx, query, mr.AssetID, mr.Platform, mr.Status)
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *mediaRenditionRepository) Get(ctx context.Context, assetID int64, platform string) (*models.MediaRendition, error) {
+	query := `SELECT ` + mediaRenditionColumns + ` FROM media_renditions WHERE asset_id = $1 AND platform = $2`
+
+	mr, err := scanMediaRendition(r.db.QueryRowContext(ctx, query, assetID, platform))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		slog.Info(err.Error())
+		return nil, err
+	}
+	return mr, nil
+}
+
+func (r *mediaRenditionRepository) ListByAssetID(ctx context.Context, assetID int64) ([]*models.MediaRendition, error) {
+	query := `SELECT ` + mediaRenditionColumns + ` FROM media_renditions WHERE asset_id = $1 ORDER BY platform`
+
+	rows, err := r.db.QueryContext(ctx, query, assetID)
+	if err != nil {
+		slog.Info(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var renditions []*models.MediaRendition
+	for rows.Next() {
+		mr, err := scanMediaRendition(rows)
+		if err != nil {
+			slog.Info(err.Error())
+			return nil, err
+		}
+		renditions = append(renditions, mr)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Info(err.Error())
+		return nil, err
+	}
+	return renditions, nil
+}
+
+func (r *mediaRenditionRepository) Update(ctx context.Context, mr *models.MediaRendition) error {
+	query := `
+		UPDATE media_renditions
+		SET status = $2, progress = $3, file_key = NULLIF($4, ''), file_url = NULLIF($5, ''),
+			file_size = NULLIF($6, 0), width = NULLIF($7, 0), height = NULLIF($8, 0),
+			duration = NULLIF($9, 0), error_message = NULLIF($10, ''), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+
+	_, err := r.db.ExecContext(ctx, query, mr.ID, mr.Status, mr.Progress, mr.FileKey, mr.FileURL,
+		mr.FileSize, mr.Width, mr.Height, mr.Duration, mr.ErrorMessage)
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *mediaRenditionRepository) UpdateProgress(ctx context.Context, id int64, progress int) error {
+	query := `UPDATE media_renditions SET progress = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id, progress)
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *mediaRenditionRepository) RemoveByAssetID(ctx context.Context, assetID int64) error {
+	query := `
+		DELETE FROM media_renditions
+		WHERE asset_id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, assetID)
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
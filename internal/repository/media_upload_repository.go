@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/maheshrc27/scheduling-api/internal/models"
+)
+
+type MediaUploadRepository interface {
+	Create(ctx context.Context, mu *models.MediaUpload) (int64, error)
+	GetByID(ctx context.Context, id int64) (*models.MediaUpload, error)
+	ListExpired(ctx context.Context, before time.Time) ([]*models.MediaUpload, error)
+	Remove(ctx context.Context, id int64) error
+}
+
+type mediaUploadRepository struct {
+	db *sql.DB
+}
+
+func NewMediaUploadRepository(db *sql.DB) MediaUploadRepository {
+	return &mediaUploadRepository{db: db}
+}
+
+func (r *mediaUploadRepository) Create(ctx context.Context, mu *models.MediaUpload) (int64, error) {
+	query := `
+		INSERT INTO media_uploads (user_id, file_key, file_name, file_type, file_size, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+
+	var id int64
+	err := r.db.QueryRowContext(ctx, query, mu.UserID, mu.FileKey, mu.FileName, mu.FileType, mu.FileSize, mu.ExpiresAt.UTC()).Scan(&id)
+	if err != nil {
+		slog.Info(err.Error())
+		return 0, err
+	}
+
+	return id, nil
+}
+
+const mediaUploadColumns = `id, user_id, file_key, COALESCE(file_name, ''), file_type, file_size, expires_at, created_at`
+
+func scanMediaUpload(row interface{ Scan(dest ...any) error }) (*models.MediaUpload, error) {
+	var mu models.MediaUpload
+	err := row.Scan(
+		&mu.ID,
+		&mu.UserID,
+		&mu.FileKey,
+		&mu.FileName,
+		&mu.FileType,
+		&mu.FileSize,
+		&mu.ExpiresAt,
+		&mu.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	mu.ExpiresAt = mu.ExpiresAt.UTC()
+	return &mu, nil
+}
+
+func (r *mediaUploadRepository) GetByID(ctx context.Context, id int64) (*models.MediaUpload, error) {
+	query := `SELECT ` + mediaUploadColumns + ` FROM media_uploads WHERE id = $1`
+
+	mu, err := scanMediaUpload(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		slog.Info(err.Error())
+		return nil, err
+	}
+	return mu, nil
+}
+
+// ListExpired returns the uploads that expired before a time without being
+// completed.
+func (r *mediaUploadRepository) ListExpired(ctx context.Context, before time.Time) ([]*models.MediaUpload, error) {
+	query := `SELECT ` + mediaUploadColumns + ` FROM media_uploads WHERE expires_at < $1 ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query, before.UTC())
+	if err != nil {
+		slog.Info(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var uploads []*models.MediaUpload
+	for rows.Next() {
+		mu, err := scanMediaUpload(rows)
+		if err != nil {
+			slog.Info(err.Error())
+			return nil, err
+		}
+		uploads = append(uploads, mu)
+	}
+	if err := rows.Err(); err != nil {
+		slog.Info(err.Error())
+		return nil, err
+	}
+	return uploads, nil
+}
+
+func (r *mediaUploadRepository) Remove(ctx context.Context, id int64) error {
+	query := `
+		DELETE FROM media_uploads
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/maheshrc27/scheduling-api/internal/models"
+)
+
+type MediaVariantRepository interface {
+	Create(ctx context.Context, mv *models.MediaVariant) error
+	Get(ctx context.Context, assetID int64, name string) (*models.MediaVariant, error)
+	ListByAssetID(ctx context.Context, assetID int64) ([]*models.MediaVariant, error)
+	RemoveByAssetID(ctx context.Context, assetID int64) error
+}
+
+type mediaVariantRepository struct {
+	db *sql.DB
+}
+
+func NewMediaVariantRepository(db *sql.DB) MediaVariantRepository {
+	return &mediaVariantRepository{db: db}
+}
+
+const mediaVariantColumns = `id, asset_id, name, file_key, file_url, file_size, width, height, created_at`
+
+func scanMediaVariant(row interface{ Scan(dest ...any) error }) (*models.MediaVariant, error) {
+	var mv models.MediaVariant
+	err := row.Scan(
+		&mv.ID,
+		&mv.AssetID,
+		&mv.Name,
+		&mv.FileKey,
+		&mv.FileURL,
+		&mv.FileSize,
+		&mv.Width,
+		&mv.Height,
+		&mv.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &mv, nil
+}
+
+// Create saves a variant, unless the asset already has one of that name.
+func (r *mediaVariantRepository) Create(ctx context.Context, mv *models.MediaVariant) error {
+	query := `
+		INSERT INTO media_variants (asset_id, name, file_key, file_url, file_size, width, height)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (asset_id, name) DO NOTHING
+	`
+
+	_, err := r.db.ExecContext(ctx, query, mv.AssetID, mv.Name, mv.FileKey, mv.FileURL, mv.FileSize, mv.Width, mv.Height)
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *mediaVariantRepository) Get(ctx context.Context, assetID int64, name string) (*models.MediaVariant, error) {
+	query := `SELECT ` + mediaVariantColumns + ` FROM media_variants WHERE asset_id = $1 AND name = $2`
+
+	mv, err := scanMediaVariant(r.db.QueryRowContext(ctx, query, assetID, name))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		slog.Info(err.Error())
+		return nil, err
+	}
+	return mv, nil
+}
+
+func (r *mediaVariantRepository) ListByAssetID(ctx context.Context, assetID int64) ([]*models.MediaVariant, error) {
+	query := `SELECT ` + mediaVariantColumns + ` FROM media_variants WHERE asset_id = $1 ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query, assetID)
+	if err != nil {
+		slog.Info(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var variants []*models.MediaVariant
+	for rows.Next() {
+		mv, err := scanMediaVariant(rows)
+		if err != nil {
+			slog.Info(err.Error())
+			return nil, err
+		}
+		variants = append(variants, mv)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Info(err.Error())
+		return nil, err
+	}
+	return variants, nil
+}
+
+func (r *mediaVariantRepository) RemoveByAssetID(ctx context.Context, assetID int64) error {
+	query := `
+		DELETE FROM media_variants
+		WHERE asset_id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, assetID)
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/lib/pq"
+	"github.com/maheshrc27/scheduling-api/internal/models"
+)
+
+type PlatformOverrideRepository interface {
+	Create(ctx context.Context, tx *sql.Tx, po *models.PlatformOverride) error
+	ListByPostID(ctx context.Context, postID int64) ([]*models.PlatformOverride, error)
+	RemoveByPostID(ctx context.Context, tx *sql.Tx, postID int64) error
+}
+
+type platformOverrideRepository struct {
+	db *sql.DB
+}
+
+func NewPlatformOverrideRepository(db *sql.DB) PlatformOverrideRepository {
+	return &platformOverrideRepository{db: db}
+}
+
+func (r *platformOverrideRepository) Create(ctx context.Context, tx *sql.Tx, po *models.PlatformOverride) error {
+	options, err := marshalOptions(po.Options)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO platform_overrides (post_id, platform, caption, title, hashtags, options)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5, $6)
+	`
+
+	args := []any{po.PostID, po.Platform, po.Caption, po.Title, pq.Array(po.Hashtags), options}
+	if tx != nil {
+		_, err = tx.ExecContext(ctx, query, args...)
+	} else {
+		_, err = r.db.ExecContext(ctx, query, args...)
+	}
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *platformOverrideRepository) ListByPostID(ctx context.Context, postID int64) ([]*models.PlatformOverride, error) {
+	query := `
+		SELECT post_id, platform, COALESCE(caption, ''), COALESCE(title, ''), hashtags, options
+		FROM platform_overrides
+		WHERE post_id = $1
+		ORDER BY platform
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, postID)
+	if err != nil {
+		slog.Info(err.Error())
+		return nil, fmt.Errorf("query rows: %w", err)
+	}
+	defer rows.Close()
+
+	var overrides []*models.PlatformOverride
+	for rows.Next() {
+		var po models.PlatformOverride
+		var options []byte
+		if err := rows.Scan(&po.PostID, &po.Platform, &po.Caption, &po.Title, pq.Array(&po.Hashtags), &options); err != nil {
+			slog.Info(err.Error())
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		if err := unmarshalOptions(options, &po.Options); err != nil {
+			slog.Info(err.Error())
+			return nil, fmt.Errorf("decode options: %w", err)
+		}
+		overrides = append(overrides, &po)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Info(err.Error())
+		return nil, fmt.Errorf("rows iteration: %w", err)
+	}
+
+	return overrides, nil
+}
+
+func (r *platformOverrideRepository) RemoveByPostID(ctx context.Context, tx *sql.Tx, postID int64) error {
+	query := `DELETE FROM platform_overrides WHERE post_id = $1`
+
+	var err error
+	if tx != nil {
+		_, err = tx.ExecContext(ctx, query, postID)
+	} else {
+		_, err = r.db.ExecContext(ctx, query, postID)
+	}
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
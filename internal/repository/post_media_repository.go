@@ -13,7 +13,7 @@ type PostMediaRepository interface {
 	Create(ctx context.Context, tx *sql.Tx, pm *models.PostMedia) error
 	GetByPostID(ctx context.Context, postID int64) (*models.PostMedia, error)
 	ListByPostID(ctx context.Context, postID int64) ([]*models.PostMedia, error)
-	Update(ctx context.Context, pm *models.PostMedia) error
+	Update(ctx context.Context, tx *sql.Tx, pm *models.PostMedia) error
 	Remove(ctx context.Context, postID int64) error
 }
 
@@ -126,21 +126,20 @@ func (r *postMediaRepository) Remove(ctx context.Context, id int64) error {
 	return nil
 }
 
-func (r *postMediaRepository) Update(ctx context.Context, pm *models.PostMedia) error {
-	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
-	if err != nil {
-		slog.Info(err.Error())
-		return err
-	}
-	defer tx.Rollback()
+func (r *postMediaRepository) Update(ctx context.Context, tx *sql.Tx, pm *models.PostMedia) error {
+	var result sql.Result
+	var err error
 
 	query := `
 		UPDATE post_media
 		SET display_order = $1
 		WHERE post_id = $2 AND asset_id = $3
 	`
-
-	result, err := tx.ExecContext(ctx, query, pm.DisplayOrder, pm.PostID, pm.AssetID)
+	if tx != nil {
+		result, err = tx.ExecContext(ctx, query, pm.DisplayOrder, pm.PostID, pm.AssetID)
+	} else {
+		result, err = r.db.ExecContext(ctx, query, pm.DisplayOrder, pm.PostID, pm.AssetID)
+	}
 	if err != nil {
 		slog.Info(err.Error())
 		return err
@@ -155,10 +154,5 @@ func (r *postMediaRepository) Update(ctx context.Context, pm *models.PostMedia)
 		return errors.New("no rows affected")
 	}
 
-	if err = tx.Commit(); err != nil {
-		slog.Info(err.Error())
-		return err
-	}
-
 	return nil
 }
@@ -13,7 +13,13 @@ type PostRepository interface {
 	GetByID(ctx context.Context, id int64) (*models.Post, error)
 	Create(ctx context.Context, tx *sql.Tx, post *models.Post) (int64, error)
 	GetByUserID(ctx context.Context, userID int64) ([]*models.Post, error)
+	GetDraftsByUserID(ctx context.Context, userID int64) ([]*models.Post, error)
+	GetByAssetID(ctx context.Context, assetID int64) ([]*models.Post, error)
+	GetQueuedByUserID(ctx context.Context, userID int64) ([]*models.Post, error)
+	GetNextOccurrence(ctx context.Context, seriesID int64, occurrence int) (*models.Post, error)
+	Update(ctx context.Context, tx *sql.Tx, post *models.Post) error
 	UpdatePostStatus(ctx context.Context, status string, postID int64) error
+	SetTaskID(ctx context.Context, postID int64, taskID string) error
 	CheckByUserID(ctx context.Context, accountID, userID int64) (bool, error)
 	Remove(ctx context.Context, id int64) error
 	CountCurrentMonth(ctx context.Context, userID int64) (int, error)
@@ -27,20 +33,46 @@ func NewPostRepository(db *sql.DB) PostRepository {
 	return &postRepository{db: db}
 }
 
+const postColumns = `id, user_id, post_type, caption, title, scheduled_time, status, COALESCE(task_id, ''), COALESCE(recurrence, ''), COALESCE(series_id, 0), series_start, COALESCE(occurrence, 1), COALESCE(queued, false), COALESCE(category, ''), COALESCE(timezone, ''), created_at, updated_at`
+
+func scanPost(row interface{ Scan(dest ...any) error }) (*models.Post, error) {
+	var post models.Post
+	var scheduledTime, seriesStart sql.NullTime
+	err := row.Scan(&post.ID, &post.UserID, &post.PostType, &post.Caption, &post.Title, &scheduledTime, &post.Status, &post.TaskID, &post.Recurrence, &post.SeriesID, &seriesStart, &post.Occurrence, &post.Queued, &post.Category, &post.Timezone, &post.CreatedAt, &post.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	// Times are stored in UTC
+	if scheduledTime.Valid {
+		post.ScheduledTime = scheduledTime.Time.UTC()
+	}
+	if seriesStart.Valid {
+		post.SeriesStart = seriesStart.Time.UTC()
+	}
+	return &post, nil
+}
+
+// nullTime stores the zero time as NULL, drafts may not have a time yet.
+// Times are converted to UTC as the columns don't keep the zone.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
 func (r *postRepository) Create(ctx context.Context, tx *sql.Tx, post *models.Post) (int64, error) {
 	query := `
-		INSERT INTO posts (user_id, post_type, caption, title, scheduled_time)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO posts (user_id, post_type, caption, title, scheduled_time, status, recurrence, series_id, series_start, occurrence, queued, category, timezone)
+		VALUES ($1, $2, $3, $4, $5, COALESCE(NULLIF($6, ''), 'scheduled'), NULLIF($7, ''), NULLIF($8, 0), $9, GREATEST($10, 1), $11, NULLIF($12, ''), NULLIF($13, ''))
 		RETURNING id
 	`
 
 	var id int64
 	var err error
 
+	args := []any{post.UserID, post.PostType, post.Caption, post.Title, nullTime(post.ScheduledTime), post.Status, post.Recurrence, post.SeriesID, nullTime(post.SeriesStart), post.Occurrence, post.Queued, post.Category, post.Timezone}
 	if tx != nil {
-		err = tx.QueryRowContext(ctx, query, post.UserID, post.PostType, post.Caption, post.Title, post.ScheduledTime).Scan(&id)
+		err = tx.QueryRowContext(ctx, query, args...).Scan(&id)
 	} else {
-		err = r.db.QueryRowContext(ctx, query, post.UserID, post.PostType, post.Caption, post.Title, post.ScheduledTime).Scan(&id)
+		err = r.db.QueryRowContext(ctx, query, args...).Scan(&id)
 	}
 	if err != nil {
 		slog.Info(err.Error())
@@ -51,11 +83,8 @@ func (r *postRepository) Create(ctx context.Context, tx *sql.Tx, post *models.Po
 }
 
 func (r *postRepository) GetByID(ctx context.Context, id int64) (*models.Post, error) {
-	query := `SELECT id, user_id, post_type, caption, title, scheduled_time, status, created_at, updated_at FROM posts WHERE id = $1`
-	row := r.db.QueryRowContext(ctx, query, id)
-
-	var post models.Post
-	err := row.Scan(&post.ID, &post.UserID, &post.PostType, &post.Caption, &post.Title, &post.ScheduledTime, &post.Status, &post.CreatedAt, &post.UpdatedAt)
+	query := `SELECT ` + postColumns + ` FROM posts WHERE id = $1`
+	post, err := scanPost(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -64,15 +93,52 @@ func (r *postRepository) GetByID(ctx context.Context, id int64) (*models.Post, e
 		return nil, err
 	}
 
-	return &post, nil
+	return post, nil
 }
 
 func (r *postRepository) GetByUserID(ctx context.Context, userID int64) ([]*models.Post, error) {
-	query := `SELECT id, user_id, post_type, caption, title, scheduled_time, status, created_at, updated_at FROM posts WHERE user_id = $1`
-	var rows *sql.Rows
-	var err error
+	query := `SELECT ` + postColumns + ` FROM posts WHERE user_id = $1 AND status <> $2`
+	return r.listPosts(ctx, query, userID, models.PostStatusDraft)
+}
+
+func (r *postRepository) GetDraftsByUserID(ctx context.Context, userID int64) ([]*models.Post, error) {
+	query := `SELECT ` + postColumns + ` FROM posts WHERE user_id = $1 AND status = $2 ORDER BY updated_at DESC`
+	return r.listPosts(ctx, query, userID, models.PostStatusDraft)
+}
+
+// GetByAssetID returns the posts that use a media asset, newest first.
+func (r *postRepository) GetByAssetID(ctx context.Context, assetID int64) ([]*models.Post, error) {
+	query := `SELECT ` + postColumns + ` FROM posts
+		WHERE id IN (SELECT post_id FROM post_media WHERE asset_id = $1)
+		ORDER BY created_at DESC`
+	return r.listPosts(ctx, query, assetID)
+}
+
+// GetQueuedByUserID returns the scheduled posts of the user that were placed
+// in a posting slot, soonest first.
+func (r *postRepository) GetQueuedByUserID(ctx context.Context, userID int64) ([]*models.Post, error) {
+	query := `SELECT ` + postColumns + ` FROM posts WHERE user_id = $1 AND status = $2 AND queued ORDER BY scheduled_time`
+	return r.listPosts(ctx, query, userID, models.PostStatusScheduled)
+}
 
-	rows, err = r.db.QueryContext(ctx, query, userID)
+// GetNextOccurrence returns the first post of a recurring series that comes
+// after the given occurrence, or nil if there is none yet.
+func (r *postRepository) GetNextOccurrence(ctx context.Context, seriesID int64, occurrence int) (*models.Post, error) {
+	query := `SELECT ` + postColumns + ` FROM posts WHERE series_id = $1 AND occurrence > $2 ORDER BY occurrence LIMIT 1`
+	post, err := scanPost(r.db.QueryRowContext(ctx, query, seriesID, occurrence))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		slog.Info(err.Error())
+		return nil, err
+	}
+
+	return post, nil
+}
+
+func (r *postRepository) listPosts(ctx context.Context, query string, args ...any) ([]*models.Post, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		slog.Info(err.Error())
 		return nil, err
@@ -81,15 +147,14 @@ func (r *postRepository) GetByUserID(ctx context.Context, userID int64) ([]*mode
 
 	var posts []*models.Post
 	for rows.Next() {
-		var post models.Post
-		err := rows.Scan(&post.ID, &post.UserID, &post.PostType, &post.Caption, &post.Title, &post.ScheduledTime, &post.Status, &post.CreatedAt, &post.UpdatedAt)
+		post, err := scanPost(rows)
 		if err != nil {
 			slog.Info(err.Error())
 			return nil, err
 		}
-		posts = append(posts, &post)
+		posts = append(posts, post)
 	}
-	return posts, nil
+	return posts, rows.Err()
 }
 
 func (r *postRepository) CheckByUserID(ctx context.Context, accountID, userID int64) (bool, error) {
@@ -109,28 +174,38 @@ func (r *postRepository) CheckByUserID(ctx context.Context, accountID, userID in
 }
 
 func (r *postRepository) GetScheduled(ctx context.Context, userID int64) ([]*models.Post, error) {
-	query := `SELECT id, user_id, post_type, caption, title, scheduled_time, status, created_at, updated_at FROM posts WHERE user_id=$1 AND status = $2`
-	var rows *sql.Rows
-	var err error
+	query := `SELECT ` + postColumns + ` FROM posts WHERE user_id=$1 AND status = $2`
+	return r.listPosts(ctx, query, userID, models.PostStatusScheduled)
+}
+
+func (r *postRepository) Update(ctx context.Context, tx *sql.Tx, post *models.Post) error {
+	query := `
+		UPDATE posts
+		SET caption = $1,
+			title = $2,
+			scheduled_time = $3,
+			post_type = $4,
+			status = $5,
+			series_start = $6,
+			queued = $7,
+			category = NULLIF($8, ''),
+			timezone = NULLIF($9, ''),
+			updated_at = $10
+		WHERE id = $11
+	`
 
-	rows, err = r.db.QueryContext(ctx, query, userID, "scheduled")
+	var err error
+	args := []any{post.Caption, post.Title, nullTime(post.ScheduledTime), post.PostType, post.Status, nullTime(post.SeriesStart), post.Queued, post.Category, post.Timezone, time.Now(), post.ID}
+	if tx != nil {
+		_, err = tx.ExecContext(ctx, query, args...)
+	} else {
+		_, err = r.db.ExecContext(ctx, query, args...)
+	}
 	if err != nil {
 		slog.Info(err.Error())
-		return nil, err
-	}
-
-	var posts []*models.Post
-	for rows.Next() {
-		var post models.Post
-		err := rows.Scan(&post.ID, &post.UserID, &post.PostType, &post.Caption, &post.Title, &post.ScheduledTime, &post.Status, &post.CreatedAt, &post.UpdatedAt)
-		if err != nil {
-			slog.Info(err.Error())
-			return nil, err
-		}
-		posts = append(posts, &post)
+		return err
 	}
-	return posts, nil
-
+	return nil
 }
 
 func (r *postRepository) UpdatePostStatus(ctx context.Context, status string, postID int64) error {
@@ -148,8 +223,26 @@ func (r *postRepository) UpdatePostStatus(ctx context.Context, status string, po
 	return nil
 }
 
+func (r *postRepository) SetTaskID(ctx context.Context, postID int64, taskID string) error {
+	query := `UPDATE posts SET task_id = NULLIF($1, '') WHERE id = $2`
+	_, err := r.db.ExecContext(ctx, query, taskID, postID)
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
+
+// Remove deletes a post, its media are removed along with it and release
+// their assets, which are kept for the other posts that use them.
 func (r *postRepository) Remove(ctx context.Context, id int64) error {
-	query := `DELETE FROM posts WHERE id = $1`
+	query := `
+		WITH released AS (
+			UPDATE media_assets SET released_at = CURRENT_TIMESTAMP
+			WHERE id IN (SELECT asset_id FROM post_media WHERE post_id = $1)
+		)
+		DELETE FROM posts WHERE id = $1
+	`
 	_, err := r.db.ExecContext(ctx, query, id)
 
 	if err != nil {
@@ -3,7 +3,9 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"log/slog"
+	"strings"
 
 	"github.com/maheshrc27/scheduling-api/internal/models"
 )
@@ -12,6 +14,7 @@ type PostingHistoryRepository interface {
 	GetByID(ctx context.Context, id int64) (*models.PostingHistory, error)
 	Create(ctx context.Context, ph *models.PostingHistory) (int64, error)
 	GetByUserID(ctx context.Context, userID int64) ([]*models.PostingHistory, error)
+	List(ctx context.Context, userID int64, filter *models.HistoryFilter) ([]*models.PostingHistory, int, error)
 }
 
 type postingHistoryRepository struct {
@@ -24,13 +27,13 @@ func NewPostingHistoryRepository(db *sql.DB) PostingHistoryRepository {
 
 func (r *postingHistoryRepository) Create(ctx context.Context, ph *models.PostingHistory) (int64, error) {
 	query := `
-		INSERT INTO posting_history (user_id, post_id, account_id, error_message)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO posting_history (user_id, post_id, account_id, attempt, error_message)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id
 	`
 
 	var id int64
-	err := r.db.QueryRowContext(ctx, query, ph.UserID, ph.PostID, ph.AccountID, ph.ErrorMessage).Scan(&id)
+	err := r.db.QueryRowContext(ctx, query, ph.UserID, ph.PostID, ph.AccountID, ph.Attempt, ph.ErrorMessage).Scan(&id)
 	if err != nil {
 		slog.Info(err.Error())
 		return 0, err
@@ -40,11 +43,11 @@ func (r *postingHistoryRepository) Create(ctx context.Context, ph *models.Postin
 }
 
 func (r *postingHistoryRepository) GetByID(ctx context.Context, id int64) (*models.PostingHistory, error) {
-	query := `SELECT * FROM posting_history WHERE id = ?`
+	query := `SELECT id, user_id, post_id, account_id, COALESCE(attempt, 1), COALESCE(error_message, ''), created_at FROM posting_history WHERE id = $1`
 	row := r.db.QueryRowContext(ctx, query, id)
 
 	var ph models.PostingHistory
-	err := row.Scan(&ph.ID, &ph.UserID, &ph.PostID, &ph.AccountID, &ph.ErrorMessage, &ph.CreatedAt)
+	err := row.Scan(&ph.ID, &ph.UserID, &ph.PostID, &ph.AccountID, &ph.Attempt, &ph.ErrorMessage, &ph.CreatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -57,7 +60,7 @@ func (r *postingHistoryRepository) GetByID(ctx context.Context, id int64) (*mode
 }
 
 func (r *postingHistoryRepository) GetByUserID(ctx context.Context, userID int64) ([]*models.PostingHistory, error) {
-	query := `SELECT * FROM posting_history WHERE user_id = $1`
+	query := `SELECT id, user_id, post_id, account_id, COALESCE(attempt, 1), COALESCE(error_message, ''), created_at FROM posting_history WHERE user_id = $1`
 	var rows *sql.Rows
 	var err error
 
@@ -71,7 +74,7 @@ func (r *postingHistoryRepository) GetByUserID(ctx context.Context, userID int64
 	var phs []*models.PostingHistory
 	for rows.Next() {
 		var ph models.PostingHistory
-		err := rows.Scan(&ph.ID, &ph.UserID, &ph.PostID, &ph.AccountID, &ph.ErrorMessage, &ph.CreatedAt)
+		err := rows.Scan(&ph.ID, &ph.UserID, &ph.PostID, &ph.AccountID, &ph.Attempt, &ph.ErrorMessage, &ph.CreatedAt)
 		if err != nil {
 			slog.Info(err.Error())
 			return nil, err
@@ -80,3 +83,79 @@ func (r *postingHistoryRepository) GetByUserID(ctx context.Context, userID int64
 	}
 	return phs, nil
 }
+
+// List returns the filtered history of a user, newest first, along with the
+// total number of matching entries.
+func (r *postingHistoryRepository) List(ctx context.Context, userID int64, filter *models.HistoryFilter) ([]*models.PostingHistory, int, error) {
+	conditions := []string{"ph.user_id = $1"}
+	args := []interface{}{userID}
+
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.PostID != 0 {
+		addCondition("ph.post_id = $%d", filter.PostID)
+	}
+	if filter.AccountID != 0 {
+		addCondition("ph.account_id = $%d", filter.AccountID)
+	}
+	if filter.Platform != "" {
+		addCondition("a.platform = $%d", filter.Platform)
+	}
+	if filter.Succeeded != nil {
+		if *filter.Succeeded {
+			conditions = append(conditions, "COALESCE(ph.error_message, '') = ''")
+		} else {
+			conditions = append(conditions, "COALESCE(ph.error_message, '') <> ''")
+		}
+	}
+	if !filter.From.IsZero() {
+		addCondition("ph.created_at >= $%d", filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		addCondition("ph.created_at < $%d", filter.To.UTC())
+	}
+
+	from := `
+		FROM posting_history ph
+		JOIN social_accounts a ON a.id = ph.account_id
+		WHERE ` + strings.Join(conditions, " AND ")
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*)"+from, args...).Scan(&total); err != nil {
+		slog.Info(err.Error())
+		return nil, 0, err
+	}
+
+	query := `SELECT ph.id, ph.user_id, ph.post_id, ph.account_id, a.account_name, a.platform,
+		COALESCE(ph.attempt, 1), COALESCE(ph.error_message, ''), ph.created_at` + from +
+		fmt.Sprintf(" ORDER BY ph.created_at DESC, ph.id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+
+	rows, err := r.db.QueryContext(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		slog.Info(err.Error())
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var phs []*models.PostingHistory
+	for rows.Next() {
+		var ph models.PostingHistory
+		err := rows.Scan(&ph.ID, &ph.UserID, &ph.PostID, &ph.AccountID, &ph.AccountName, &ph.Platform,
+			&ph.Attempt, &ph.ErrorMessage, &ph.CreatedAt)
+		if err != nil {
+			slog.Info(err.Error())
+			return nil, 0, err
+		}
+		phs = append(phs, &ph)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Info(err.Error())
+		return nil, 0, err
+	}
+
+	return phs, total, nil
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/maheshrc27/scheduling-api/internal/models"
+)
+
+type ResumableUploadRepository interface {
+	Create(ctx context.Context, ru *models.ResumableUpload) (int64, error)
+	GetByID(ctx context.Context, id int64) (*models.ResumableUpload, error)
+	Lock(ctx context.Context, tx *sql.Tx, id int64) (*models.ResumableUpload, error)
+	ListExpired(ctx context.Context, before time.Time) ([]*models.ResumableUpload, error)
+	Update(ctx context.Context, tx *sql.Tx, ru *models.ResumableUpload) error
+	Remove(ctx context.Context, id int64) error
+}
+
+type resumableUploadRepository struct {
+	db *sql.DB
+}
+
+func NewResumableUploadRepository(db *sql.DB) ResumableUploadRepository {
+	return &resumableUploadRepository{db: db}
+}
+
+func (r *resumableUploadRepository) Create(ctx context.Context, ru *models.ResumableUpload) (int64, error) {
+	query := `
+		INSERT INTO resumable_uploads (user_id, file_key, file_name, file_type, file_size, multipart_id, expires_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7)
+		RETURNING id
+	`
+
+	var id int64
+	err := r.db.QueryRowContext(ctx, query, ru.UserID, ru.FileKey, ru.FileName, ru.FileType, ru.FileSize, ru.MultipartID, ru.ExpiresAt.UTC()).Scan(&id)
+	if err != nil {
+		slog.Info(err.Error())
+		return 0, err
+	}
+
+	return id, nil
+}
+
+const resumableUploadColumns = `id, user_id, file_key, COALESCE(file_name, ''), COALESCE(file_type, ''), file_size, upload_offset,
+	multipart_id, parts, hash_state, COALESCE(asset_id, 0), expires_at, created_at, updated_at`
+
+func scanResumableUpload(row interface{ Scan(dest ...any) error }) (*models.ResumableUpload, error) {
+	var ru models.ResumableUpload
+	err := row.Scan(
+		&ru.ID,
+		&ru.UserID,
+		&ru.FileKey,
+		&ru.FileName,
+		&ru.FileType,
+		&ru.FileSize,
+		&ru.Offset,
+		&ru.MultipartID,
+		pq.Array(&ru.Parts),
+		&ru.HashState,
+		&ru.AssetID,
+		&ru.ExpiresAt,
+		&ru.CreatedAt,
+		&ru.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ru.ExpiresAt = ru.ExpiresAt.UTC()
+	return &ru, nil
+}
+
+func (r *resumableUploadRepository) GetByID(ctx context.Context, id int64) (*models.ResumableUpload, error) {
+	query := `SELECT ` + resumableUploadColumns + ` FROM resumable_uploads WHERE id = $1`
+
+	ru, err := scanResumableUpload(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		slog.Info(err.Error())
+		return nil, err
+	}
+	return ru, nil
+}
+
+// Lock gets an upload and keeps other requests from changing it until the
+// transaction ends.
+func (r *resumableUploadRepository) Lock(ctx context.Context, tx *sql.Tx, id int64) (*models.ResumableUpload, error) {
+	query := `SELECT ` + resumableUploadColumns + ` FROM resumable_uploads WHERE id = $1 FOR UPDATE`
+
+	ru, err := scanResumableUpload(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		slog.Info(err.Error())
+		return nil, err
+	}
+	return ru, nil
+}
+
+// ListExpired returns the uploads that expired before a time, whether they
+// were completed or not.
+func (r *resumableUploadRepository) ListExpired(ctx context.Context, before time.Time) ([]*models.ResumableUpload, error) {
+	query := `SELECT ` + resumableUploadColumns + ` FROM resumable_uploads WHERE expires_at < $1 ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query, before.UTC())
+	if err != nil {
+		slog.Info(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var uploads []*models.ResumableUpload
+	for rows.Next() {
+		ru, err := scanResumableUpload(rows)
+		if err != nil {
+			slog.Info(err.Error())
+			return nil, err
+		}
+		uploads = append(uploads, ru)
+	}
+	if err := rows.Err(); err != nil {
+		slog.Info(err.Error())
+		return nil, err
+	}
+	return uploads, nil
+}
+
+// Update saves the progress of an upload.
+func (r *resumableUploadRepository) Update(ctx context.Context, tx *sql.Tx, ru *models.ResumableUpload) error {
+	query := `
+		UPDATE resumable_uploads
+		SET file_type = NULLIF($1, ''),
+			upload_offset = $2,
+			parts = $3,
+			hash_state = $4,
+			asset_id = NULLIF($5, 0),
+			updated_at = $6
+		WHERE id = $7
+	`
+
+	var err error
+	args := []any{ru.FileType, ru.Offset, pq.Array(ru.Parts), ru.HashState, ru.AssetID, time.Now(), ru.ID}
+	if tx != nil {
+		_, err = tx.ExecContext(ctx, query, args...)
+	} else {
+		_, err = r.db.ExecContext(ctx, query, args...)
+	}
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *resumableUploadRepository) Remove(ctx context.Context, id int64) error {
+	query := `
+		DELETE FROM resumable_uploads
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
@@ -5,7 +5,9 @@ import (
 	"database/sql"
 	"fmt"
 	"log/slog"
+	"time"
 
+	"github.com/lib/pq"
 	"github.com/maheshrc27/scheduling-api/internal/models"
 )
 
@@ -14,7 +16,12 @@ type SelectedAccountRepository interface {
 	GetByID(ctx context.Context, postID, accountID int64) (*models.SelectedAccount, error)
 	ListByPostID(ctx context.Context, postID int64) ([]*models.SelectedAccount, error)
 	ListByAccountID(ctx context.Context, userID int64) ([]*models.SelectedAccount, error)
+	StartDeliveryAttempt(ctx context.Context, postID, accountID int64) (int, error)
+	UpdateDelivery(ctx context.Context, sa *models.SelectedAccount) error
+	ResetDelivery(ctx context.Context, postID, accountID int64) error
 	Remove(ctx context.Context, postID, accountID int64) error
+	RemoveByPostID(ctx context.Context, tx *sql.Tx, postID int64) error
+	SetOverride(ctx context.Context, tx *sql.Tx, postID, accountID int64, override *models.Override) error
 }
 
 type selectedAccountRepository struct {
@@ -45,11 +52,43 @@ func (r *selectedAccountRepository) Create(ctx context.Context, tx *sql.Tx, sa *
 	return nil
 }
 
+const selectedAccountColumns = `
+	sa.post_id, sa.account_id, a.platform, a.account_name,
+	COALESCE(sa.status, 'pending'), COALESCE(sa.remote_media_id, ''), COALESCE(sa.permalink, ''),
+	COALESCE(sa.error_message, ''), COALESCE(sa.attempts, 0), sa.published_at,
+	COALESCE(sa.caption_override, ''), COALESCE(sa.title_override, ''), sa.hashtags, sa.options,
+	sa.created_at, sa.updated_at
+`
+
+func scanSelectedAccount(row interface{ Scan(dest ...any) error }, sa *models.SelectedAccount) error {
+	var override models.Override
+	var options []byte
+	err := row.Scan(&sa.PostID, &sa.AccountID, &sa.Platform, &sa.AccountName,
+		&sa.Status, &sa.RemoteMediaID, &sa.Permalink,
+		&sa.ErrorMessage, &sa.Attempts, &sa.PublishedAt,
+		&override.Caption, &override.Title, pq.Array(&override.Hashtags), &options,
+		&sa.CreatedAt, &sa.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := unmarshalOptions(options, &override.Options); err != nil {
+		return err
+	}
+	if !override.IsEmpty() {
+		sa.Override = &override
+	}
+	return nil
+}
+
 func (r *selectedAccountRepository) GetByID(ctx context.Context, postID, accountID int64) (*models.SelectedAccount, error) {
-	query := "SELECT post_id, account_id FROM selected_accounts WHERE post_id = $1 AND account_id = $2"
+	query := `SELECT ` + selectedAccountColumns + `
+		FROM selected_accounts sa
+		JOIN social_accounts a ON a.id = sa.account_id
+		WHERE sa.post_id = $1 AND sa.account_id = $2`
 
 	var sa models.SelectedAccount
-	err := r.db.QueryRowContext(ctx, query, postID, accountID).Scan(&sa.PostID, &sa.AccountID, &sa.CreatedAt, &sa.UpdatedAt)
+	err := scanSelectedAccount(r.db.QueryRowContext(ctx, query, postID, accountID), &sa)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -62,7 +101,11 @@ func (r *selectedAccountRepository) GetByID(ctx context.Context, postID, account
 }
 
 func (r *selectedAccountRepository) ListByPostID(ctx context.Context, postID int64) ([]*models.SelectedAccount, error) {
-	query := "SELECT post_id, account_id FROM selected_accounts WHERE post_id = $1"
+	query := `SELECT ` + selectedAccountColumns + `
+		FROM selected_accounts sa
+		JOIN social_accounts a ON a.id = sa.account_id
+		WHERE sa.post_id = $1
+		ORDER BY sa.account_id`
 
 	rows, err := r.db.QueryContext(ctx, query, postID)
 	if err != nil {
@@ -74,7 +117,7 @@ func (r *selectedAccountRepository) ListByPostID(ctx context.Context, postID int
 	var accounts []*models.SelectedAccount
 	for rows.Next() {
 		var sa models.SelectedAccount
-		if err := rows.Scan(&sa.PostID, &sa.AccountID); err != nil {
+		if err := scanSelectedAccount(rows, &sa); err != nil {
 			slog.Info(err.Error())
 			return nil, fmt.Errorf("scan row: %w", err)
 		}
@@ -117,6 +160,64 @@ func (r *selectedAccountRepository) ListByAccountID(ctx context.Context, account
 	return accounts, nil
 }
 
+// StartDeliveryAttempt marks the delivery as publishing and returns the
+// number of the attempt that is starting.
+func (r *selectedAccountRepository) StartDeliveryAttempt(ctx context.Context, postID, accountID int64) (int, error) {
+	query := `
+		UPDATE selected_accounts
+		SET status = $1,
+			attempts = COALESCE(attempts, 0) + 1,
+			updated_at = $2
+		WHERE post_id = $3 AND account_id = $4
+		RETURNING attempts
+	`
+
+	var attempts int
+	err := r.db.QueryRowContext(ctx, query, models.DeliveryStatusPublishing, time.Now(), postID, accountID).Scan(&attempts)
+	if err != nil {
+		slog.Info(err.Error())
+		return 0, err
+	}
+	return attempts, nil
+}
+
+func (r *selectedAccountRepository) UpdateDelivery(ctx context.Context, sa *models.SelectedAccount) error {
+	query := `
+		UPDATE selected_accounts
+		SET status = $1,
+			remote_media_id = NULLIF($2, ''),
+			permalink = NULLIF($3, ''),
+			error_message = NULLIF($4, ''),
+			published_at = $5,
+			updated_at = $6
+		WHERE post_id = $7 AND account_id = $8
+	`
+	_, err := r.db.ExecContext(ctx, query, sa.Status, sa.RemoteMediaID, sa.Permalink, sa.ErrorMessage, sa.PublishedAt, time.Now(), sa.PostID, sa.AccountID)
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
+
+// ResetDelivery puts a delivery back to pending with a fresh attempt count.
+func (r *selectedAccountRepository) ResetDelivery(ctx context.Context, postID, accountID int64) error {
+	query := `
+		UPDATE selected_accounts
+		SET status = $1,
+			attempts = 0,
+			error_message = NULL,
+			updated_at = $2
+		WHERE post_id = $3 AND account_id = $4
+	`
+	_, err := r.db.ExecContext(ctx, query, models.DeliveryStatusPending, time.Now(), postID, accountID)
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
+
 func (r *selectedAccountRepository) Remove(ctx context.Context, postID, accountID int64) error {
 	query := `DELETE FROM social_accounts WHERE post_id = $1 AND account_id = $2`
 	_, err := r.db.ExecContext(ctx, query, postID, accountID)
@@ -126,3 +227,55 @@ func (r *selectedAccountRepository) Remove(ctx context.Context, postID, accountI
 	}
 	return nil
 }
+
+func (r *selectedAccountRepository) RemoveByPostID(ctx context.Context, tx *sql.Tx, postID int64) error {
+	var err error
+
+	query := `DELETE FROM selected_accounts WHERE post_id = $1`
+	if tx != nil {
+		_, err = tx.ExecContext(ctx, query, postID)
+	} else {
+		_, err = r.db.ExecContext(ctx, query, postID)
+	}
+
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
+
+// SetOverride stores the override used when the post is published to the
+// account, a nil override removes it.
+func (r *selectedAccountRepository) SetOverride(ctx context.Context, tx *sql.Tx, postID, accountID int64, override *models.Override) error {
+	if override == nil {
+		override = &models.Override{}
+	}
+
+	options, err := marshalOptions(override.Options)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE selected_accounts
+		SET caption_override = NULLIF($1, ''),
+			title_override = NULLIF($2, ''),
+			hashtags = $3,
+			options = $4,
+			updated_at = $5
+		WHERE post_id = $6 AND account_id = $7
+	`
+
+	args := []any{override.Caption, override.Title, pq.Array(override.Hashtags), options, time.Now(), postID, accountID}
+	if tx != nil {
+		_, err = tx.ExecContext(ctx, query, args...)
+	} else {
+		_, err = r.db.ExecContext(ctx, query, args...)
+	}
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/maheshrc27/scheduling-api/internal/models"
+)
+
+type SettingsRepository interface {
+	GetByUserID(ctx context.Context, userID int64) ([]*models.Settings, error)
+	Create(ctx context.Context, settings *models.Settings) (int64, error)
+	CheckByUserID(ctx context.Context, settingsID, userID int64) (bool, error)
+	Remove(ctx context.Context, id int64) error
+}
+
+type settingsRepository struct {
+	db *sql.DB
+}
+
+func NewSettingsRepository(db *sql.DB) SettingsRepository {
+	return &settingsRepository{db: db}
+}
+
+func (r *settingsRepository) GetByUserID(ctx context.Context, userID int64) ([]*models.Settings, error) {
+	query := `
+		SELECT id, user_id, COALESCE(account_id, 0), weekday, posting_time, COALESCE(category, ''), created_at, updated_at
+		FROM settings
+		WHERE user_id = $1
+		ORDER BY weekday, posting_time
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		slog.Info(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var slots []*models.Settings
+	for rows.Next() {
+		var slot models.Settings
+		err := rows.Scan(&slot.ID, &slot.UserID, &slot.AccountID, &slot.Weekday, &slot.PostingTime, &slot.Category, &slot.CreatedAt, &slot.UpdatedAt)
+		if err != nil {
+			slog.Info(err.Error())
+			return nil, err
+		}
+		slots = append(slots, &slot)
+	}
+	return slots, rows.Err()
+}
+
+func (r *settingsRepository) Create(ctx context.Context, settings *models.Settings) (int64, error) {
+	query := `
+		INSERT INTO settings (user_id, account_id, weekday, posting_time, category)
+		VALUES ($1, NULLIF($2, 0), $3, $4, NULLIF($5, ''))
+		RETURNING id
+	`
+
+	var id int64
+	err := r.db.QueryRowContext(ctx, query, settings.UserID, settings.AccountID, settings.Weekday, settings.PostingTime.Format("15:04:05"), settings.Category).Scan(&id)
+	if err != nil {
+		slog.Info(err.Error())
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *settingsRepository) CheckByUserID(ctx context.Context, settingsID, userID int64) (bool, error) {
+	query := "SELECT 1 FROM settings WHERE id = $1 AND user_id = $2"
+
+	var result int
+	err := r.db.QueryRowContext(ctx, query, settingsID, userID).Scan(&result)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		slog.Info(err.Error())
+		return false, err
+	}
+
+	return result == 1, nil
+}
+
+func (r *settingsRepository) Remove(ctx context.Context, id int64) error {
+	query := `DELETE FROM settings WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
@@ -15,6 +15,7 @@ type UserRepository interface {
 	GetByEmail(ctx context.Context, email string) (*models.User, bool, error)
 	Create(ctx context.Context, tx *sql.Tx, user *models.User) (int64, error)
 	Update(ctx context.Context, user *models.User) error
+	SetTimezone(ctx context.Context, userID int64, timezone string) error
 	Remove(ctx context.Context, id int64) error
 }
 
@@ -28,8 +29,8 @@ func NewUserRepository(db *sql.DB) UserRepository {
 
 func (r *userRepository) GetByID(ctx context.Context, id int64) (*models.User, bool, error) {
 	var user models.User
-	query := "SELECT id, name, email, profile_picture FROM users WHERE id = $1"
-	err := r.db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Name, &user.Email, &user.ProfilePicture)
+	query := "SELECT id, name, email, profile_picture, COALESCE(timezone, 'UTC') FROM users WHERE id = $1"
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Name, &user.Email, &user.ProfilePicture, &user.Timezone)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, false, nil
@@ -90,6 +91,16 @@ func (r *userRepository) Update(ctx context.Context, user *models.User) error {
 	return nil
 }
 
+func (r *userRepository) SetTimezone(ctx context.Context, userID int64, timezone string) error {
+	query := `UPDATE users SET timezone = $1, updated_at = $2 WHERE id = $3`
+	_, err := r.db.ExecContext(ctx, query, timezone, time.Now(), userID)
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
+
 func (r *userRepository) Remove(ctx context.Context, id int64) error {
 	query := `DELETE FROM users WHERE id = $1`
 	_, err := r.db.ExecContext(ctx, query, id)
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/maheshrc27/scheduling-api/internal/models"
+)
+
+// AggregatePostStatus derives the status of a post from the deliveries to
+// each of its selected accounts.
+func AggregatePostStatus(deliveries []*models.SelectedAccount) string {
+	if len(deliveries) == 0 {
+		return models.PostStatusScheduled
+	}
+
+	var published, failed int
+	for _, d := range deliveries {
+		switch d.Status {
+		case models.DeliveryStatusPublished:
+			published++
+		case models.DeliveryStatusFailed:
+			failed++
+		default:
+			// Some accounts are still pending or being published to
+			return models.PostStatusPublishing
+		}
+	}
+
+	switch {
+	case failed == 0:
+		return models.PostStatusPosted
+	case published == 0:
+		return models.PostStatusFailed
+	default:
+		return models.PostStatusPartiallyPublished
+	}
+}
+
+// PostForDelivery returns a copy of the post with the overrides for the
+// account of the delivery applied: the override of its platform first, then
+// the one of the account itself. Hashtags are appended to the caption.
+func PostForDelivery(post *models.Post, delivery *models.SelectedAccount, platformOverrides []*models.PlatformOverride) *models.Post {
+	p := *post
+	var hashtags []string
+
+	apply := func(o *models.Override) {
+		if o == nil {
+			return
+		}
+		if o.Caption != "" {
+			p.Caption = o.Caption
+		}
+		if o.Title != "" {
+			p.Title = o.Title
+		}
+		if len(o.Hashtags) > 0 {
+			hashtags = o.Hashtags
+		}
+		mergeOptions(&p.Options, o.Options)
+	}
+
+	for _, po := range platformOverrides {
+		if po.Platform == delivery.Platform {
+			apply(&po.Override)
+		}
+	}
+	apply(delivery.Override)
+
+	if len(hashtags) > 0 {
+		tags := make([]string, 0, len(hashtags))
+		for _, tag := range hashtags {
+			tags = append(tags, "#"+tag)
+		}
+		p.Caption = strings.TrimSpace(p.Caption + "\n\n" + strings.Join(tags, " "))
+	}
+
+	return &p
+}
+
+func mergeOptions(dst *models.PublishOptions, src models.PublishOptions) {
+	if src.PrivacyLevel != "" {
+		dst.PrivacyLevel = src.PrivacyLevel
+	}
+	if src.DisableComment != nil {
+		dst.DisableComment = src.DisableComment
+	}
+	if src.DisableDuet != nil {
+		dst.DisableDuet = src.DisableDuet
+	}
+	if src.DisableStitch != nil {
+		dst.DisableStitch = src.DisableStitch
+	}
+	if src.CategoryID != "" {
+		dst.CategoryID = src.CategoryID
+	}
+	if src.ImageFit != "" {
+		dst.ImageFit = src.ImageFit
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/maheshrc27/scheduling-api/internal/models"
+	"github.com/maheshrc27/scheduling-api/internal/repository"
+	"github.com/maheshrc27/scheduling-api/internal/transfer"
+)
+
+const (
+	defaultHistoryPageSize = 20
+	maxHistoryPageSize     = 100
+)
+
+type HistoryService interface {
+	List(ctx context.Context, userID int64, hq *transfer.HistoryQuery) ([]*models.PostingHistory, int, error)
+}
+
+type historyService struct {
+	ph repository.PostingHistoryRepository
+	ur repository.UserRepository
+}
+
+func NewHistoryService(ph repository.PostingHistoryRepository, ur repository.UserRepository) HistoryService {
+	return &historyService{
+		ph: ph,
+		ur: ur,
+	}
+}
+
+func (s *historyService) List(ctx context.Context, userID int64, hq *transfer.HistoryQuery) ([]*models.PostingHistory, int, error) {
+	var err error
+
+	if userID == 0 {
+		err = errors.New("User is not valid")
+		slog.Info(err.Error())
+		return nil, 0, err
+	}
+
+	filter := models.HistoryFilter{
+		PostID:    hq.PostID,
+		AccountID: hq.AccountID,
+		Platform:  hq.Platform,
+	}
+
+	switch hq.Status {
+	case "":
+	case "success":
+		succeeded := true
+		filter.Succeeded = &succeeded
+	case "failure":
+		succeeded := false
+		filter.Succeeded = &succeeded
+	default:
+		err = fmt.Errorf("invalid status %q, expected success or failure", hq.Status)
+		slog.Info(err.Error())
+		return nil, 0, err
+	}
+
+	// Dates are in the zone of the user
+	loc := time.UTC
+	if hq.From != "" || hq.To != "" {
+		loc = locationOrUTC(userZone(ctx, s.ur, userID))
+	}
+	if hq.From != "" {
+		if filter.From, err = parseHistoryDate(hq.From, false, loc); err != nil {
+			return nil, 0, err
+		}
+	}
+	if hq.To != "" {
+		if filter.To, err = parseHistoryDate(hq.To, true, loc); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	perPage := hq.PerPage
+	if perPage <= 0 {
+		perPage = defaultHistoryPageSize
+	}
+	if perPage > maxHistoryPageSize {
+		perPage = maxHistoryPageSize
+	}
+	page := hq.Page
+	if page <= 0 {
+		page = 1
+	}
+	hq.Page, hq.PerPage = page, perPage
+	filter.Limit = perPage
+	filter.Offset = (page - 1) * perPage
+
+	history, total, err := s.ph.List(ctx, userID, &filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Error getting posting history")
+	}
+
+	return history, total, nil
+}
+
+// parseHistoryDate accepts a date or a date with time in loc. A plain date
+// used as the end of a range includes the whole day.
+func parseHistoryDate(value string, end bool, loc *time.Location) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02T15:04", value, loc); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		err = fmt.Errorf("invalid date %q, expected YYYY-MM-DD or YYYY-MM-DDTHH:MM", value)
+		slog.Info(err.Error())
+		return time.Time{}, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/maheshrc27/scheduling-api/internal/models"
+	"github.com/maheshrc27/scheduling-api/internal/repository"
+	"github.com/maheshrc27/scheduling-api/pkg/utils"
+)
+
+// How images are fit to the aspect ratios of a platform, set with the
+// image_fit option. Images are cropped by default.
+const (
+	ImageFitCrop = "crop"
+	ImageFitPad  = "pad"
+)
+
+const variantQuality = 90
+
+// ImageVariants makes copies of images that fit the rules of a platform:
+// within its aspect ratios and size, and re-encoded to JPEG when it only
+// takes JPEGs. Variants are made the first time they are published and kept
+// for the next posts.
+type ImageVariants interface {
+	// URL returns the URL to publish the image of an asset from, the asset
+	// itself when it already fits the platform.
+	URL(ctx context.Context, asset *models.MediaAsset, platform, fit string) (string, error)
+	List(ctx context.Context, assetID int64) ([]*models.MediaVariant, error)
+}
+
+type imageVariants struct {
+	mv    repository.MediaVariantRepository
+	store Storage
+}
+
+func NewImageVariants(mv repository.MediaVariantRepository, store Storage) ImageVariants {
+	return &imageVariants{
+		mv:    mv,
+		store: store,
+	}
+}
+
+func (v *imageVariants) URL(ctx context.Context, asset *models.MediaAsset, platform, fit string) (string, error) {
+	rules := platformRules[platform]
+	if strings.HasPrefix(asset.FileType, "video/") || fitsImageRules(rules, asset) {
+		return asset.FileURL, nil
+	}
+
+	if fit == "" {
+		fit = ImageFitCrop
+	}
+	name := platform
+	if rules.MinAspectRatio > 0 || rules.MaxAspectRatio > 0 {
+		name += "-" + fit
+	}
+
+	variant, err := v.mv.Get(ctx, asset.ID, name)
+	if err != nil {
+		return "", fmt.Errorf("error getting image variant: %w", err)
+	}
+	if variant != nil {
+		return variant.FileURL, nil
+	}
+
+	variant, err = v.create(ctx, asset, rules, name, fit)
+	if err != nil {
+		return "", err
+	}
+	return variant.FileURL, nil
+}
+
+// create makes the variant of an image for the rules and stores it next to
+// the image.
+func (v *imageVariants) create(ctx context.Context, asset *models.MediaAsset, rules PlatformRules, name, fit string) (*models.MediaVariant, error) {
+	data, err := v.store.ReadRange(ctx, asset.FileName, 0, asset.FileSize)
+	if err != nil {
+		return nil, fmt.Errorf("error reading image: %w", err)
+	}
+
+	img, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	img = utils.FitAspectRatio(img, rules.MinAspectRatio, rules.MaxAspectRatio, fit == ImageFitPad)
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	if rules.MaxImageDimension > 0 {
+		width, height = utils.FitSize(width, height, rules.MaxImageDimension)
+		if width != img.Bounds().Dx() {
+			img = utils.Resize(img, width, height)
+		}
+	}
+
+	encoded, err := utils.EncodeJPEG(img, variantQuality)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding image: %w", err)
+	}
+
+	key := fmt.Sprintf("%s-%s.jpg", asset.FileName, name)
+	if err := v.store.Put(ctx, key, bytes.NewReader(encoded), int64(len(encoded)), "image/jpeg"); err != nil {
+		return nil, fmt.Errorf("error storing image variant: %w", err)
+	}
+
+	variant := &models.MediaVariant{
+		AssetID:  asset.ID,
+		Name:     name,
+		FileKey:  key,
+		FileURL:  v.store.URL(key),
+		FileSize: int64(len(encoded)),
+		Width:    width,
+		Height:   height,
+	}
+	if err := v.mv.Create(ctx, variant); err != nil {
+		return nil, fmt.Errorf("error saving image variant: %w", err)
+	}
+	return variant, nil
+}
+
+func (v *imageVariants) List(ctx context.Context, assetID int64) ([]*models.MediaVariant, error) {
+	return v.mv.ListByAssetID(ctx, assetID)
+}
+
+// fitsImageRules tells whether an image can be published as it is. Images
+// of unknown size get a variant, in case they don't fit.
+func fitsImageRules(rules PlatformRules, asset *models.MediaAsset) bool {
+	if !rules.resizesImages() {
+		return true
+	}
+	if rules.JPEGImages && asset.FileType != "image/jpeg" {
+		return false
+	}
+
+	info := assetInfo(asset)
+	ratio := info.AspectRatio()
+	switch {
+	case ratio == 0:
+		return false
+	case rules.MaxImageDimension > 0 && max(info.Width, info.Height) > rules.MaxImageDimension:
+		return false
+	case rules.MinAspectRatio > 0 && ratio < rules.MinAspectRatio:
+		return false
+	case rules.MaxAspectRatio > 0 && ratio > rules.MaxAspectRatio:
+		return false
+	}
+	return true
+}
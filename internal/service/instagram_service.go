@@ -24,7 +24,7 @@ import (
 type InstagramService interface {
 	InstagramCallback(ctx context.Context, code string, userID int64) (err error)
 	RefreshInstagramToken(ctx context.Context, userID int64, refreshToken string) error
-	HandleInstagramPost(ctx context.Context, post *models.Post, socialAcc *models.SocialAccount) error
+	HandleInstagramPost(ctx context.Context, post *models.Post, socialAcc *models.SocialAccount) (*transfer.PublishResult, error)
 }
 
 type instagramService struct {
@@ -33,6 +33,8 @@ type instagramService struct {
 	p   repository.PostRepository
 	pm  repository.PostMediaRepository
 	ma  repository.MediaAssetRepository
+	iv  ImageVariants
+	vr  VideoRenditions
 }
 
 func NewInstagramService(
@@ -40,13 +42,17 @@ func NewInstagramService(
 	sa repository.SocialAccountRepository,
 	p repository.PostRepository,
 	pm repository.PostMediaRepository,
-	ma repository.MediaAssetRepository) InstagramService {
+	ma repository.MediaAssetRepository,
+	iv ImageVariants,
+	vr VideoRenditions) InstagramService {
 	return &instagramService{
 		cfg: cfg,
 		sa:  sa,
 		p:   p,
 		pm:  pm,
 		ma:  ma,
+		iv:  iv,
+		vr:  vr,
 	}
 }
 
@@ -277,83 +283,77 @@ func (s *instagramService) RefreshInstagramToken(ctx context.Context, userID int
 	return nil
 }
 
-func (s *instagramService) HandleInstagramPost(ctx context.Context, post *models.Post, socialAcc *models.SocialAccount) error {
-	var err error
-
+func (s *instagramService) HandleInstagramPost(ctx context.Context, post *models.Post, socialAcc *models.SocialAccount) (*transfer.PublishResult, error) {
 	decryptedAccessToken, err := utils.Decrypt(socialAcc.AccessToken, []byte(s.cfg.SecretKey))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	var mediaID string
 	switch post.PostType {
 	case "single":
-		err = s.InstagramSinglePost(ctx, post.ID, socialAcc.AccountID, post.Caption, decryptedAccessToken)
+		mediaID, err = s.InstagramSinglePost(ctx, post.ID, socialAcc.AccountID, post.Caption, post.Options.ImageFit, decryptedAccessToken)
 		if err != nil {
-			return fmt.Errorf("failed to schedule single post on Instagram: %w", err)
+			return nil, fmt.Errorf("failed to schedule single post on Instagram: %w", err)
 		}
 	case "multiple":
-		err := s.InstagramCarouselPost(ctx, post.ID, socialAcc.AccountID, post.Caption, decryptedAccessToken)
+		mediaID, err = s.InstagramCarouselPost(ctx, post.ID, socialAcc.AccountID, post.Caption, post.Options.ImageFit, decryptedAccessToken)
 		if err != nil {
-			return fmt.Errorf("failed to schedule carousel post on Instagram: %w", err)
+			return nil, fmt.Errorf("failed to schedule carousel post on Instagram: %w", err)
 		}
 	}
 
-	if err := s.p.UpdatePostStatus(ctx, models.PostStatusPosted, post.ID); err != nil {
-		return fmt.Errorf("failed to update status: %w", err)
+	permalink, err := InstagramPermalink(mediaID, decryptedAccessToken)
+	if err != nil {
+		// The media is live at this point, a missing permalink is not a failure
+		slog.Info(err.Error())
 	}
-	return nil
+
+	return &transfer.PublishResult{MediaID: mediaID, Permalink: permalink}, nil
 }
 
-func (s *instagramService) InstagramSinglePost(ctx context.Context, postID int64, accountID, caption, accessToken string) error {
-	url := fmt.Sprintf("https://graph.instagram.com/v21.0/%s/media", accountID)
+func (s *instagramService) InstagramSinglePost(ctx context.Context, postID int64, accountID, caption, imageFit, accessToken string) (string, error) {
+	url := instagramMediaURL(accountID)
 
 	postMedia, err := s.pm.GetByPostID(ctx, postID)
 	if err != nil {
-		return fmt.Errorf("error fetching post media for PostID %d: %w", postID, err)
+		return "", fmt.Errorf("error fetching post media for PostID %d: %w", postID, err)
 	}
 
 	if postMedia == nil {
-		return fmt.Errorf("no media found for PostID %d", postID)
+		return "", fmt.Errorf("no media found for PostID %d", postID)
 	}
 
 	mediaAsset, err := s.ma.GetByID(ctx, postMedia.AssetID)
 	if err != nil {
-		return fmt.Errorf("error retrieving media asset for AssetID %d: %w", postMedia.AssetID, err)
+		return "", fmt.Errorf("error retrieving media asset for AssetID %d: %w", postMedia.AssetID, err)
 	}
 
 	if mediaAsset == nil || mediaAsset.FileURL == "" {
-		return fmt.Errorf("media asset is missing or incomplete for AssetID %d", postMedia.AssetID)
+		return "", fmt.Errorf("media asset is missing or incomplete for AssetID %d", postMedia.AssetID)
 	}
 
-	var payload map[string]interface{}
-	if mediaAsset.FileType == "video/mp4" || mediaAsset.FileType == "video/mov" {
-		payload = map[string]interface{}{
-			"video_url":    mediaAsset.FileURL,
-			"caption":      caption,
-			"media_type":   "REELS",
-			"access_token": accessToken,
-		}
-	} else {
-		payload = map[string]interface{}{
-			"image_url":    mediaAsset.FileURL,
-			"caption":      caption,
-			"access_token": accessToken,
-		}
+	mediaURL, err := publishURL(ctx, s.iv, s.vr, mediaAsset, "instagram", imageFit)
+	if err != nil {
+		return "", fmt.Errorf("error preparing media asset %d: %w", postMedia.AssetID, err)
 	}
+
+	payload := instagramMediaPayload(mediaURL, mediaAsset.FileType, caption, false)
+	payload["access_token"] = accessToken
 	body, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("error marshalling payload: %w", err)
+		return "", fmt.Errorf("error marshalling payload: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
 	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+		return "", fmt.Errorf("error creating request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("HTTP request error: %w", err)
+		return "", fmt.Errorf("HTTP request error: %w", err)
 	}
 	if resp.Body != nil {
 		defer resp.Body.Close()
@@ -364,15 +364,15 @@ func (s *instagramService) InstagramSinglePost(ctx context.Context, postID int64
 
 		respBody, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return fmt.Errorf("error reading response body: %w", err)
+			return "", fmt.Errorf("error reading response body: %w", err)
 		}
 		if err := json.Unmarshal(respBody, &errResponse); err != nil {
-			return fmt.Errorf("error parsing response: %w", err)
+			return "", fmt.Errorf("error parsing response: %w", err)
 		}
 
 		fmt.Printf("%+v", errResponse)
 		err = errors.New(errResponse.Error.Message)
-		return err
+		return "", err
 	}
 
 	var result struct {
@@ -380,78 +380,65 @@ func (s *instagramService) InstagramSinglePost(ctx context.Context, postID int64
 	}
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("error reading response body: %w", err)
+		return "", fmt.Errorf("error reading response body: %w", err)
 	}
 	if err := json.Unmarshal(respBody, &result); err != nil {
-		return fmt.Errorf("error parsing response: %w", err)
+		return "", fmt.Errorf("error parsing response: %w", err)
 	}
 
 	if result.ID == "" {
-		return fmt.Errorf("no media ID returned from Instagram")
+		return "", fmt.Errorf("no media ID returned from Instagram")
 	}
 
-	err = InstagramPublishPost(accountID, result.ID, accessToken)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return InstagramPublishPost(accountID, result.ID, accessToken)
 }
 
-func (s *instagramService) InstagramCarouselPost(ctx context.Context, postID int64, accountID, caption, accessToken string) error {
-	url := fmt.Sprintf("https://graph.instagram.com/v21.0/%s/media", accountID)
+func (s *instagramService) InstagramCarouselPost(ctx context.Context, postID int64, accountID, caption, imageFit, accessToken string) (string, error) {
+	url := instagramMediaURL(accountID)
 	postMedias, err := s.pm.ListByPostID(ctx, postID)
 	if err != nil {
-		return fmt.Errorf("error fetching post media for PostID %d: %w", postID, err)
+		return "", fmt.Errorf("error fetching post media for PostID %d: %w", postID, err)
 	}
 
 	if postMedias == nil {
-		return fmt.Errorf("no media found for PostID %d", postID)
+		return "", fmt.Errorf("no media found for PostID %d", postID)
 	}
 
 	postMediasLength := len(postMedias)
 
-	containerIDs := make([]string, postMediasLength)
+	containerIDs := make([]string, 0, postMediasLength)
 
 	for _, postMedia := range postMedias {
 		mediaAsset, err := s.ma.GetByID(ctx, postMedia.AssetID)
 		if err != nil {
-			return fmt.Errorf("error retrieving media asset for AssetID %d: %w", postMedia.AssetID, err)
+			return "", fmt.Errorf("error retrieving media asset for AssetID %d: %w", postMedia.AssetID, err)
 		}
 
 		if mediaAsset == nil || mediaAsset.FileURL == "" {
-			return fmt.Errorf("media asset is missing or incomplete for AssetID %d", postMedia.AssetID)
+			return "", fmt.Errorf("media asset is missing or incomplete for AssetID %d", postMedia.AssetID)
 		}
 
-		var payload map[string]interface{}
-		if mediaAsset.FileType == "video/mp4" || mediaAsset.FileType == "video/mov" {
-			payload = map[string]interface{}{
-				"media_type":       "VIDEO",
-				"video_url":        mediaAsset.FileURL,
-				"is_carousel_item": true,
-				"access_token":     accessToken,
-			}
-		} else {
-			payload = map[string]interface{}{
-				"image_url":        mediaAsset.FileURL,
-				"is_carousel_item": true,
-				"access_token":     accessToken,
-			}
+		mediaURL, err := publishURL(ctx, s.iv, s.vr, mediaAsset, "instagram", imageFit)
+		if err != nil {
+			return "", fmt.Errorf("error preparing media asset %d: %w", postMedia.AssetID, err)
 		}
+
+		payload := instagramMediaPayload(mediaURL, mediaAsset.FileType, "", true)
+		payload["access_token"] = accessToken
 		body, err := json.Marshal(payload)
 		if err != nil {
-			return fmt.Errorf("error marshalling payload: %w", err)
+			return "", fmt.Errorf("error marshalling payload: %w", err)
 		}
 
 		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
 		if err != nil {
-			return fmt.Errorf("error creating request: %w", err)
+			return "", fmt.Errorf("error creating request: %w", err)
 		}
 		req.Header.Set("Content-Type", "application/json")
 
 		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
-			return fmt.Errorf("HTTP request error: %w", err)
+			return "", fmt.Errorf("HTTP request error: %w", err)
 		}
 		if resp.Body != nil {
 			defer resp.Body.Close()
@@ -462,14 +449,14 @@ func (s *instagramService) InstagramCarouselPost(ctx context.Context, postID int
 
 			respBody, err := io.ReadAll(resp.Body)
 			if err != nil {
-				return fmt.Errorf("error reading response body: %w", err)
+				return "", fmt.Errorf("error reading response body: %w", err)
 			}
 			if err := json.Unmarshal(respBody, &errResponse); err != nil {
-				return fmt.Errorf("error parsing response: %w", err)
+				return "", fmt.Errorf("error parsing response: %w", err)
 			}
 
 			err = errors.New(errResponse.Error.Message)
-			return err
+			return "", err
 		}
 
 		var result struct {
@@ -477,40 +464,36 @@ func (s *instagramService) InstagramCarouselPost(ctx context.Context, postID int
 		}
 		respBody, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return fmt.Errorf("error reading response body: %w", err)
+			return "", fmt.Errorf("error reading response body: %w", err)
 		}
 		if err := json.Unmarshal(respBody, &result); err != nil {
-			return fmt.Errorf("error parsing response: %w", err)
+			return "", fmt.Errorf("error parsing response: %w", err)
 		}
 
 		if result.ID == "" {
-			return fmt.Errorf("no media ID returned from Instagram")
+			return "", fmt.Errorf("no media ID returned from Instagram")
 		}
 
 		containerIDs = append(containerIDs, result.ID)
 	}
 
-	payload := map[string]interface{}{
-		"media_type":   "CAROUSEL",
-		"caption":      caption,
-		"children":     containerIDs,
-		"access_token": accessToken,
-	}
+	payload := instagramCarouselPayload(caption, containerIDs)
+	payload["access_token"] = accessToken
 
 	body, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("error marshalling payload: %w", err)
+		return "", fmt.Errorf("error marshalling payload: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
 	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+		return "", fmt.Errorf("error creating request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("HTTP request error: %w", err)
+		return "", fmt.Errorf("HTTP request error: %w", err)
 	}
 	if resp.Body != nil {
 		defer resp.Body.Close()
@@ -521,14 +504,14 @@ func (s *instagramService) InstagramCarouselPost(ctx context.Context, postID int
 
 		respBody, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return fmt.Errorf("error reading response body: %w", err)
+			return "", fmt.Errorf("error reading response body: %w", err)
 		}
 		if err := json.Unmarshal(respBody, &errResponse); err != nil {
-			return fmt.Errorf("error parsing response: %w", err)
+			return "", fmt.Errorf("error parsing response: %w", err)
 		}
 
 		err = errors.New(errResponse.Error.Message)
-		return err
+		return "", err
 	}
 
 	var result struct {
@@ -536,90 +519,164 @@ func (s *instagramService) InstagramCarouselPost(ctx context.Context, postID int
 	}
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("error reading response body: %w", err)
+		return "", fmt.Errorf("error reading response body: %w", err)
 	}
 	if err := json.Unmarshal(respBody, &result); err != nil {
-		return fmt.Errorf("error parsing response: %w", err)
+		return "", fmt.Errorf("error parsing response: %w", err)
 	}
 
 	if result.ID == "" {
-		return fmt.Errorf("no media ID returned from Instagram")
+		return "", fmt.Errorf("no media ID returned from Instagram")
 	}
 
-	err = InstagramPublishPost(accountID, result.ID, accessToken)
-	if err != nil {
-		return err
+	return InstagramPublishPost(accountID, result.ID, accessToken)
+}
+
+func instagramMediaURL(accountID string) string {
+	return fmt.Sprintf("https://graph.instagram.com/v21.0/%s/media", accountID)
+}
+
+// instagramMediaPayload is the body creating the container of a media,
+// without the access token. Carousel items have no caption of their own.
+func instagramMediaPayload(fileURL, fileType, caption string, carouselItem bool) map[string]interface{} {
+	isVideo := fileType == "video/mp4" || fileType == "video/mov"
+	if carouselItem {
+		if isVideo {
+			return map[string]interface{}{
+				"media_type":       "VIDEO",
+				"video_url":        fileURL,
+				"is_carousel_item": true,
+			}
+		}
+		return map[string]interface{}{
+			"image_url":        fileURL,
+			"is_carousel_item": true,
+		}
 	}
 
-	return nil
+	if isVideo {
+		return map[string]interface{}{
+			"video_url":  fileURL,
+			"caption":    caption,
+			"media_type": "REELS",
+		}
+	}
+	return map[string]interface{}{
+		"image_url": fileURL,
+		"caption":   caption,
+	}
+}
+
+// instagramCarouselPayload is the body creating a carousel from the
+// containers of its items, without the access token.
+func instagramCarouselPayload(caption string, children []string) map[string]interface{} {
+	return map[string]interface{}{
+		"media_type": "CAROUSEL",
+		"caption":    caption,
+		"children":   children,
+	}
 }
 
-func InstagramPublishPost(accountID, mediaID, accessToken string) error {
+func InstagramPublishPost(accountID, mediaID, accessToken string) (string, error) {
 	checkStatusURL := fmt.Sprintf("https://graph.instagram.com/v21.0/%s?fields=status_code&access_token=%s", mediaID, accessToken)
 	isUploaded, err := isUploadSuccessful(0, checkStatusURL)
 	if err != nil {
-		return err
+		return "", err
+	}
+	if !isUploaded {
+		return "", fmt.Errorf("media container %s was not ready for publishing", mediaID)
 	}
 
-	if isUploaded {
-		url := fmt.Sprintf("https://graph.instagram.com/v21.0/%s/media_publish", accountID)
-		payload := map[string]string{
-			"creation_id":  mediaID,
-			"access_token": accessToken,
-		}
+	url := fmt.Sprintf("https://graph.instagram.com/v21.0/%s/media_publish", accountID)
+	payload := map[string]string{
+		"creation_id":  mediaID,
+		"access_token": accessToken,
+	}
 
-		body, err := json.Marshal(payload)
-		if err != nil {
-			return fmt.Errorf("error marshalling payload: %w", err)
-		}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling payload: %w", err)
+	}
 
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
-		if err != nil {
-			return fmt.Errorf("error creating request: %w", err)
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("HTTP request error: %w", err)
+	}
+	if resp.Body != nil {
+		defer resp.Body.Close()
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var AutoGenerated struct {
+			Error struct {
+				Message        string `json:"message"`
+				Type           string `json:"type"`
+				Code           int    `json:"code"`
+				ErrorSubcode   int    `json:"error_subcode"`
+				IsTransient    bool   `json:"is_transient"`
+				ErrorUserTitle string `json:"error_user_title"`
+				ErrorUserMsg   string `json:"error_user_msg"`
+				FbtraceID      string `json:"fbtrace_id"`
+			} `json:"error"`
 		}
-		req.Header.Set("Content-Type", "application/json")
 
-		resp, err := http.DefaultClient.Do(req)
+		respBody, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return fmt.Errorf("HTTP request error: %w", err)
+			return "", fmt.Errorf("error reading response body: %w", err)
 		}
-		if resp.Body != nil {
-			defer resp.Body.Close()
+		if err := json.Unmarshal(respBody, &AutoGenerated); err != nil {
+			return "", fmt.Errorf("error parsing response: %w", err)
 		}
 
-		if resp.StatusCode != http.StatusOK {
-			var AutoGenerated struct {
-				Error struct {
-					Message        string `json:"message"`
-					Type           string `json:"type"`
-					Code           int    `json:"code"`
-					ErrorSubcode   int    `json:"error_subcode"`
-					IsTransient    bool   `json:"is_transient"`
-					ErrorUserTitle string `json:"error_user_title"`
-					ErrorUserMsg   string `json:"error_user_msg"`
-					FbtraceID      string `json:"fbtrace_id"`
-				} `json:"error"`
-			}
+		fmt.Printf("%+v", AutoGenerated)
+		return "", fmt.Errorf("unexpected status code from Instagram: %d", resp.StatusCode)
+	}
 
-			respBody, err := io.ReadAll(resp.Body)
-			if err != nil {
-				return fmt.Errorf("error reading response body: %w", err)
-			}
-			if err := json.Unmarshal(respBody, &AutoGenerated); err != nil {
-				return fmt.Errorf("error parsing response: %w", err)
-			}
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading response body: %w", err)
+	}
+	log.Printf("Publish response from Instagram: %s\n", string(respBody))
 
-			fmt.Printf("%+v", AutoGenerated)
-			return fmt.Errorf("unexpected status code from Instagram: %d", resp.StatusCode)
-		}
+	var result struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("error parsing response: %w", err)
+	}
+	return result.ID, nil
+}
 
-		respBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("error reading response body: %w", err)
-		}
-		log.Printf("Publish response from Instagram: %s\n", string(respBody))
+// InstagramPermalink looks up the public URL of a published media.
+func InstagramPermalink(mediaID, accessToken string) (string, error) {
+	if mediaID == "" {
+		return "", errors.New("no media ID to look up")
 	}
-	return nil
+
+	reqUrl := fmt.Sprintf("https://graph.instagram.com/v21.0/%s?fields=permalink&access_token=%s", mediaID, accessToken)
+	resp, err := http.Get(reqUrl)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code from Instagram: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Permalink string `json:"permalink"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	return result.Permalink, nil
 }
 
 func isUploadSuccessful(retryCount int, checkStatusUri string) (bool, error) {
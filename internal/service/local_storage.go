@@ -0,0 +1,288 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	cfg "github.com/maheshrc27/scheduling-api/configs"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+// multipartDir is where the parts of multipart uploads are kept, inside the
+// storage directory.
+const multipartDir = ".multipart"
+
+// LocalStorage keeps files in a directory, for development and running
+// offline. The files are served by the API itself, see
+// handlers.FilesHandler.
+type LocalStorage struct {
+	dir       string
+	publicURL string
+	secretKey string
+}
+
+func NewLocalStorage(cfg cfg.Config) (*LocalStorage, error) {
+	dir, err := filepath.Abs(cfg.Storage.LocalPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, multipartDir), 0o755); err != nil {
+		return nil, err
+	}
+
+	return &LocalStorage{
+		dir:       dir,
+		publicURL: strings.TrimSuffix(cfg.Storage.PublicURL, "/"),
+		secretKey: cfg.SecretKey,
+	}, nil
+}
+
+// Path returns where the file of key is on disk.
+func (l *LocalStorage) Path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, ".") || strings.ContainsAny(key, `/\`) {
+		return "", fmt.Errorf("invalid file key %q", key)
+	}
+	return filepath.Join(l.dir, key), nil
+}
+
+func (l *LocalStorage) Put(ctx context.Context, key string, file io.Reader, size int64, filetype string) error {
+	path, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(path, io.LimitReader(file, size)); err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
+
+func (l *LocalStorage) Delete(ctx context.Context, key string) error {
+	path, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
+
+func (l *LocalStorage) Size(ctx context.Context, key string) (int64, error) {
+	path, err := l.Path(key)
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		slog.Info(err.Error())
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (l *LocalStorage) ReadRange(ctx context.Context, key string, offset, length int64) ([]byte, error) {
+	path, err := l.Path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		slog.Info(err.Error())
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(io.NewSectionReader(file, offset, length))
+}
+
+func (l *LocalStorage) URL(key string) string {
+	return l.publicURL + "/" + key
+}
+
+// PresignPut returns a URL of the file server signed for the file, see
+// CheckPresigned.
+func (l *LocalStorage) PresignPut(ctx context.Context, key, filetype string, size int64, expires time.Duration) (string, http.Header, error) {
+	if _, err := l.Path(key); err != nil {
+		return "", nil, err
+	}
+
+	expiresAt := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+	query := url.Values{
+		"expires":   {expiresAt},
+		"signature": {l.sign(key, filetype, size, expiresAt)},
+	}
+
+	headers := http.Header{}
+	headers.Set("Content-Type", filetype)
+	return l.URL(key) + "?" + query.Encode(), headers, nil
+}
+
+// CheckPresigned tells whether a PUT of a file matches a URL given by
+// PresignPut and is still allowed.
+func (l *LocalStorage) CheckPresigned(key, filetype string, size int64, expiresAt, signature string) bool {
+	expires, err := strconv.ParseInt(expiresAt, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(l.sign(key, filetype, size, expiresAt)))
+}
+
+func (l *LocalStorage) sign(key, filetype string, size int64, expiresAt string) string {
+	mac := hmac.New(sha256.New, []byte(l.secretKey))
+	fmt.Fprintf(mac, "%s\n%s\n%d\n%s", key, filetype, size, expiresAt)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (l *LocalStorage) CreateMultipartUpload(ctx context.Context, key, filetype string) (string, error) {
+	if _, err := l.Path(key); err != nil {
+		return "", err
+	}
+
+	uploadID, err := gonanoid.New()
+	if err != nil {
+		return "", err
+	}
+	if err := os.Mkdir(l.partsDir(uploadID), 0o755); err != nil {
+		slog.Info(err.Error())
+		return "", err
+	}
+	return uploadID, nil
+}
+
+// UploadPart saves a part and returns its MD5 as ETag, like S3 does.
+func (l *LocalStorage) UploadPart(ctx context.Context, key, uploadID string, partNumber int32, data []byte) (string, error) {
+	if partNumber < 1 {
+		return "", fmt.Errorf("invalid part number %d", partNumber)
+	}
+
+	path := filepath.Join(l.partsDir(uploadID), strconv.Itoa(int(partNumber)))
+	if err := writeFileAtomic(path, bytes.NewReader(data)); err != nil {
+		slog.Info(err.Error())
+		return "", err
+	}
+
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`, nil
+}
+
+func (l *LocalStorage) CompleteMultipartUpload(ctx context.Context, key, uploadID string, etags []string) error {
+	path, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(l.dir, "."+key+"-*")
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	for i := range etags {
+		part, err := os.Open(filepath.Join(l.partsDir(uploadID), strconv.Itoa(i+1)))
+		if err != nil {
+			slog.Info(err.Error())
+			return err
+		}
+		_, err = io.Copy(file, part)
+		part.Close()
+		if err != nil {
+			slog.Info(err.Error())
+			return err
+		}
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+
+	return os.RemoveAll(l.partsDir(uploadID))
+}
+
+func (l *LocalStorage) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	if err := os.RemoveAll(l.partsDir(uploadID)); err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
+
+// Walk lists the files of the storage directory. Hidden files are the parts
+// of uploads and files being written, they are skipped.
+func (l *LocalStorage) Walk(ctx context.Context, fn func(file StoredFile) error) error {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		info, err := entry.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			slog.Info(err.Error())
+			return err
+		}
+		if err := fn(StoredFile{Key: entry.Name(), Size: info.Size(), ModifiedAt: info.ModTime()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *LocalStorage) partsDir(uploadID string) string {
+	return filepath.Join(l.dir, multipartDir, filepath.Base(uploadID))
+}
+
+// writeFileAtomic writes a file so that readers never see it half written.
+func writeFileAtomic(path string, data io.Reader) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	config "github.com/maheshrc27/scheduling-api/configs"
+	"github.com/maheshrc27/scheduling-api/internal/models"
+	"github.com/maheshrc27/scheduling-api/internal/repository"
+)
+
+// MediaGCReport lists what a collection removed, or would remove in a dry
+// run.
+type MediaGCReport struct {
+	DryRun  bool
+	Assets  []*models.MediaAsset
+	Uploads int // expired uploads, direct or resumable
+	// Files no asset, variant, rendition or upload refers to. The files of
+	// Assets and Uploads are only listed once they are removed, not in a dry
+	// run.
+	Files  []StoredFile
+	Bytes  int64 // size of Files
+	Failed int   // removals that failed, tried again on the next collection
+}
+
+// MediaGC removes what media leave behind: assets uploaded with posts that
+// no post uses anymore, uploads that expired, and files in storage nothing
+// refers to, such as the files of posts whose transaction failed or of
+// uploads never completed. They are kept for a grace period first, so that
+// media being saved or replaced are left alone.
+type MediaGC interface {
+	Collect(ctx context.Context, dryRun bool) (*MediaGCReport, error)
+}
+
+type mediaGC struct {
+	grace time.Duration
+	ma    repository.MediaAssetRepository
+	mu    repository.MediaUploadRepository
+	ru    repository.ResumableUploadRepository
+	store Storage
+}
+
+func NewMediaGC(
+	cfg config.Config,
+	ma repository.MediaAssetRepository,
+	mu repository.MediaUploadRepository,
+	ru repository.ResumableUploadRepository,
+	store Storage) MediaGC {
+	return &mediaGC{
+		grace: cfg.MediaGC.GracePeriod,
+		ma:    ma,
+		mu:    mu,
+		ru:    ru,
+		store: store,
+	}
+}
+
+func (g *mediaGC) Collect(ctx context.Context, dryRun bool) (*MediaGCReport, error) {
+	before := time.Now().Add(-g.grace)
+	report := &MediaGCReport{DryRun: dryRun}
+
+	// Assets go first, their files are then removed along with the other
+	// files nothing refers to
+	assets, err := g.ma.ListOrphans(ctx, before)
+	if err != nil {
+		return nil, fmt.Errorf("error listing unused media assets: %w", err)
+	}
+	for _, asset := range assets {
+		if !dryRun {
+			removed, err := g.ma.RemoveOrphan(ctx, asset.ID, before)
+			if err != nil {
+				slog.Error("unable to remove unused media asset", "asset_id", asset.ID, "error", err)
+				report.Failed++
+				continue
+			}
+			// A post started using it
+			if !removed {
+				continue
+			}
+		}
+		report.Assets = append(report.Assets, asset)
+	}
+
+	// Expired uploads stop referring to their files
+	if err := g.collectUploads(ctx, before, dryRun, report); err != nil {
+		return nil, err
+	}
+
+	// Files are listed before the references are read, so that a file stored
+	// in between is either referenced or too recent
+	var files []StoredFile
+	err = g.store.Walk(ctx, func(file StoredFile) error {
+		if file.ModifiedAt.Before(before) {
+			files = append(files, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing stored files: %w", err)
+	}
+
+	references, err := g.ma.ListFileReferences(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing media files: %w", err)
+	}
+	referenced := make(map[string]bool, len(references))
+	for _, reference := range references {
+		if key, ok := storageKey(g.store, reference); ok {
+			reference = key
+		}
+		referenced[reference] = true
+	}
+
+	for _, file := range files {
+		if referenced[file.Key] {
+			continue
+		}
+		if !dryRun {
+			if err := g.store.Delete(ctx, file.Key); err != nil {
+				slog.Error("unable to delete unreferenced file", "file", file.Key, "error", err)
+				report.Failed++
+				continue
+			}
+		}
+		report.Files = append(report.Files, file)
+		report.Bytes += file.Size
+	}
+
+	return report, nil
+}
+
+// collectUploads removes the uploads that expired before a time. Direct
+// uploads that are still there were never completed, their files go with the
+// other unreferenced files. Resumable uploads are kept once completed so that
+// clients can check them, those that weren't have their multipart upload
+// aborted.
+func (g *mediaGC) collectUploads(ctx context.Context, before time.Time, dryRun bool, report *MediaGCReport) error {
+	uploads, err := g.mu.ListExpired(ctx, before)
+	if err != nil {
+		return fmt.Errorf("error listing expired uploads: %w", err)
+	}
+	for _, upload := range uploads {
+		if !dryRun {
+			if err := g.mu.Remove(ctx, upload.ID); err != nil {
+				slog.Error("unable to remove expired upload", "upload_id", upload.ID, "error", err)
+				report.Failed++
+				continue
+			}
+		}
+		report.Uploads++
+	}
+
+	resumable, err := g.ru.ListExpired(ctx, before)
+	if err != nil {
+		return fmt.Errorf("error listing expired resumable uploads: %w", err)
+	}
+	for _, upload := range resumable {
+		if !dryRun {
+			if upload.AssetID == 0 {
+				if err := g.store.AbortMultipartUpload(ctx, upload.FileKey, upload.MultipartID); err != nil {
+					slog.Error("unable to abort multipart upload", "upload_id", upload.ID, "file", upload.FileKey, "error", err)
+					report.Failed++
+					continue
+				}
+			}
+			if err := g.ru.Remove(ctx, upload.ID); err != nil {
+				slog.Error("unable to remove expired resumable upload", "upload_id", upload.ID, "error", err)
+				report.Failed++
+				continue
+			}
+		}
+		report.Uploads++
+	}
+	return nil
+}
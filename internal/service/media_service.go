@@ -0,0 +1,529 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/maheshrc27/scheduling-api/internal/models"
+	"github.com/maheshrc27/scheduling-api/internal/repository"
+	"github.com/maheshrc27/scheduling-api/internal/transfer"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+const (
+	defaultMediaPageSize = 20
+	maxMediaPageSize     = 100
+
+	// maxDirectUploadSize is the largest file R2 takes in a single PUT
+	maxDirectUploadSize = 5 * gb
+	// presignedUploadExpiry is how long an upload URL can be used for
+	presignedUploadExpiry = time.Hour
+)
+
+// uploadTypes are the file types that can be uploaded straight to storage.
+var uploadTypes = map[string]struct{}{
+	"image/jpeg": {}, "image/png": {}, "video/mp4": {}, "video/quicktime": {},
+}
+
+type MediaService interface {
+	Upload(ctx context.Context, userID int64, files []*multipart.FileHeader) ([]*models.MediaAsset, error)
+	List(ctx context.Context, userID int64, mq *transfer.MediaQuery) ([]*models.MediaAsset, int, error)
+	Info(ctx context.Context, userID, assetID int64) (*models.MediaAsset, []*models.Post, error)
+	Remove(ctx context.Context, userID, assetID int64) error
+	PresignUpload(ctx context.Context, userID int64, ur *transfer.UploadRequest) (*transfer.PresignedUpload, error)
+	CompleteUpload(ctx context.Context, userID, uploadID int64) (*models.MediaAsset, error)
+	SetCover(ctx context.Context, userID, assetID int64, coverTimeMs int) (*models.MediaAsset, error)
+	RetryTranscode(ctx context.Context, userID, assetID int64) (*models.MediaAsset, error)
+}
+
+type mediaService struct {
+	ma    repository.MediaAssetRepository
+	mu    repository.MediaUploadRepository
+	pr    repository.PostRepository
+	store Storage
+	mp    MediaProcessor
+	iv    ImageVariants
+	vr    VideoRenditions
+}
+
+func NewMediaService(ma repository.MediaAssetRepository, mu repository.MediaUploadRepository, pr repository.PostRepository, store Storage, mp MediaProcessor, iv ImageVariants, vr VideoRenditions) MediaService {
+	return &mediaService{
+		ma:    ma,
+		mu:    mu,
+		pr:    pr,
+		store: store,
+		mp:    mp,
+		iv:    iv,
+		vr:    vr,
+	}
+}
+
+// Upload saves files as media assets of the user, to be used by posts later.
+func (s *mediaService) Upload(ctx context.Context, userID int64, files []*multipart.FileHeader) ([]*models.MediaAsset, error) {
+	if len(files) == 0 {
+		err := errors.New("no files provided")
+		slog.Info(err.Error())
+		return nil, err
+	}
+
+	// Every file is checked before any of them is uploaded
+	media, err := fileMedia(files)
+	if err != nil {
+		return nil, err
+	}
+	defer closeMedia(media)
+
+	// Files the user already uploaded are not stored again
+	if err := dedupMedia(ctx, s.ma, userID, media); err != nil {
+		return nil, err
+	}
+
+	if err := storeFiles(ctx, s.store, media); err != nil {
+		return nil, fmt.Errorf("error uploading file: %w", err)
+	}
+
+	assets := make([]*models.MediaAsset, 0, len(media))
+	for _, m := range media {
+		assetID := m.AssetID
+		if assetID == 0 {
+			stored := newAsset(s.store, userID, m.Key, m.Hash, m.Info)
+			stored.Library = true
+			var created bool
+			assetID, created, err = s.ma.Create(ctx, nil, &stored)
+			if err != nil {
+				removeFiles(ctx, s.store, media)
+				return nil, fmt.Errorf("Error saving media asset")
+			}
+			m.AssetID = assetID
+			if created {
+				processAssets(s.mp, assetID)
+			} else {
+				removeCopy(ctx, s.store, m.Key)
+			}
+		}
+
+		asset, err := s.ma.GetByID(ctx, assetID)
+		if err != nil || asset == nil {
+			return nil, fmt.Errorf("Error getting media asset")
+		}
+		if err := s.addToLibrary(ctx, asset); err != nil {
+			return nil, err
+		}
+		assets = append(assets, asset)
+	}
+	return assets, nil
+}
+
+// addToLibrary keeps an asset the user uploaded with a post, now that they
+// uploaded it to the library too.
+func (s *mediaService) addToLibrary(ctx context.Context, asset *models.MediaAsset) error {
+	if asset.Library {
+		return nil
+	}
+	if err := s.ma.AddToLibrary(ctx, asset.ID); err != nil {
+		return fmt.Errorf("Error adding media asset to library")
+	}
+	asset.Library = true
+	return nil
+}
+
+func (s *mediaService) List(ctx context.Context, userID int64, mq *transfer.MediaQuery) ([]*models.MediaAsset, int, error) {
+	var err error
+
+	if userID == 0 {
+		err = errors.New("User is not valid")
+		slog.Info(err.Error())
+		return nil, 0, err
+	}
+
+	filter := models.MediaFilter{
+		MinSize: mq.MinSize,
+		MaxSize: mq.MaxSize,
+	}
+
+	switch mq.Type {
+	case "", "image", "video":
+		filter.Type = mq.Type
+	default:
+		err = fmt.Errorf("invalid type %q, expected image or video", mq.Type)
+		slog.Info(err.Error())
+		return nil, 0, err
+	}
+
+	if mq.From != "" {
+		if filter.From, err = parseHistoryDate(mq.From, false, time.UTC); err != nil {
+			return nil, 0, err
+		}
+	}
+	if mq.To != "" {
+		if filter.To, err = parseHistoryDate(mq.To, true, time.UTC); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	perPage := mq.PerPage
+	if perPage <= 0 {
+		perPage = defaultMediaPageSize
+	}
+	if perPage > maxMediaPageSize {
+		perPage = maxMediaPageSize
+	}
+	page := mq.Page
+	if page <= 0 {
+		page = 1
+	}
+	mq.Page, mq.PerPage = page, perPage
+	filter.Limit = perPage
+	filter.Offset = (page - 1) * perPage
+
+	assets, total, err := s.ma.List(ctx, userID, &filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Error getting media assets")
+	}
+
+	return assets, total, nil
+}
+
+// Info returns a media asset along with the posts that use it. The
+// renditions of videos show the progress of their conversion.
+func (s *mediaService) Info(ctx context.Context, userID, assetID int64) (*models.MediaAsset, []*models.Post, error) {
+	if err := s.checkAsset(ctx, userID, assetID); err != nil {
+		return nil, nil, err
+	}
+
+	asset, err := s.ma.GetByID(ctx, assetID)
+	if err != nil || asset == nil {
+		return nil, nil, fmt.Errorf("Error getting media asset")
+	}
+
+	asset.Renditions, err = s.vr.List(ctx, assetID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error getting renditions of media asset")
+	}
+
+	posts, err := s.pr.GetByAssetID(ctx, assetID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error getting posts of media asset")
+	}
+	if posts == nil {
+		posts = []*models.Post{}
+	}
+
+	return asset, posts, nil
+}
+
+// Remove deletes an asset that no post uses, from the database and from storage.
+func (s *mediaService) Remove(ctx context.Context, userID, assetID int64) error {
+	if err := s.checkAsset(ctx, userID, assetID); err != nil {
+		return err
+	}
+
+	asset, err := s.ma.GetByID(ctx, assetID)
+	if err != nil || asset == nil {
+		return fmt.Errorf("Error getting media asset")
+	}
+
+	used, err := s.ma.CountUsage(ctx, assetID)
+	if err != nil {
+		return fmt.Errorf("Error checking media asset usage")
+	}
+	if used > 0 {
+		err = fmt.Errorf("media asset is used by %d posts", used)
+		slog.Info(err.Error())
+		return err
+	}
+
+	// Variants and renditions are removed along with the asset, their files
+	// are listed first
+	variants, err := s.iv.List(ctx, assetID)
+	if err != nil {
+		return fmt.Errorf("Error getting image variants")
+	}
+	renditions, err := s.vr.List(ctx, assetID)
+	if err != nil {
+		return fmt.Errorf("Error getting video renditions")
+	}
+
+	removed, err := s.ma.Remove(ctx, assetID)
+	if err != nil {
+		return fmt.Errorf("Error removing media asset")
+	}
+	// A post started using it since it was counted
+	if !removed {
+		err = fmt.Errorf("media asset is used by a post")
+		slog.Info(err.Error())
+		return err
+	}
+
+	// The asset is gone either way, a file left behind is only wasted space
+	keys := []string{asset.FileName}
+	if key, ok := storageKey(s.store, asset.ThumbnailURL); ok {
+		keys = append(keys, key)
+	}
+	for _, variant := range variants {
+		keys = append(keys, variant.FileKey)
+	}
+	for _, rendition := range renditions {
+		if rendition.FileKey != "" {
+			keys = append(keys, rendition.FileKey)
+		}
+	}
+	for _, key := range keys {
+		if err := s.store.Delete(ctx, key); err != nil {
+			slog.Error("unable to delete media file", "asset_id", assetID, "file", key, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// PresignUpload lets the client upload a file straight to storage, without it
+// going through the API. The file becomes an asset once CompleteUpload has
+// checked it.
+func (s *mediaService) PresignUpload(ctx context.Context, userID int64, ur *transfer.UploadRequest) (*transfer.PresignedUpload, error) {
+	var err error
+
+	if ur == nil {
+		err = errors.New("upload data is nil")
+		slog.Error(err.Error())
+		return nil, err
+	}
+
+	if _, ok := uploadTypes[ur.FileType]; !ok {
+		err = fmt.Errorf("file type %s is not allowed", ur.FileType)
+		slog.Info(err.Error())
+		return nil, err
+	}
+
+	if ur.FileSize <= 0 || ur.FileSize > maxDirectUploadSize {
+		err = fmt.Errorf("file size must be between 1 byte and %s", formatSize(maxDirectUploadSize))
+		slog.Info(err.Error())
+		return nil, err
+	}
+
+	key, err := gonanoid.New()
+	if err != nil {
+		return nil, fmt.Errorf("Error creating file name")
+	}
+
+	url, headers, err := s.store.PresignPut(ctx, key, ur.FileType, ur.FileSize, presignedUploadExpiry)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating upload url")
+	}
+
+	upload := &models.MediaUpload{
+		UserID:    userID,
+		FileKey:   key,
+		FileName:  ur.FileName,
+		FileType:  ur.FileType,
+		FileSize:  ur.FileSize,
+		ExpiresAt: time.Now().Add(presignedUploadExpiry).UTC(),
+	}
+	uploadID, err := s.mu.Create(ctx, upload)
+	if err != nil {
+		return nil, fmt.Errorf("Error saving upload")
+	}
+
+	presigned := &transfer.PresignedUpload{
+		UploadID:  uploadID,
+		URL:       url,
+		Method:    http.MethodPut,
+		Headers:   make(map[string]string),
+		ExpiresAt: upload.ExpiresAt,
+	}
+	for name := range headers {
+		// The host is set by the client from the URL
+		if name != "Host" {
+			presigned.Headers[name] = headers.Get(name)
+		}
+	}
+	return presigned, nil
+}
+
+// CompleteUpload checks a file uploaded with PresignUpload and saves it as
+// a media asset. A file that isn't what was announced is deleted.
+func (s *mediaService) CompleteUpload(ctx context.Context, userID, uploadID int64) (*models.MediaAsset, error) {
+	upload, err := s.mu.GetByID(ctx, uploadID)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting upload")
+	}
+	if upload == nil || upload.UserID != userID {
+		err = errors.New("Upload doesn't exist")
+		slog.Info(err.Error())
+		return nil, err
+	}
+
+	size, err := s.store.Size(ctx, upload.FileKey)
+	if err != nil {
+		if time.Now().After(upload.ExpiresAt) {
+			s.discardUpload(ctx, upload)
+			err = errors.New("upload has expired")
+		} else {
+			err = errors.New("file has not been uploaded yet")
+		}
+		slog.Info(err.Error())
+		return nil, err
+	}
+
+	if size != upload.FileSize {
+		s.discardUpload(ctx, upload)
+		err = fmt.Errorf("uploaded file is %d bytes, expected %d", size, upload.FileSize)
+		slog.Info(err.Error())
+		return nil, err
+	}
+
+	head, err := s.store.ReadRange(ctx, upload.FileKey, 0, min(size, sniffSize))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading uploaded file")
+	}
+
+	mime, err := detectType(head)
+	if err == nil && mime != upload.FileType {
+		err = fmt.Errorf("uploaded file is %s, expected %s", mime, upload.FileType)
+	}
+	if err != nil {
+		s.discardUpload(ctx, upload)
+		slog.Info(err.Error())
+		return nil, err
+	}
+
+	file := &storageFile{ctx: ctx, store: s.store, key: upload.FileKey}
+	hash, err := hashFile(file, size)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading uploaded file")
+	}
+
+	// The user already has this file, the upload is only a copy of it
+	existing, err := s.ma.GetByHash(ctx, userID, hash)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting media asset")
+	}
+	if existing != nil {
+		s.discardUpload(ctx, upload)
+		if err := s.addToLibrary(ctx, existing); err != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+
+	info := probeFile(file, size, mime)
+	asset := newAsset(s.store, userID, upload.FileKey, hash, info)
+	asset.Library = true
+	assetID, created, err := s.ma.Create(ctx, nil, &asset)
+	if err != nil {
+		return nil, fmt.Errorf("Error saving media asset")
+	}
+
+	if !created {
+		// The same file was uploaded again meanwhile and saved first
+		s.discardUpload(ctx, upload)
+		return s.ma.GetByID(ctx, assetID)
+	}
+
+	if err := s.mu.Remove(ctx, upload.ID); err != nil {
+		slog.Error("unable to remove completed upload", "upload_id", upload.ID, "error", err)
+	}
+	processAssets(s.mp, assetID)
+
+	return s.ma.GetByID(ctx, assetID)
+}
+
+// SetCover picks the frame of a video used as its cover, in milliseconds
+// from the start. The thumbnail is made again from it in the background.
+func (s *mediaService) SetCover(ctx context.Context, userID, assetID int64, coverTimeMs int) (*models.MediaAsset, error) {
+	if err := s.checkAsset(ctx, userID, assetID); err != nil {
+		return nil, err
+	}
+
+	asset, err := s.ma.GetByID(ctx, assetID)
+	if err != nil || asset == nil {
+		return nil, fmt.Errorf("Error getting media asset")
+	}
+
+	if !strings.HasPrefix(asset.FileType, "video/") {
+		err = errors.New("only videos have a cover frame")
+		slog.Info(err.Error())
+		return nil, err
+	}
+	if coverTimeMs < 0 || (asset.Duration > 0 && coverTimeMs >= asset.Duration*1000) {
+		err = fmt.Errorf("cover time must be between 0 and %d ms", asset.Duration*1000)
+		slog.Info(err.Error())
+		return nil, err
+	}
+
+	if err := s.ma.UpdateCover(ctx, assetID, coverTimeMs); err != nil {
+		return nil, fmt.Errorf("Error saving cover time")
+	}
+	processAssets(s.mp, assetID)
+
+	asset.CoverTimeMs = coverTimeMs
+	return asset, nil
+}
+
+// RetryTranscode converts the renditions of a video that failed again. The
+// deliveries that failed for them can then be retried, and wait for them.
+func (s *mediaService) RetryTranscode(ctx context.Context, userID, assetID int64) (*models.MediaAsset, error) {
+	if err := s.checkAsset(ctx, userID, assetID); err != nil {
+		return nil, err
+	}
+
+	asset, err := s.ma.GetByID(ctx, assetID)
+	if err != nil || asset == nil {
+		return nil, fmt.Errorf("Error getting media asset")
+	}
+
+	if !strings.HasPrefix(asset.FileType, "video/") {
+		err = errors.New("only videos are converted")
+		slog.Info(err.Error())
+		return nil, err
+	}
+
+	if err := s.vr.Retry(ctx, assetID); err != nil {
+		slog.Info(err.Error())
+		return nil, err
+	}
+
+	asset.Renditions, err = s.vr.List(ctx, assetID)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting renditions of media asset")
+	}
+	return asset, nil
+}
+
+// discardUpload deletes a rejected upload along with its file.
+func (s *mediaService) discardUpload(ctx context.Context, upload *models.MediaUpload) {
+	if err := s.store.Delete(ctx, upload.FileKey); err != nil {
+		slog.Error("unable to delete uploaded file", "upload_id", upload.ID, "file", upload.FileKey, "error", err)
+	}
+	if err := s.mu.Remove(ctx, upload.ID); err != nil {
+		slog.Error("unable to remove upload", "upload_id", upload.ID, "error", err)
+	}
+}
+
+func (s *mediaService) checkAsset(ctx context.Context, userID, assetID int64) error {
+	var err error
+
+	if assetID == 0 {
+		err = errors.New("media asset id is not valid")
+		slog.Info(err.Error())
+		return err
+	}
+
+	isValid, err := s.ma.CheckByUserID(ctx, assetID, userID)
+	if err != nil {
+		return err
+	}
+
+	if !isValid {
+		err = errors.New("Media asset doesn't exist")
+		slog.Info(err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,589 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/h2non/filetype"
+	"github.com/h2non/filetype/types"
+	"github.com/maheshrc27/scheduling-api/internal/models"
+	"github.com/maheshrc27/scheduling-api/internal/repository"
+	"github.com/maheshrc27/scheduling-api/internal/transfer"
+	"github.com/maheshrc27/scheduling-api/pkg/utils"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+// maxRemoteFileSize matches the body limit of uploads.
+const maxRemoteFileSize = 100 * 1024 * 1024
+
+// maxConcurrentUploads bounds how many files of a post are stored at once.
+const maxConcurrentUploads = 4
+
+// hashBufferSize is how much of a file is read at once to hash it, files in
+// storage are read in ranges of that size.
+const hashBufferSize = 4 << 20
+
+// remoteFileClient fetches the files of posts created from URLs. Only public
+// addresses can be reached, checked once the host is resolved, so that
+// users can't make the server fetch from itself or its network.
+var remoteFileClient = &http.Client{
+	Timeout: 5 * time.Minute,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 30 * time.Second,
+			Control: checkRemoteDial,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+	},
+	CheckRedirect: checkRemoteRedirect,
+}
+
+// maxRemoteRedirects matches the default of http.Client.
+const maxRemoteRedirects = 10
+
+var errPrivateAddress = errors.New("media url must point to a public address")
+
+// sharedAddressSpace is the carrier-grade NAT range, private but not
+// reported by netip.Addr.IsPrivate.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddr tells whether an address can be fetched from: not loopback,
+// private, link-local, multicast or unspecified.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified() &&
+		!sharedAddressSpace.Contains(addr) &&
+		!(addr.Is4() && addr.As4()[0] == 0)
+}
+
+// checkRemoteDial runs before every connection of remoteFileClient, with the
+// address the host resolved to. Checking it there rather than before the
+// request means the host can't resolve to another address in between.
+func checkRemoteDial(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !publicAddr(addrPort.Addr()) {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// checkRemoteRedirect checks every URL a remote file is redirected to like
+// the URL it was fetched from.
+func checkRemoteRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRemoteRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRemoteRedirects)
+	}
+	return checkRemoteURL(req.Context(), req.URL)
+}
+
+// checkRemoteURL checks that a URL is http or https and that its host only
+// resolves to public addresses.
+func checkRemoteURL(ctx context.Context, u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("invalid media url %s", u)
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return fmt.Errorf("error resolving %s: %w", u.Hostname(), err)
+	}
+	for _, addr := range addrs {
+		if !publicAddr(addr) {
+			return errPrivateAddress
+		}
+	}
+	return nil
+}
+
+// mediaItem is a media of a new post: an existing asset, or an upload or
+// remote file that still has to be stored.
+type mediaItem struct {
+	AssetID     int64
+	URL         string    // asset or remote URL, upload://name for uploads
+	File        mediaFile // content to store, nil for assets
+	Key         string    // storage key once File is stored
+	Hash        string    // of the content of File
+	Info        MediaInfo
+	CoverTimeMs int   // cover frame of video assets
+	SavedID     int64 // asset saveMedia created for the stored file
+}
+
+// coverTime is the cover frame of a video, new files get the default one.
+func (m *mediaItem) coverTime() int {
+	if m.AssetID == 0 {
+		return defaultCoverTime(m.Info)
+	}
+	return m.CoverTimeMs
+}
+
+// mediaFile is the content of an upload or a remote file. Large files are
+// kept on disk, they are only ever read in parts.
+type mediaFile interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+	io.Closer
+}
+
+func mediaInfos(media []*mediaItem) []MediaInfo {
+	infos := make([]MediaInfo, 0, len(media))
+	for _, m := range media {
+		infos = append(infos, m.Info)
+	}
+	return infos
+}
+
+// closeMedia releases the files of media, once they are stored or not
+// needed anymore.
+func closeMedia(media []*mediaItem) {
+	for _, m := range media {
+		if m.File != nil {
+			m.File.Close()
+		}
+	}
+}
+
+// fileMedia opens uploaded files and checks their type.
+func fileMedia(files []*multipart.FileHeader) ([]*mediaItem, error) {
+	media := make([]*mediaItem, 0, len(files))
+	for _, file := range files {
+		fileContent, err := file.Open()
+		if err != nil {
+			closeMedia(media)
+			return nil, fmt.Errorf("error opening file: %w", err)
+		}
+		media = append(media, &mediaItem{URL: "upload://" + file.Filename, File: fileContent})
+
+		info, err := sniffFile(fileContent, file.Size)
+		if err != nil {
+			closeMedia(media)
+			return nil, err
+		}
+		media[len(media)-1].Info = info
+	}
+	return media, nil
+}
+
+// resolveMedia loads the assets and fetches the remote files a post is
+// created with, in display order.
+func (s *postService) resolveMedia(ctx context.Context, userID int64, inputs []transfer.PostMediaInput) ([]*mediaItem, error) {
+	inputs = append([]transfer.PostMediaInput(nil), inputs...)
+	sort.SliceStable(inputs, func(i, j int) bool {
+		return inputs[i].DisplayOrder < inputs[j].DisplayOrder
+	})
+
+	media := make([]*mediaItem, 0, len(inputs))
+	ok := false
+	defer func() {
+		if !ok {
+			closeMedia(media)
+		}
+	}()
+
+	for _, in := range inputs {
+		switch {
+		case in.AssetID != 0 && in.URL != "":
+			err := errors.New("a media is either an asset_id or a url, not both")
+			slog.Info(err.Error())
+			return nil, err
+
+		case in.AssetID != 0:
+			asset, err := s.ma.GetByID(ctx, in.AssetID)
+			if err != nil {
+				return nil, fmt.Errorf("Error getting media asset")
+			}
+			if asset == nil || asset.UserID != userID {
+				err = fmt.Errorf("media asset %d does not exist", in.AssetID)
+				slog.Info(err.Error())
+				return nil, err
+			}
+			media = append(media, &mediaItem{
+				AssetID:     asset.ID,
+				URL:         asset.FileURL,
+				Info:        assetInfo(asset),
+				CoverTimeMs: asset.CoverTimeMs,
+			})
+
+		case in.URL != "":
+			file, size, err := fetchRemoteFile(ctx, in.URL)
+			if err != nil {
+				return nil, err
+			}
+			media = append(media, &mediaItem{URL: in.URL, File: file})
+
+			info, err := sniffFile(file, size)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", in.URL, err)
+			}
+			media[len(media)-1].Info = info
+
+		default:
+			err := errors.New("media must have an asset_id or a url")
+			slog.Info(err.Error())
+			return nil, err
+		}
+	}
+
+	ok = true
+	return media, nil
+}
+
+// fetchRemoteFile downloads a file to a temporary file, which is removed
+// when closed.
+func fetchRemoteFile(ctx context.Context, rawURL string) (mediaFile, int64, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		err = fmt.Errorf("invalid media url %s", rawURL)
+		slog.Info(err.Error())
+		return nil, 0, err
+	}
+	if err := checkRemoteURL(ctx, u); err != nil {
+		slog.Info(err.Error())
+		return nil, 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error creating request: %w", err)
+	}
+
+	resp, err := remoteFileClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error fetching %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("error fetching %s: status %d", rawURL, resp.StatusCode)
+	}
+
+	file, err := os.CreateTemp("", "media-*")
+	if err != nil {
+		return nil, 0, fmt.Errorf("error creating temporary file: %w", err)
+	}
+	tmp := &tempFile{file}
+
+	size, err := io.Copy(tmp, io.LimitReader(resp.Body, maxRemoteFileSize+1))
+	if err != nil {
+		tmp.Close()
+		return nil, 0, fmt.Errorf("error reading %s: %w", rawURL, err)
+	}
+	if size > maxRemoteFileSize {
+		tmp.Close()
+		return nil, 0, fmt.Errorf("%s is larger than %s", rawURL, formatSize(maxRemoteFileSize))
+	}
+	return tmp, size, nil
+}
+
+// tempFile is a temporary file that is removed when closed.
+type tempFile struct {
+	*os.File
+}
+
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
+
+// sniffSize is how much of a file is read to check its type
+const sniffSize = 8 << 10
+
+var allowedMediaTypes = map[string]struct{}{
+	"mp4": {}, "mov": {}, "jpeg": {}, "png": {}, "jpg": {},
+}
+
+// detectType returns the MIME type of a file from its first bytes, when it
+// is a type that can be posted.
+func detectType(head []byte) (string, error) {
+	fileType, err := filetype.Match(head)
+	if err != nil || fileType == types.Unknown {
+		return "", fmt.Errorf("unsupported file type: %w", err)
+	}
+	if _, ok := allowedMediaTypes[fileType.Extension]; !ok {
+		return "", fmt.Errorf("file type %s is not allowed", fileType.Extension)
+	}
+	return fileType.MIME.Value, nil
+}
+
+// sniffFile checks that the type of a file is allowed and describes it. Only
+// the first bytes are read, and the movie header of videos.
+func sniffFile(file io.ReaderAt, size int64) (MediaInfo, error) {
+	head := make([]byte, min(size, sniffSize))
+	if _, err := file.ReadAt(head, 0); err != nil && err != io.EOF {
+		return MediaInfo{}, fmt.Errorf("error reading file: %w", err)
+	}
+
+	mime, err := detectType(head)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+	return probeFile(file, size, mime), nil
+}
+
+// probeFile reads the dimensions of a file of a known type and, for videos,
+// the duration, frame rate and codec. What can't be read is left to the
+// platforms to reject.
+func probeFile(file io.ReaderAt, size int64, mime string) MediaInfo {
+	info := MediaInfo{
+		MIME: mime,
+		Size: size,
+	}
+
+	if info.IsVideo() {
+		video, err := utils.MP4Info(file, size)
+		if err != nil {
+			slog.Info("unable to probe video", "error", err)
+			return info
+		}
+		info.Width = video.Width
+		info.Height = video.Height
+		info.Duration = video.Duration
+		info.FrameRate = video.FrameRate
+		info.Codec = video.Codec
+		return info
+	}
+
+	width, height, err := utils.ImageSize(file, size)
+	if err != nil {
+		slog.Info("unable to probe image", "error", err)
+		return info
+	}
+	info.Width = width
+	info.Height = height
+	return info
+}
+
+// hashFile returns the SHA-256 of the content of a file, in hex. Assets with
+// the same hash have the same content.
+func hashFile(file io.ReaderAt, size int64) (string, error) {
+	h := sha256.New()
+	if _, err := io.CopyBuffer(h, io.NewSectionReader(file, 0, size), make([]byte, hashBufferSize)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// dedupMedia hashes the files of media and swaps those the user already
+// stored for their assets, so that the same content is only stored once per
+// user. A file can't be given twice.
+func dedupMedia(ctx context.Context, ma repository.MediaAssetRepository, userID int64, media []*mediaItem) error {
+	assets := make(map[int64]int)
+	hashes := make(map[string]int)
+	for i, m := range media {
+		if m.File != nil {
+			hash, err := hashFile(m.File, m.Info.Size)
+			if err != nil {
+				return fmt.Errorf("error reading file: %w", err)
+			}
+			if j, ok := hashes[hash]; ok {
+				err = fmt.Errorf("media %d is the same file as media %d", i+1, j+1)
+				slog.Info(err.Error())
+				return err
+			}
+			hashes[hash] = i
+			m.Hash = hash
+
+			asset, err := ma.GetByHash(ctx, userID, hash)
+			if err != nil {
+				return fmt.Errorf("Error getting media asset")
+			}
+			if asset != nil {
+				m.File.Close()
+				*m = mediaItem{
+					AssetID:     asset.ID,
+					URL:         asset.FileURL,
+					Info:        assetInfo(asset),
+					CoverTimeMs: asset.CoverTimeMs,
+				}
+			}
+		}
+
+		if m.AssetID == 0 {
+			continue
+		}
+		if j, ok := assets[m.AssetID]; ok {
+			err := fmt.Errorf("media %d is the same file as media %d", i+1, j+1)
+			slog.Info(err.Error())
+			return err
+		}
+		assets[m.AssetID] = i
+	}
+	return nil
+}
+
+// storeFiles stores the files of the media that aren't assets yet, a few at
+// a time. Either all of them are stored or none.
+func storeFiles(ctx context.Context, store Storage, media []*mediaItem) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	slots := make(chan struct{}, maxConcurrentUploads)
+
+	for _, m := range media {
+		if m.File == nil || m.Key != "" {
+			continue
+		}
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(m *mediaItem) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			if err := storeFile(ctx, store, m); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				mu.Unlock()
+			}
+		}(m)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		removeFiles(context.WithoutCancel(ctx), store, media)
+		return firstErr
+	}
+	return nil
+}
+
+func storeFile(ctx context.Context, store Storage, m *mediaItem) error {
+	key, err := gonanoid.New()
+	if err != nil {
+		return err
+	}
+
+	if _, err := m.File.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := store.Put(ctx, key, m.File, m.Info.Size, m.Info.MIME); err != nil {
+		return err
+	}
+
+	m.Key = key
+	return nil
+}
+
+// removeFiles deletes the files storeFiles stored, when they didn't make it
+// into assets.
+func removeFiles(ctx context.Context, store Storage, media []*mediaItem) {
+	for _, m := range media {
+		if m.AssetID != 0 || m.Key == "" {
+			continue
+		}
+		if err := store.Delete(ctx, m.Key); err != nil {
+			slog.Error("unable to delete media file", "file", m.Key, "error", err)
+		}
+		m.Key = ""
+	}
+}
+
+// removeCopy deletes a stored file whose content was saved as an asset by
+// another upload meanwhile.
+func removeCopy(ctx context.Context, store Storage, key string) {
+	if err := store.Delete(ctx, key); err != nil {
+		slog.Error("unable to delete uploaded copy", "file", key, "error", err)
+	}
+}
+
+// saveMedia saves the stored files as assets and attaches all the media to
+// the post, in order from startOrder.
+func (s *postService) saveMedia(ctx context.Context, tx *sql.Tx, userID, postID int64, startOrder int, media []*mediaItem) error {
+	for i, m := range media {
+		assetID := m.AssetID
+		if assetID == 0 {
+			asset := newAsset(s.store, userID, m.Key, m.Hash, m.Info)
+			var created bool
+			var err error
+			assetID, created, err = s.ma.Create(ctx, tx, &asset)
+			if err != nil {
+				return fmt.Errorf("error saving media asset: %w", err)
+			}
+			if created {
+				m.SavedID = assetID
+			} else {
+				removeCopy(ctx, s.store, m.Key)
+				m.Key = ""
+			}
+		}
+
+		postMedia := models.PostMedia{
+			PostID:       postID,
+			AssetID:      assetID,
+			DisplayOrder: startOrder + i,
+		}
+		if err := s.pm.Create(ctx, tx, &postMedia); err != nil {
+			return fmt.Errorf("error saving media file: %w", err)
+		}
+	}
+	return nil
+}
+
+// newAssetIDs returns the IDs of the assets saveMedia created for stored
+// files.
+func newAssetIDs(media []*mediaItem) []int64 {
+	var ids []int64
+	for _, m := range media {
+		if m.SavedID != 0 {
+			ids = append(ids, m.SavedID)
+		}
+	}
+	return ids
+}
+
+// newAsset describes a stored file as a media asset, hash is the hash of its
+// content.
+func newAsset(store Storage, userID int64, key, hash string, info MediaInfo) models.MediaAsset {
+	return models.MediaAsset{
+		UserID:      userID,
+		FileName:    key,
+		ContentHash: hash,
+		FileType:    info.MIME,
+		FileSize:    info.Size,
+		FileURL:     store.URL(key),
+		Width:       info.Width,
+		Height:      info.Height,
+		Duration:    int(math.Ceil(info.Duration.Seconds())),
+		FrameRate:   math.Round(info.FrameRate*100) / 100,
+		Codec:       info.Codec,
+		CoverTimeMs: defaultCoverTime(info),
+	}
+}
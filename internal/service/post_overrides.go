@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/maheshrc27/scheduling-api/internal/models"
+	"github.com/maheshrc27/scheduling-api/internal/transfer"
+)
+
+var overridePlatforms = map[string]struct{}{
+	"tiktok": {}, "instagram": {}, "youtube": {},
+}
+
+// parseOverrides reads the JSON list of overrides sent with a new post.
+func parseOverrides(value string) ([]transfer.PostOverride, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var overrides []transfer.PostOverride
+	if err := json.Unmarshal([]byte(value), &overrides); err != nil {
+		err = fmt.Errorf("invalid overrides format: %w", err)
+		slog.Info(err.Error())
+		return nil, err
+	}
+	return overrides, nil
+}
+
+// validateOverrides checks that every override targets either one of the
+// selected accounts or a platform, once, and cleans up their hashtags.
+func validateOverrides(overrides []transfer.PostOverride, accounts []int) error {
+	selected := make(map[int64]bool, len(accounts))
+	for _, id := range accounts {
+		selected[int64(id)] = true
+	}
+
+	seen := make(map[string]bool, len(overrides))
+	for i := range overrides {
+		o := &overrides[i]
+
+		var key string
+		switch {
+		case o.AccountID != 0 && o.Platform != "":
+			return errors.New("an override is either for an account or for a platform, not both")
+		case o.AccountID != 0:
+			if !selected[o.AccountID] {
+				return fmt.Errorf("override for account %d which is not selected", o.AccountID)
+			}
+			key = fmt.Sprintf("account:%d", o.AccountID)
+		case o.Platform != "":
+			if _, ok := overridePlatforms[o.Platform]; !ok {
+				return fmt.Errorf("override for unsupported platform %s", o.Platform)
+			}
+			key = "platform:" + o.Platform
+		default:
+			return errors.New("override must have an account_id or a platform")
+		}
+
+		if seen[key] {
+			return fmt.Errorf("more than one override for %s", strings.Replace(key, ":", " ", 1))
+		}
+		seen[key] = true
+
+		switch o.Options.ImageFit {
+		case "", ImageFitCrop, ImageFitPad:
+		default:
+			return fmt.Errorf("invalid image_fit %q, expected crop or pad", o.Options.ImageFit)
+		}
+
+		hashtags := make([]string, 0, len(o.Hashtags))
+		for _, tag := range o.Hashtags {
+			tag = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(tag), "#"))
+			if tag != "" {
+				hashtags = append(hashtags, tag)
+			}
+		}
+		o.Hashtags = hashtags
+	}
+	return nil
+}
+
+// saveOverrides stores the overrides of a post, its accounts must already be
+// saved.
+func (s *postService) saveOverrides(ctx context.Context, tx *sql.Tx, postID int64, overrides []transfer.PostOverride) error {
+	for _, o := range overrides {
+		override := o.Override
+		if o.AccountID != 0 {
+			if err := s.sa.SetOverride(ctx, tx, postID, o.AccountID, &override); err != nil {
+				return fmt.Errorf("error saving override of account %d: %w", o.AccountID, err)
+			}
+			continue
+		}
+
+		po := models.PlatformOverride{
+			PostID:   postID,
+			Platform: o.Platform,
+			Override: override,
+		}
+		if err := s.po.Create(ctx, tx, &po); err != nil {
+			return fmt.Errorf("error saving override of platform %s: %w", o.Platform, err)
+		}
+	}
+	return nil
+}
+
+// clearOverrides removes the overrides of a post for its platforms and for
+// the given deliveries.
+func (s *postService) clearOverrides(ctx context.Context, tx *sql.Tx, postID int64, deliveries []*models.SelectedAccount) error {
+	if err := s.po.RemoveByPostID(ctx, tx, postID); err != nil {
+		return fmt.Errorf("error removing platform overrides: %w", err)
+	}
+	for _, d := range deliveries {
+		if d.Override == nil {
+			continue
+		}
+		if err := s.sa.SetOverride(ctx, tx, postID, d.AccountID, nil); err != nil {
+			return fmt.Errorf("error removing override of account %d: %w", d.AccountID, err)
+		}
+	}
+	return nil
+}
+
+// accountOverrides lists the overrides of the given deliveries, limited to
+// the accounts in keep when it isn't nil.
+func accountOverrides(deliveries []*models.SelectedAccount, keep []int) []transfer.PostOverride {
+	var overrides []transfer.PostOverride
+	for _, d := range deliveries {
+		if d.Override == nil {
+			continue
+		}
+		if keep != nil && !containsID(toIDs(keep), d.AccountID) {
+			continue
+		}
+		overrides = append(overrides, transfer.PostOverride{AccountID: d.AccountID, Override: *d.Override})
+	}
+	return overrides
+}
+
+// platformOverrides lists the given platform overrides as inputs.
+func platformOverrides(overrides []*models.PlatformOverride) []transfer.PostOverride {
+	inputs := make([]transfer.PostOverride, 0, len(overrides))
+	for _, po := range overrides {
+		inputs = append(inputs, transfer.PostOverride{Platform: po.Platform, Override: po.Override})
+	}
+	return inputs
+}
+
+func toIDs(ids []int) []int64 {
+	out := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, int64(id))
+	}
+	return out
+}
@@ -6,172 +6,368 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"log"
 	"log/slog"
 	"mime/multipart"
+	"strings"
 	"time"
 
-	"github.com/h2non/filetype"
-	"github.com/h2non/filetype/types"
 	"github.com/maheshrc27/scheduling-api/internal/models"
 	"github.com/maheshrc27/scheduling-api/internal/repository"
 	"github.com/maheshrc27/scheduling-api/internal/transfer"
+	"github.com/maheshrc27/scheduling-api/pkg/utils"
 	gonanoid "github.com/matoous/go-nanoid/v2"
 )
 
 type PostService interface {
-	CreatePost(ctx context.Context, userID int64, pc *transfer.PostCreation, files []*multipart.FileHeader) (int64, time.Duration, error)
+	CreatePost(ctx context.Context, userID int64, pc *transfer.PostCreation, files []*multipart.FileHeader) (int64, error)
+	CreatePostJSON(ctx context.Context, userID int64, pj *transfer.PostCreationJSON) (int64, error)
+	Preview(ctx context.Context, userID int64, pc *transfer.PostCreation, files []*multipart.FileHeader) (*PostPreview, error)
+	PreviewJSON(ctx context.Context, userID int64, pj *transfer.PostCreationJSON) (*PostPreview, error)
 	List(ctx context.Context, userID int64) ([]*models.Post, error)
+	ListDrafts(ctx context.Context, userID int64) ([]*models.Post, error)
 	PostInfo(ctx context.Context, postID, userID int64) (*models.Post, error)
+	Update(ctx context.Context, userID, postID int64, pu *transfer.PostUpdate) error
 	Remove(ctx context.Context, userID, postID int64) error
+	ScheduleDraft(ctx context.Context, userID, postID int64, scheduledTime, timezone string, files []*multipart.FileHeader) error
+	Skip(ctx context.Context, userID, postID int64) (int64, error)
+	ScheduleNextOccurrence(ctx context.Context, postID int64) (int64, error)
+	Retry(ctx context.Context, userID, postID int64, accountIDs []int64) ([]int64, error)
+	Defer(ctx context.Context, postID int64, delay time.Duration) error
 }
 
 type postService struct {
-	db *sql.DB
-	pr repository.PostRepository
-	sa repository.SelectedAccountRepository
-	ac repository.SocialAccountRepository
-	ma repository.MediaAssetRepository
-	pm repository.PostMediaRepository
-	sr repository.SubscriptionRepository
-	r2 R2Service
+	db    *sql.DB
+	ur    repository.UserRepository
+	pr    repository.PostRepository
+	sa    repository.SelectedAccountRepository
+	po    repository.PlatformOverrideRepository
+	ac    repository.SocialAccountRepository
+	ma    repository.MediaAssetRepository
+	pm    repository.PostMediaRepository
+	sr    repository.SubscriptionRepository
+	st    repository.SettingsRepository
+	store Storage
+	ps    PostScheduler
+	mp    MediaProcessor
 }
 
 func NewPostService(
 	db *sql.DB,
+	ur repository.UserRepository,
 	pr repository.PostRepository,
 	sa repository.SelectedAccountRepository,
+	po repository.PlatformOverrideRepository,
 	ma repository.MediaAssetRepository,
 	ac repository.SocialAccountRepository,
 	pm repository.PostMediaRepository,
 	sr repository.SubscriptionRepository,
-	r2 R2Service) PostService {
+	st repository.SettingsRepository,
+	store Storage,
+	ps PostScheduler,
+	mp MediaProcessor) PostService {
 	return &postService{
-		db: db,
-		pr: pr,
-		sa: sa,
-		ac: ac,
-		ma: ma,
-		pm: pm,
-		sr: sr,
-		r2: r2,
+		db:    db,
+		ur:    ur,
+		pr:    pr,
+		sa:    sa,
+		po:    po,
+		ac:    ac,
+		ma:    ma,
+		pm:    pm,
+		sr:    sr,
+		st:    st,
+		store: store,
+		ps:    ps,
+		mp:    mp,
 	}
 }
 
-func (s *postService) CreatePost(ctx context.Context, userID int64, pc *transfer.PostCreation, files []*multipart.FileHeader) (int64, time.Duration, error) {
+func (s *postService) CreatePost(ctx context.Context, userID int64, pc *transfer.PostCreation, files []*multipart.FileHeader) (int64, error) {
+	if err := s.checkQuota(ctx, userID); err != nil {
+		return 0, err
+	}
+
+	selectedAccounts, overrides, err := parsePostCreation(pc)
+	if err != nil {
+		return 0, err
+	}
+
+	media, err := fileMedia(files)
+	if err != nil {
+		return 0, err
+	}
+	defer closeMedia(media)
+
+	return s.createPost(ctx, userID, pc, selectedAccounts, overrides, media)
+}
+
+// CreatePostJSON creates a post from existing media assets and remote files
+// instead of uploads.
+func (s *postService) CreatePostJSON(ctx context.Context, userID int64, pj *transfer.PostCreationJSON) (int64, error) {
+	if pj == nil {
+		err := errors.New("post creation data is nil")
+		slog.Error(err.Error())
+		return 0, err
+	}
+
+	if err := s.checkQuota(ctx, userID); err != nil {
+		return 0, err
+	}
+
+	media, err := s.resolveMedia(ctx, userID, pj.Media)
+	if err != nil {
+		return 0, err
+	}
+	defer closeMedia(media)
 
+	return s.createPost(ctx, userID, postCreationJSON(pj), pj.SelectedAccounts, pj.Overrides, media)
+}
+
+// postCreationJSON returns the fields a JSON post creation shares with the
+// form, its accounts, overrides and media are passed separately.
+func postCreationJSON(pj *transfer.PostCreationJSON) *transfer.PostCreation {
+	return &transfer.PostCreation{
+		Caption:       pj.Caption,
+		Title:         pj.Title,
+		ScheduledTime: pj.ScheduledTime,
+		Draft:         pj.Draft,
+		Recurrence:    pj.Recurrence,
+		Queue:         pj.Queue,
+		Category:      pj.Category,
+		Timezone:      pj.Timezone,
+	}
+}
+
+func (s *postService) checkQuota(ctx context.Context, userID int64) error {
 	isPremium, err := s.sr.CheckPremium(ctx, userID)
 	if err != nil {
-		return 0, 0, fmt.Errorf("Error checking user subscription")
+		return fmt.Errorf("Error checking user subscription")
 	}
 
 	if !isPremium {
 		postsNum, err := s.pr.CountCurrentMonth(ctx, userID)
 		if err != nil {
-			return 0, 0, fmt.Errorf("Error checking number of posts")
+			return fmt.Errorf("Error checking number of posts")
 		}
 
 		if postsNum > 2000 {
-			return 0, 0, fmt.Errorf("Only 5 posts are allowed for free users.")
+			return fmt.Errorf("Only 5 posts are allowed for free users.")
 		}
 	}
+	return nil
+}
 
-	// Validate input parameters
-	if pc == nil {
-		err := errors.New("post creation data is nil")
-		slog.Error(err.Error())
-		return 0, 0, err
-	}
-	if pc.Caption == "" {
-		err := errors.New("caption cannot be empty")
-		slog.Info(err.Error())
-		return 0, 0, err
-	}
-
-	// Parse scheduled time
-	scheduledTime, err := time.Parse("2006-01-02T15:04", pc.ScheduledTime)
+func (s *postService) createPost(ctx context.Context, userID int64, pc *transfer.PostCreation, selectedAccounts []int, overrides []transfer.PostOverride, media []*mediaItem) (int64, error) {
+	post, err := s.newPost(ctx, userID, pc, selectedAccounts, overrides, len(media))
 	if err != nil {
-		err = fmt.Errorf("invalid scheduled time format: %w", err)
-		slog.Error(err.Error())
-		return 0, 0, err
+		return 0, err
 	}
 
-	// Parse selected accounts
-	var selectedAccounts []int
-	if err := json.Unmarshal([]byte(pc.SelectedAccounts), &selectedAccounts); err != nil {
-		err = fmt.Errorf("invalid selected accounts format: %w", err)
-		slog.Error(err.Error())
-		return 0, 0, err
-	}
-	if len(selectedAccounts) == 0 {
-		err := errors.New("no social accounts selected")
-		slog.Error(err.Error())
-		return 0, 0, err
+	// Check the post against the limits of each platform before anything is saved
+	if !pc.Draft {
+		deliveries, platformOverrides, err := s.newDeliveries(ctx, userID, selectedAccounts, overrides)
+		if err != nil {
+			return 0, err
+		}
+		if err := validatePost(post, deliveries, platformOverrides, mediaInfos(media)); err != nil {
+			slog.Info(err.Error())
+			return 0, err
+		}
 	}
 
-	// Validate files
-	if len(files) == 0 {
-		err := errors.New("no files provided for the post")
-		slog.Error(err.Error())
-		return 0, 0, err
+	// Files the user already uploaded are attached as their assets instead
+	// of being stored again
+	if err := dedupMedia(ctx, s.ma, userID, media); err != nil {
+		return 0, err
 	}
 
-	postType := PostTypeSingle
-	if len(files) > 1 {
-		postType = PostTypeMultiple
+	// Files are stored before the transaction, so that it isn't held open
+	// during uploads
+	if err := storeFiles(ctx, s.store, media); err != nil {
+		return 0, fmt.Errorf("error uploading files: %w", err)
 	}
 
 	// Begin database transaction
 	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to start transaction: %w", err)
+		removeFiles(ctx, s.store, media)
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
 	}
 	defer func() {
 		if p := recover(); p != nil {
 			tx.Rollback()
+			removeFiles(ctx, s.store, media)
 			panic(p)
 		} else if err != nil {
 			tx.Rollback()
+			removeFiles(ctx, s.store, media)
 		}
 	}()
 
-	// Create post
-	post := models.Post{
-		UserID:        userID,
-		PostType:      postType,
-		Caption:       pc.Caption,
-		Title:         pc.Title,
-		ScheduledTime: scheduledTime,
-		Status:        PostStatusScheduled,
-	}
-
-	postID, err := s.pr.Create(ctx, tx, &post)
+	postID, err := s.pr.Create(ctx, tx, post)
 	if err != nil {
-		return 0, 0, fmt.Errorf("error creating post: %w", err)
+		return 0, fmt.Errorf("error creating post: %w", err)
 	}
 
 	// Validate and save selected accounts
-	if err := s.saveSelectedAccounts(ctx, tx, userID, postID, selectedAccounts); err != nil {
-		return 0, 0, fmt.Errorf("error processing selected accounts: %w", err)
+	if err = s.saveSelectedAccounts(ctx, tx, userID, postID, selectedAccounts); err != nil {
+		return 0, fmt.Errorf("error processing selected accounts: %w", err)
+	}
+
+	if err = s.saveOverrides(ctx, tx, postID, overrides); err != nil {
+		return 0, fmt.Errorf("error processing overrides: %w", err)
 	}
 
 	// Process and save files
-	if err := s.processFiles(ctx, tx, userID, postID, files); err != nil {
-		return 0, 0, fmt.Errorf("error processing files: %w", err)
+	if err = s.saveMedia(ctx, tx, userID, postID, 0, media); err != nil {
+		return 0, fmt.Errorf("error processing files: %w", err)
 	}
 
 	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	processAssets(s.mp, newAssetIDs(media)...)
 
-	delay := time.Until(scheduledTime)
-	if delay < 0 {
-		delay = 0
+	if pc.Draft {
+		return postID, nil
+	}
+
+	// Queue the publishing task; without it the post would never go out
+	if err := s.schedule(ctx, postID, post.ScheduledTime); err != nil {
+		if rmErr := s.pr.Remove(ctx, postID); rmErr != nil {
+			slog.Error("unable to remove unscheduled post", "post_id", postID, "error", rmErr)
+		}
+		return 0, fmt.Errorf("error scheduling post: %w", err)
+	}
+
+	return postID, nil
+}
+
+// newPost checks the input of a new post and returns the post to save,
+// nothing is saved.
+func (s *postService) newPost(ctx context.Context, userID int64, pc *transfer.PostCreation, selectedAccounts []int, overrides []transfer.PostOverride, mediaCount int) (*models.Post, error) {
+	var err error
+
+	// Drafts can be saved incomplete, everything is checked when they are scheduled
+	if !pc.Draft && pc.Caption == "" {
+		err := errors.New("caption cannot be empty")
+		slog.Info(err.Error())
+		return nil, err
+	}
+
+	// Parse scheduled time, queued posts get theirs from the posting slots
+	var scheduledTime time.Time
+	zone := pc.Timezone
+	if zone == "" {
+		zone = userZone(ctx, s.ur, userID)
+	} else if _, err := LoadZone(zone); err != nil {
+		slog.Info(err.Error())
+		return nil, err
+	}
+	queued := pc.Queue && !pc.Draft
+	if !queued && (!pc.Draft || pc.ScheduledTime != "") {
+		scheduledTime, zone, err = parseScheduledTime(pc.ScheduledTime, pc.Timezone, zone)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !pc.Draft && len(selectedAccounts) == 0 {
+		err := errors.New("no social accounts selected")
+		slog.Error(err.Error())
+		return nil, err
+	}
+
+	if err := validateOverrides(overrides, selectedAccounts); err != nil {
+		slog.Info(err.Error())
+		return nil, err
+	}
+
+	// Parse recurrence rule
+	recurrence := strings.TrimPrefix(strings.TrimSpace(pc.Recurrence), "RRULE:")
+	if recurrence != "" {
+		if queued {
+			err := errors.New("recurring posts can't be added to the queue")
+			slog.Info(err.Error())
+			return nil, err
+		}
+		if _, err := utils.ParseRRule(recurrence); err != nil {
+			err = fmt.Errorf("invalid recurrence: %w", err)
+			slog.Info(err.Error())
+			return nil, err
+		}
+	}
+
+	if queued {
+		scheduledTime, err = s.queueTime(ctx, userID, selectedAccounts, pc.Category, zone)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Validate files
+	if !pc.Draft && mediaCount == 0 {
+		err := errors.New("no files provided for the post")
+		slog.Error(err.Error())
+		return nil, err
+	}
+
+	status := PostStatusScheduled
+	if pc.Draft {
+		status = models.PostStatusDraft
+	}
+
+	post := &models.Post{
+		UserID:        userID,
+		PostType:      postTypeFor(mediaCount),
+		Caption:       pc.Caption,
+		Title:         pc.Title,
+		ScheduledTime: scheduledTime,
+		Status:        status,
+		Recurrence:    recurrence,
+		SeriesStart:   scheduledTime,
+		Occurrence:    1,
+		Queued:        queued,
+		Category:      pc.Category,
+		Timezone:      zone,
+	}
+
+	return post, nil
+}
+
+// parsePostCreation reads the selected accounts and the overrides of a post
+// sent as a form.
+func parsePostCreation(pc *transfer.PostCreation) ([]int, []transfer.PostOverride, error) {
+	if pc == nil {
+		err := errors.New("post creation data is nil")
+		slog.Error(err.Error())
+		return nil, nil, err
+	}
+
+	// Parse selected accounts
+	var selectedAccounts []int
+	if !pc.Draft || pc.SelectedAccounts != "" {
+		if err := json.Unmarshal([]byte(pc.SelectedAccounts), &selectedAccounts); err != nil {
+			err = fmt.Errorf("invalid selected accounts format: %w", err)
+			slog.Error(err.Error())
+			return nil, nil, err
+		}
 	}
 
-	return postID, delay, nil
+	// Parse per account and per platform overrides
+	overrides, err := parseOverrides(pc.Overrides)
+	if err != nil {
+		return nil, nil, err
+	}
+	return selectedAccounts, overrides, nil
+}
+
+func postTypeFor(mediaCount int) string {
+	if mediaCount > 1 {
+		return PostTypeMultiple
+	}
+	return PostTypeSingle
 }
 
 func (s *postService) saveSelectedAccounts(ctx context.Context, tx *sql.Tx, userID, postID int64, accounts []int) error {
@@ -195,73 +391,62 @@ func (s *postService) saveSelectedAccounts(ctx context.Context, tx *sql.Tx, user
 	return nil
 }
 
-func (s *postService) processFiles(ctx context.Context, tx *sql.Tx, userID, postID int64, files []*multipart.FileHeader) error {
-	allowedTypes := map[string]struct{}{
-		"mp4": {}, "mov": {}, "jpeg": {}, "png": {}, "jpg": {},
+// postMedia describes the media already saved for a post, in order.
+func (s *postService) postMedia(ctx context.Context, postID int64) ([]MediaInfo, error) {
+	postMedias, err := s.pm.ListByPostID(ctx, postID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting post media: %w", err)
 	}
 
-	for i, file := range files {
-		fileContent, err := file.Open()
-		if err != nil {
-			return fmt.Errorf("error opening file: %w", err)
-		}
-		defer fileContent.Close()
-
-		fileBytes, err := io.ReadAll(fileContent)
+	media := make([]MediaInfo, 0, len(postMedias))
+	for _, pm := range postMedias {
+		asset, err := s.ma.GetByID(ctx, pm.AssetID)
 		if err != nil {
-			return fmt.Errorf("error reading file content: %w", err)
-		}
-
-		fileType, err := filetype.Match(fileBytes)
-		if err != nil || fileType == types.Unknown {
-			return fmt.Errorf("unsupported file type: %w", err)
+			return nil, fmt.Errorf("error getting media asset: %w", err)
 		}
-		if _, ok := allowedTypes[fileType.Extension]; !ok {
-			return fmt.Errorf("file type %s is not allowed", fileType.Extension)
+		if asset == nil {
+			continue
 		}
+		media = append(media, assetInfo(asset))
+	}
+	return media, nil
+}
 
-		assetID, err := s.saveFile(ctx, tx, userID, fileType.MIME.Value, fileBytes)
+// newDeliveries builds the deliveries and platform overrides a post will
+// have with the given accounts and overrides, for validating it before it is
+// saved.
+func (s *postService) newDeliveries(ctx context.Context, userID int64, accounts []int, overrides []transfer.PostOverride) ([]*models.SelectedAccount, []*models.PlatformOverride, error) {
+	deliveries := make([]*models.SelectedAccount, 0, len(accounts))
+	for _, accountID := range accounts {
+		account, err := s.ac.GetByID(ctx, int64(accountID))
 		if err != nil {
-			return fmt.Errorf("error uploading file: %w", err)
-		}
-
-		postMedia := models.PostMedia{
-			PostID:       postID,
-			AssetID:      assetID,
-			DisplayOrder: i,
+			return nil, nil, fmt.Errorf("error checking social account %d: %w", accountID, err)
 		}
-		if err := s.pm.Create(ctx, tx, &postMedia); err != nil {
-			return fmt.Errorf("error saving media file: %w", err)
+		if account == nil || account.UserID != userID {
+			err = fmt.Errorf("social account %d does not exist", accountID)
+			slog.Info(err.Error())
+			return nil, nil, err
 		}
-	}
-	return nil
-}
-
-func (s *postService) saveFile(ctx context.Context, tx *sql.Tx, userID int64, fileType string, file []byte) (int64, error) {
-	id, err := gonanoid.New()
-	if err != nil {
-		log.Println(err.Error())
-		return 0, err
-	}
-	err = s.r2.UploadToR2(ctx, id, file, fileType)
-	if err != nil {
-		fmt.Println(err.Error())
-		return 0, err
-	}
-
-	ma := models.MediaAsset{
-		UserID:   userID,
-		FileName: id,
-		FileType: fileType,
-		FileURL:  fmt.Sprintf("https://pub-f8f43aa198a449518df6744ec9ce452c.r2.dev/%s", id),
+		deliveries = append(deliveries, &models.SelectedAccount{
+			AccountID: int64(accountID),
+			Platform:  account.Platform,
+		})
 	}
 
-	assetID, err := s.ma.Create(ctx, tx, &ma)
-	if err != nil {
-		return 0, err
+	var platformOverrides []*models.PlatformOverride
+	for i := range overrides {
+		o := overrides[i]
+		if o.Platform != "" {
+			platformOverrides = append(platformOverrides, &models.PlatformOverride{Platform: o.Platform, Override: o.Override})
+			continue
+		}
+		for _, d := range deliveries {
+			if d.AccountID == o.AccountID {
+				d.Override = &o.Override
+			}
+		}
 	}
-
-	return assetID, nil
+	return deliveries, platformOverrides, nil
 }
 
 func (s *postService) PostInfo(ctx context.Context, postID, userID int64) (*models.Post, error) {
@@ -295,47 +480,922 @@ func (s *postService) PostInfo(ctx context.Context, postID, userID int64) (*mode
 		return nil, fmt.Errorf("Error getting post info")
 	}
 
+	post.Deliveries, err = s.sa.ListByPostID(ctx, postID)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting post deliveries")
+	}
+
+	post.PlatformOverrides, err = s.po.ListByPostID(ctx, postID)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting post overrides")
+	}
+
+	s.localize(ctx, userID, post)
 	return post, nil
 }
 
 func (s *postService) List(ctx context.Context, userID int64) ([]*models.Post, error) {
 	posts, err := s.pr.GetByUserID(ctx, userID)
 	if err != nil {
-		return nil, fmt.Errorf("Error getting API keys")
+		return nil, fmt.Errorf("Error getting posts")
 	}
+
+	for _, post := range posts {
+		post.Deliveries, err = s.sa.ListByPostID(ctx, post.ID)
+		if err != nil {
+			return nil, fmt.Errorf("Error getting post deliveries")
+		}
+	}
+
+	s.localize(ctx, userID, posts...)
 	return posts, nil
 }
 
-func (s *postService) Remove(ctx context.Context, userID, postID int64) error {
-	var err error
+func (s *postService) ListDrafts(ctx context.Context, userID int64) ([]*models.Post, error) {
+	posts, err := s.pr.GetDraftsByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting drafts")
+	}
 
-	if userID == 0 {
-		err = errors.New("User is not valid")
-		slog.Info(err.Error())
-		return err
+	for _, post := range posts {
+		post.Deliveries, err = s.sa.ListByPostID(ctx, post.ID)
+		if err != nil {
+			return nil, fmt.Errorf("Error getting post deliveries")
+		}
 	}
 
-	if postID == 0 {
-		err = errors.New("post_id is not valid")
-		slog.Info(err.Error())
+	s.localize(ctx, userID, posts...)
+	return posts, nil
+}
+
+func (s *postService) Remove(ctx context.Context, userID, postID int64) error {
+	post, err := s.PostInfo(ctx, postID, userID)
+	if err != nil {
 		return err
 	}
 
-	isValid, err := s.pr.CheckByUserID(ctx, postID, userID)
+	// Drop the pending task before the row so it can't fire for a missing post
+	cancelled := false
+	if post.TaskID != "" {
+		if err = s.ps.Cancel(post.TaskID); err != nil {
+			slog.Error("unable to cancel scheduled task", "post_id", postID, "task_id", post.TaskID, "error", err)
+			return fmt.Errorf("Error cancelling scheduled post")
+		}
+		cancelled = true
+	}
+
+	err = s.pr.Remove(ctx, postID)
+	if err != nil {
+		if cancelled {
+			s.restoreSchedule(ctx, postID)
+		}
+		return fmt.Errorf("Error removing post")
+	}
+
+	if post.Queued && post.Status == PostStatusScheduled {
+		s.reshuffleQueue(ctx, userID, post.ScheduledTime)
+	}
+
+	return nil
+}
+
+func (s *postService) Update(ctx context.Context, userID, postID int64, pu *transfer.PostUpdate) error {
+	post, err := s.PostInfo(ctx, postID, userID)
 	if err != nil {
 		return err
 	}
 
-	if !isValid {
-		err = errors.New("Post doesn't exist")
+	if pu == nil {
+		err = errors.New("post update data is nil")
+		slog.Error(err.Error())
+		return err
+	}
+
+	isDraft := post.Status == models.PostStatusDraft
+	if post.Status != PostStatusScheduled && !isDraft {
+		err = fmt.Errorf("only scheduled posts and drafts can be edited, post is %s", post.Status)
 		slog.Info(err.Error())
 		return err
 	}
 
-	err = s.pr.Remove(ctx, postID)
-	if err != nil {
-		return fmt.Errorf("Error removing post")
+	if pu.Caption != nil {
+		if *pu.Caption == "" && !isDraft {
+			err = errors.New("caption cannot be empty")
+			slog.Info(err.Error())
+			return err
+		}
+		post.Caption = *pu.Caption
+	}
+
+	if pu.Title != nil {
+		post.Title = *pu.Title
+	}
+
+	zone := post.Timezone
+	if zone == "" {
+		zone = userZone(ctx, s.ur, userID)
+	}
+	var newZone string
+	if pu.Timezone != nil {
+		newZone = *pu.Timezone
+	}
+
+	rescheduled := false
+	if pu.ScheduledTime == nil && newZone != "" && newZone != post.Timezone && !post.ScheduledTime.IsZero() {
+		// A new zone alone keeps the wall clock time of the post
+		wall := post.ScheduledTime.In(locationOrUTC(zone)).Format("2006-01-02T15:04")
+		pu.ScheduledTime = &wall
+	}
+	if pu.ScheduledTime == nil && newZone != "" {
+		if _, err = LoadZone(newZone); err != nil {
+			slog.Info(err.Error())
+			return err
+		}
+		post.Timezone = newZone
+	}
+	if pu.ScheduledTime != nil {
+		var scheduledTime time.Time
+		// A draft's time can be cleared, it is only required once scheduled
+		if *pu.ScheduledTime != "" || !isDraft {
+			scheduledTime, zone, err = parseScheduledTime(*pu.ScheduledTime, newZone, zone)
+			if err != nil {
+				return err
+			}
+			post.Timezone = zone
+		}
+		if !scheduledTime.Equal(post.ScheduledTime) {
+			// A time set by hand takes the post out of the queue
+			post.Queued = false
+		}
+		rescheduled = !isDraft && !scheduledTime.Equal(post.ScheduledTime)
+		post.ScheduledTime = scheduledTime
+		if post.Occurrence <= 1 {
+			// Moving the first occurrence moves the whole series
+			post.SeriesStart = scheduledTime
+		}
+	}
+
+	if pu.SelectedAccounts != nil && len(pu.SelectedAccounts) == 0 && !isDraft {
+		err = errors.New("no social accounts selected")
+		slog.Error(err.Error())
+		return err
+	}
+
+	// Overrides are checked against the accounts the post will have
+	accounts := pu.SelectedAccounts
+	if accounts == nil {
+		for _, d := range post.Deliveries {
+			accounts = append(accounts, int(d.AccountID))
+		}
+	}
+	overrides := pu.Overrides
+	if overrides != nil {
+		if err = validateOverrides(overrides, accounts); err != nil {
+			slog.Info(err.Error())
+			return err
+		}
+	}
+
+	// Drafts are only checked once they are scheduled
+	if !isDraft {
+		deliveries, byPlatform := post.Deliveries, post.PlatformOverrides
+		if pu.SelectedAccounts != nil || overrides != nil {
+			newOverrides := overrides
+			if newOverrides == nil {
+				newOverrides = append(accountOverrides(post.Deliveries, pu.SelectedAccounts), platformOverrides(post.PlatformOverrides)...)
+			}
+			deliveries, byPlatform, err = s.newDeliveries(ctx, userID, accounts, newOverrides)
+			if err != nil {
+				return err
+			}
+		}
+		media, err := s.postMedia(ctx, postID)
+		if err != nil {
+			return err
+		}
+		if err := validatePost(post, deliveries, byPlatform, media); err != nil {
+			slog.Info(err.Error())
+			return err
+		}
+	}
+
+	var reordered []*models.PostMedia
+	if pu.MediaOrder != nil {
+		reordered, err = s.reorderMedia(ctx, postID, pu.MediaOrder)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Cancel the pending task first so the old time can never fire
+	if rescheduled {
+		if err = s.ps.Cancel(post.TaskID); err != nil {
+			return fmt.Errorf("error cancelling scheduled task: %w", err)
+		}
 	}
 
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+			if rescheduled {
+				// Put the original task back so the post still goes out
+				s.restoreSchedule(ctx, postID)
+			}
+		}
+	}()
+
+	if err = s.pr.Update(ctx, tx, post); err != nil {
+		return fmt.Errorf("error updating post: %w", err)
+	}
+
+	if overrides != nil {
+		if err = s.clearOverrides(ctx, tx, postID, post.Deliveries); err != nil {
+			return err
+		}
+	}
+
+	if pu.SelectedAccounts != nil {
+		if err = s.sa.RemoveByPostID(ctx, tx, postID); err != nil {
+			return fmt.Errorf("error removing selected accounts: %w", err)
+		}
+		if err = s.saveSelectedAccounts(ctx, tx, userID, postID, pu.SelectedAccounts); err != nil {
+			return fmt.Errorf("error processing selected accounts: %w", err)
+		}
+		if overrides == nil {
+			// Keep the overrides of the accounts that are still selected
+			if err = s.saveOverrides(ctx, tx, postID, accountOverrides(post.Deliveries, pu.SelectedAccounts)); err != nil {
+				return fmt.Errorf("error processing overrides: %w", err)
+			}
+		}
+	}
+
+	if overrides != nil {
+		if err = s.saveOverrides(ctx, tx, postID, overrides); err != nil {
+			return fmt.Errorf("error processing overrides: %w", err)
+		}
+	}
+
+	for _, pm := range reordered {
+		if err = s.pm.Update(ctx, tx, pm); err != nil {
+			return fmt.Errorf("error updating media order: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if rescheduled {
+		if err := s.schedule(ctx, postID, post.ScheduledTime); err != nil {
+			s.failUnscheduled(ctx, postID, err)
+			return fmt.Errorf("post updated but could not be scheduled: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// failUnscheduled marks a post whose task couldn't be queued as failed,
+// along with its deliveries, so that it shows and can be retried rather
+// than waiting for a task that never comes.
+func (s *postService) failUnscheduled(ctx context.Context, postID int64, cause error) {
+	deliveries, err := s.sa.ListByPostID(ctx, postID)
+	if err != nil {
+		slog.Error("unable to get deliveries of unscheduled post", "post_id", postID, "error", err)
+	}
+	for _, d := range deliveries {
+		d.Status = models.DeliveryStatusFailed
+		d.ErrorMessage = fmt.Sprintf("post could not be scheduled: %v", cause)
+		if err := s.sa.UpdateDelivery(ctx, d); err != nil {
+			slog.Error("unable to fail delivery of unscheduled post", "post_id", postID, "account_id", d.AccountID, "error", err)
+		}
+	}
+	if err := s.pr.UpdatePostStatus(ctx, models.PostStatusFailed, postID); err != nil {
+		slog.Error("unable to fail unscheduled post", "post_id", postID, "error", err)
+	}
+}
+
+// ScheduleDraft promotes a draft to a scheduled post. Files are appended to
+// the draft's media and scheduledTime, when given, replaces its time. The
+// draft must be complete before it is queued for publishing.
+func (s *postService) ScheduleDraft(ctx context.Context, userID, postID int64, scheduledTime, timezone string, files []*multipart.FileHeader) error {
+	post, err := s.PostInfo(ctx, postID, userID)
+	if err != nil {
+		return err
+	}
+
+	if post.Status != models.PostStatusDraft {
+		err = fmt.Errorf("only drafts can be scheduled, post is %s", post.Status)
+		slog.Info(err.Error())
+		return err
+	}
+
+	if timezone != "" {
+		if _, err = LoadZone(timezone); err != nil {
+			slog.Info(err.Error())
+			return err
+		}
+	}
+
+	zone := post.Timezone
+	if zone == "" {
+		zone = userZone(ctx, s.ur, userID)
+	}
+
+	if scheduledTime != "" {
+		post.ScheduledTime, post.Timezone, err = parseScheduledTime(scheduledTime, timezone, zone)
+		if err != nil {
+			return err
+		}
+	} else if timezone != "" && timezone != post.Timezone && !post.ScheduledTime.IsZero() {
+		// A new zone alone keeps the wall clock time of the draft
+		wall := post.ScheduledTime.In(locationOrUTC(zone)).Format("2006-01-02T15:04")
+		post.ScheduledTime, post.Timezone, err = parseScheduledTime(wall, timezone, zone)
+		if err != nil {
+			return err
+		}
+	}
+
+	if post.Caption == "" {
+		err = errors.New("caption cannot be empty")
+		slog.Info(err.Error())
+		return err
+	}
+
+	if post.ScheduledTime.IsZero() {
+		err = errors.New("scheduling time is required")
+		slog.Info(err.Error())
+		return err
+	}
+
+	if len(post.Deliveries) == 0 {
+		err = errors.New("no social accounts selected")
+		slog.Info(err.Error())
+		return err
+	}
+
+	postMedias, err := s.pm.ListByPostID(ctx, postID)
+	if err != nil {
+		return fmt.Errorf("error getting post media: %w", err)
+	}
+
+	mediaCount := len(postMedias) + len(files)
+	if mediaCount == 0 {
+		err = errors.New("no files provided for the post")
+		slog.Info(err.Error())
+		return err
+	}
+
+	media, err := s.postMedia(ctx, postID)
+	if err != nil {
+		return err
+	}
+	newMedia, err := fileMedia(files)
+	if err != nil {
+		return err
+	}
+	defer closeMedia(newMedia)
+	if err = validatePost(post, post.Deliveries, post.PlatformOverrides, append(media, mediaInfos(newMedia)...)); err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+
+	if err = dedupMedia(ctx, s.ma, userID, newMedia); err != nil {
+		return err
+	}
+	for _, pm := range postMedias {
+		for i, m := range newMedia {
+			if m.AssetID == pm.AssetID {
+				err = fmt.Errorf("file %d is already a media of the post", i+1)
+				slog.Info(err.Error())
+				return err
+			}
+		}
+	}
+
+	if err = storeFiles(ctx, s.store, newMedia); err != nil {
+		return fmt.Errorf("error uploading files: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		removeFiles(ctx, s.store, newMedia)
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			removeFiles(ctx, s.store, newMedia)
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+			removeFiles(ctx, s.store, newMedia)
+		}
+	}()
+
+	if err = s.saveMedia(ctx, tx, userID, postID, len(postMedias), newMedia); err != nil {
+		return fmt.Errorf("error processing files: %w", err)
+	}
+
+	post.PostType = postTypeFor(mediaCount)
+	post.Status = PostStatusScheduled
+	post.SeriesStart = post.ScheduledTime
+	if err = s.pr.Update(ctx, tx, post); err != nil {
+		return fmt.Errorf("error updating post: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	processAssets(s.mp, newAssetIDs(newMedia)...)
+
+	if err := s.schedule(ctx, postID, post.ScheduledTime); err != nil {
+		// Keep it as a draft so it can be scheduled again
+		if statusErr := s.pr.UpdatePostStatus(ctx, models.PostStatusDraft, postID); statusErr != nil {
+			slog.Error("unable to revert post to draft", "post_id", postID, "error", statusErr)
+		}
+		return fmt.Errorf("error scheduling post: %w", err)
+	}
+
+	return nil
+}
+
+// Skip drops the upcoming occurrence of a recurring post and schedules the
+// one after it. It returns the ID of the new occurrence, or 0 when the
+// series has ended.
+func (s *postService) Skip(ctx context.Context, userID, postID int64) (int64, error) {
+	post, err := s.PostInfo(ctx, postID, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if post.Recurrence == "" {
+		err = errors.New("only recurring posts can be skipped")
+		slog.Info(err.Error())
+		return 0, err
+	}
+
+	if post.Status != PostStatusScheduled {
+		err = fmt.Errorf("only scheduled posts can be skipped, post is %s", post.Status)
+		slog.Info(err.Error())
+		return 0, err
+	}
+
+	if err = s.ps.Cancel(post.TaskID); err != nil {
+		return 0, fmt.Errorf("error cancelling scheduled task: %w", err)
+	}
+
+	if err = s.pr.UpdatePostStatus(ctx, models.PostStatusSkipped, postID); err != nil {
+		s.restoreSchedule(ctx, postID)
+		return 0, fmt.Errorf("Error skipping post")
+	}
+
+	if err = s.pr.SetTaskID(ctx, postID, ""); err != nil {
+		slog.Error("unable to clear task of skipped post", "post_id", postID, "error", err)
+	}
+
+	nextID, err := s.ScheduleNextOccurrence(ctx, postID)
+	if err != nil {
+		return 0, fmt.Errorf("post skipped but the next occurrence could not be scheduled: %w", err)
+	}
+
+	return nextID, nil
+}
+
+// ScheduleNextOccurrence creates and queues the occurrence that follows a
+// post of a recurring series, reusing the content, accounts and media of
+// the first post of the series. Occurrences that are already in the past
+// are left out. It does nothing and returns 0 for posts without recurrence
+// or once the rule has ended, and returns the existing occurrence if it was
+// already created, so it is safe to call more than once for the same post.
+func (s *postService) ScheduleNextOccurrence(ctx context.Context, postID int64) (int64, error) {
+	post, err := s.pr.GetByID(ctx, postID)
+	if err != nil {
+		return 0, err
+	}
+	if post == nil || post.Recurrence == "" {
+		return 0, nil
+	}
+
+	rule, err := utils.ParseRRule(post.Recurrence)
+	if err != nil {
+		return 0, fmt.Errorf("invalid recurrence: %w", err)
+	}
+
+	seriesID := post.SeriesRootID()
+	next, err := s.pr.GetNextOccurrence(ctx, seriesID, post.Occurrence)
+	if err != nil {
+		return 0, err
+	}
+	if next != nil {
+		return next.ID, nil
+	}
+
+	seriesStart := post.SeriesStart
+	if seriesStart.IsZero() {
+		seriesStart = post.ScheduledTime
+	}
+	// Follow the wall clock of the post's zone across DST changes
+	seriesStart = seriesStart.In(locationOrUTC(post.Timezone))
+
+	// The next occurrence comes after this one, and occurrences that were
+	// missed are skipped
+	after := time.Now()
+	if current, ok := rule.Occurrence(seriesStart, post.Occurrence); ok && current.After(after) {
+		after = current
+	}
+	scheduledTime, occurrence, ok := rule.After(seriesStart, after)
+	if !ok {
+		return 0, nil
+	}
+
+	// Edits to a single occurrence don't carry over, the first post is the
+	// template unless it was removed
+	template := post
+	if seriesID != post.ID {
+		root, err := s.pr.GetByID(ctx, seriesID)
+		if err != nil {
+			return 0, err
+		}
+		if root != nil {
+			template = root
+		}
+	}
+
+	deliveries, err := s.sa.ListByPostID(ctx, template.ID)
+	if err != nil {
+		return 0, fmt.Errorf("error getting selected accounts: %w", err)
+	}
+	accounts := make([]int, 0, len(deliveries))
+	for _, d := range deliveries {
+		accounts = append(accounts, int(d.AccountID))
+	}
+
+	templateOverrides, err := s.po.ListByPostID(ctx, template.ID)
+	if err != nil {
+		return 0, fmt.Errorf("error getting post overrides: %w", err)
+	}
+	overrides := append(accountOverrides(deliveries, nil), platformOverrides(templateOverrides)...)
+
+	postMedias, err := s.pm.ListByPostID(ctx, template.ID)
+	if err != nil {
+		return 0, fmt.Errorf("error getting post media: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	nextPost := models.Post{
+		UserID:        template.UserID,
+		PostType:      template.PostType,
+		Caption:       template.Caption,
+		Title:         template.Title,
+		ScheduledTime: scheduledTime,
+		Status:        PostStatusScheduled,
+		Recurrence:    post.Recurrence,
+		SeriesID:      seriesID,
+		SeriesStart:   seriesStart,
+		Occurrence:    occurrence,
+		Timezone:      post.Timezone,
+	}
+
+	nextID, err := s.pr.Create(ctx, tx, &nextPost)
+	if err != nil {
+		return 0, fmt.Errorf("error creating post: %w", err)
+	}
+
+	if err = s.saveSelectedAccounts(ctx, tx, template.UserID, nextID, accounts); err != nil {
+		return 0, fmt.Errorf("error processing selected accounts: %w", err)
+	}
+
+	if err = s.saveOverrides(ctx, tx, nextID, overrides); err != nil {
+		return 0, fmt.Errorf("error processing overrides: %w", err)
+	}
+
+	for _, pm := range postMedias {
+		postMedia := models.PostMedia{
+			PostID:       nextID,
+			AssetID:      pm.AssetID,
+			DisplayOrder: pm.DisplayOrder,
+		}
+		if err = s.pm.Create(ctx, tx, &postMedia); err != nil {
+			return 0, fmt.Errorf("error saving media file: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if err := s.schedule(ctx, nextID, scheduledTime); err != nil {
+		if rmErr := s.pr.Remove(ctx, nextID); rmErr != nil {
+			slog.Error("unable to remove unscheduled post", "post_id", nextID, "error", rmErr)
+		}
+		return 0, fmt.Errorf("error scheduling post: %w", err)
+	}
+
+	return nextID, nil
+}
+
+// localize renders the times of posts in the zone of their user.
+func (s *postService) localize(ctx context.Context, userID int64, posts ...*models.Post) {
+	loc := locationOrUTC(userZone(ctx, s.ur, userID))
+	for _, post := range posts {
+		if !post.ScheduledTime.IsZero() {
+			post.ScheduledTime = post.ScheduledTime.In(loc)
+		}
+		if !post.SeriesStart.IsZero() {
+			post.SeriesStart = post.SeriesStart.In(loc)
+		}
+		for _, d := range post.Deliveries {
+			if d.PublishedAt != nil {
+				publishedAt := d.PublishedAt.In(loc)
+				d.PublishedAt = &publishedAt
+			}
+		}
+	}
+}
+
+// queueTime returns the next posting slot of the user that no other queued
+// post is using. Slots are read in the given zone.
+func (s *postService) queueTime(ctx context.Context, userID int64, accounts []int, category, zone string) (time.Time, error) {
+	slots, err := s.st.GetByUserID(ctx, userID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Error getting posting slots")
+	}
+
+	queuedPosts, err := s.pr.GetQueuedByUserID(ctx, userID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Error getting queued posts")
+	}
+
+	taken := make(map[takenSlot]bool)
+	for _, p := range queuedPosts {
+		postAccounts, err := s.postAccounts(ctx, p.ID)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("Error getting queued posts")
+		}
+		takeSlot(taken, p.ScheduledTime, postAccounts)
+	}
+
+	slot, ok := nextSlot(slots, toIDs(accounts), category, time.Now().In(locationOrUTC(zone)), taken)
+	if !ok {
+		err = errors.New("no free posting slot available, add posting slots or pick a scheduling time")
+		slog.Info(err.Error())
+		return time.Time{}, err
+	}
+	return slot.UTC(), nil
+}
+
+// reshuffleQueue moves the queued posts that come after a freed slot up to
+// the earliest free slot they can use, keeping their order. Errors are only
+// logged, a post that can't be moved keeps its time.
+func (s *postService) reshuffleQueue(ctx context.Context, userID int64, freed time.Time) {
+	slots, err := s.st.GetByUserID(ctx, userID)
+	if err != nil {
+		return
+	}
+
+	queuedPosts, err := s.pr.GetQueuedByUserID(ctx, userID)
+	if err != nil {
+		return
+	}
+
+	taken := make(map[takenSlot]bool)
+	var later []*models.Post
+	for _, p := range queuedPosts {
+		if p.ScheduledTime.After(freed) {
+			later = append(later, p)
+			continue
+		}
+		accounts, err := s.postAccounts(ctx, p.ID)
+		if err != nil {
+			return
+		}
+		takeSlot(taken, p.ScheduledTime, accounts)
+	}
+
+	loc := locationOrUTC(userZone(ctx, s.ur, userID))
+	from := time.Now().In(loc)
+	for _, p := range later {
+		accounts, err := s.postAccounts(ctx, p.ID)
+		if err != nil {
+			slog.Error("unable to get accounts of queued post", "post_id", p.ID, "error", err)
+			return
+		}
+
+		slot, ok := nextSlot(slots, accounts, p.Category, from, taken)
+		if ok && slot.Before(p.ScheduledTime) {
+			if err := s.moveQueuedPost(ctx, p, slot); err != nil {
+				slog.Error("unable to move queued post", "post_id", p.ID, "error", err)
+			}
+		}
+		// Later posts never move ahead of this one
+		takeSlot(taken, p.ScheduledTime, accounts)
+		from = p.ScheduledTime.In(loc)
+	}
+}
+
+// postAccounts returns the IDs of the accounts a post is published to.
+func (s *postService) postAccounts(ctx context.Context, postID int64) ([]int64, error) {
+	deliveries, err := s.sa.ListByPostID(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]int64, 0, len(deliveries))
+	for _, d := range deliveries {
+		accounts = append(accounts, d.AccountID)
+	}
+	return accounts, nil
+}
+
+// moveQueuedPost reschedules a queued post to the given slot.
+func (s *postService) moveQueuedPost(ctx context.Context, post *models.Post, slot time.Time) error {
+	if err := s.ps.Cancel(post.TaskID); err != nil {
+		return fmt.Errorf("error cancelling scheduled task: %w", err)
+	}
+
+	previous := post.ScheduledTime
+	post.ScheduledTime = slot
+	if err := s.pr.Update(ctx, nil, post); err != nil {
+		post.ScheduledTime = previous
+		s.restoreSchedule(ctx, post.ID)
+		return fmt.Errorf("error updating post: %w", err)
+	}
+
+	return s.schedule(ctx, post.ID, slot)
+}
+
+// reorderMedia checks that order lists exactly the assets attached to the
+// post and returns them with their new display order.
+func (s *postService) reorderMedia(ctx context.Context, postID int64, order []int64) ([]*models.PostMedia, error) {
+	postMedias, err := s.pm.ListByPostID(ctx, postID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting post media: %w", err)
+	}
+
+	if len(order) != len(postMedias) {
+		err = fmt.Errorf("media order must list all %d media of the post", len(postMedias))
+		slog.Info(err.Error())
+		return nil, err
+	}
+
+	byAsset := make(map[int64]*models.PostMedia, len(postMedias))
+	for _, pm := range postMedias {
+		byAsset[pm.AssetID] = pm
+	}
+
+	for i, assetID := range order {
+		pm, ok := byAsset[assetID]
+		if !ok {
+			err = fmt.Errorf("media %d is not part of the post or is listed twice", assetID)
+			slog.Info(err.Error())
+			return nil, err
+		}
+		delete(byAsset, assetID)
+		pm.DisplayOrder = i
+	}
+
+	return postMedias, nil
+}
+
+// schedule queues the publishing task for a post under a new task ID,
+// stored on the post first. A post never ends up with a task it doesn't know
+// about, and the ID is cleared again if the task cannot be queued.
+func (s *postService) schedule(ctx context.Context, postID int64, scheduledTime time.Time) error {
+	taskID, err := gonanoid.New()
+	if err != nil {
+		return err
+	}
+
+	// The task can run right away, its ID must be on the post by then
+	if err := s.pr.SetTaskID(ctx, postID, taskID); err != nil {
+		return err
+	}
+
+	if err := s.ps.Schedule(postID, taskID, delayUntil(scheduledTime)); err != nil {
+		if clearErr := s.pr.SetTaskID(ctx, postID, ""); clearErr != nil {
+			slog.Error("unable to clear task of unscheduled post", "post_id", postID, "task_id", taskID, "error", clearErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *postService) restoreSchedule(ctx context.Context, postID int64) {
+	post, err := s.pr.GetByID(ctx, postID)
+	if err != nil || post == nil {
+		slog.Error("unable to load post to restore its schedule", "post_id", postID)
+		return
+	}
+	if err := s.schedule(ctx, postID, post.ScheduledTime); err != nil {
+		slog.Error("unable to restore scheduled task", "post_id", postID, "error", err)
+	}
+}
+
+func delayUntil(t time.Time) time.Duration {
+	delay := time.Until(t)
+	if delay < 0 {
+		delay = 0
+	}
+	return delay
+}
+
+// Retry re-enqueues publishing for the failed deliveries of a post. When
+// accountIDs is empty every failed delivery is retried.
+func (s *postService) Retry(ctx context.Context, userID, postID int64, accountIDs []int64) ([]int64, error) {
+	post, err := s.PostInfo(ctx, postID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	failed := make(map[int64]*models.SelectedAccount)
+	for _, d := range post.Deliveries {
+		if d.Status == models.DeliveryStatusFailed {
+			failed[d.AccountID] = d
+		}
+	}
+
+	if len(accountIDs) == 0 {
+		for accountID := range failed {
+			accountIDs = append(accountIDs, accountID)
+		}
+	}
+
+	if len(accountIDs) == 0 {
+		err = errors.New("post has no failed deliveries to retry")
+		slog.Info(err.Error())
+		return nil, err
+	}
+
+	for _, accountID := range accountIDs {
+		if _, ok := failed[accountID]; !ok {
+			err = fmt.Errorf("delivery to account %d has not failed", accountID)
+			slog.Info(err.Error())
+			return nil, err
+		}
+
+		account, err := s.ac.GetByID(ctx, accountID)
+		if err != nil {
+			return nil, fmt.Errorf("error checking social account %d: %w", accountID, err)
+		}
+		if account == nil || account.AccountStatus != models.AccountStatusActive {
+			err = fmt.Errorf("social account %d is not active", accountID)
+			slog.Info(err.Error())
+			return nil, err
+		}
+		if account.TokenExpiresAt.Before(time.Now()) {
+			err = fmt.Errorf("social account %d needs to be reconnected", accountID)
+			slog.Info(err.Error())
+			return nil, err
+		}
+	}
+
+	// Replace any task left over from earlier runs before resetting
+	if err = s.ps.Cancel(post.TaskID); err != nil {
+		return nil, fmt.Errorf("error cancelling queued task: %w", err)
+	}
+
+	for _, accountID := range accountIDs {
+		if err = s.sa.ResetDelivery(ctx, postID, accountID); err != nil {
+			return nil, fmt.Errorf("error resetting delivery to account %d: %w", accountID, err)
+		}
+	}
+
+	if err = s.pr.UpdatePostStatus(ctx, models.PostStatusPublishing, postID); err != nil {
+		return nil, fmt.Errorf("error updating post status: %w", err)
+	}
+
+	if err = s.schedule(ctx, postID, time.Now()); err != nil {
+		return nil, fmt.Errorf("error scheduling retry: %w", err)
+	}
+
+	return accountIDs, nil
+}
+
+// Defer queues a new run of the publishing of a post after a delay, for
+// deliveries that wait on their media. It doesn't count as an attempt.
+func (s *postService) Defer(ctx context.Context, postID int64, delay time.Duration) error {
+	if err := s.schedule(ctx, postID, time.Now().Add(delay)); err != nil {
+		return fmt.Errorf("error scheduling post: %w", err)
+	}
 	return nil
 }
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime/multipart"
+	"time"
+
+	"github.com/maheshrc27/scheduling-api/internal/models"
+	"github.com/maheshrc27/scheduling-api/internal/transfer"
+)
+
+// PostPreview is what publishing a post would do, worked out without saving
+// or uploading anything.
+type PostPreview struct {
+	ScheduledTime time.Time        `json:"scheduled_time"`
+	Timezone      string           `json:"timezone"`
+	PostType      string           `json:"post_type"`
+	Accounts      []AccountPreview `json:"accounts"`
+}
+
+// AccountPreview is the content published to one of the selected accounts
+// and the requests sent to its platform, in order. Access tokens are left
+// out, and files that aren't uploaded yet are referenced as upload://name.
+type AccountPreview struct {
+	AccountID   int64             `json:"account_id"`
+	Platform    string            `json:"platform"`
+	AccountName string            `json:"account_name"`
+	PostType    string            `json:"post_type"`
+	Caption     string            `json:"caption"`
+	Title       string            `json:"title,omitempty"`
+	Requests    []PreviewRequest  `json:"requests"`
+	Warnings    []ValidationIssue `json:"warnings"`
+}
+
+type PreviewRequest struct {
+	URL   string `json:"url"`
+	Body  any    `json:"body"`
+	Media string `json:"media,omitempty"` // file sent along with the body
+}
+
+// Preview runs the checks of CreatePost and returns what would be published
+// to each selected account, with validation issues as warnings.
+func (s *postService) Preview(ctx context.Context, userID int64, pc *transfer.PostCreation, files []*multipart.FileHeader) (*PostPreview, error) {
+	selectedAccounts, overrides, err := parsePostCreation(pc)
+	if err != nil {
+		return nil, err
+	}
+
+	media, err := fileMedia(files)
+	if err != nil {
+		return nil, err
+	}
+	defer closeMedia(media)
+
+	return s.preview(ctx, userID, pc, selectedAccounts, overrides, media)
+}
+
+// PreviewJSON is Preview for the body of CreatePostJSON. Remote files are
+// fetched to be checked, but they aren't stored.
+func (s *postService) PreviewJSON(ctx context.Context, userID int64, pj *transfer.PostCreationJSON) (*PostPreview, error) {
+	if pj == nil {
+		err := errors.New("post creation data is nil")
+		slog.Error(err.Error())
+		return nil, err
+	}
+
+	media, err := s.resolveMedia(ctx, userID, pj.Media)
+	if err != nil {
+		return nil, err
+	}
+	defer closeMedia(media)
+
+	return s.preview(ctx, userID, postCreationJSON(pj), pj.SelectedAccounts, pj.Overrides, media)
+}
+
+func (s *postService) preview(ctx context.Context, userID int64, pc *transfer.PostCreation, selectedAccounts []int, overrides []transfer.PostOverride, media []*mediaItem) (*PostPreview, error) {
+	post, err := s.newPost(ctx, userID, pc, selectedAccounts, overrides, len(media))
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, platformOverrides, err := s.newDeliveries(ctx, userID, selectedAccounts, overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &PostPreview{
+		ScheduledTime: post.ScheduledTime,
+		Timezone:      post.Timezone,
+		PostType:      post.PostType,
+		Accounts:      make([]AccountPreview, 0, len(deliveries)),
+	}
+	if !post.ScheduledTime.IsZero() {
+		preview.ScheduledTime = post.ScheduledTime.In(locationOrUTC(post.Timezone))
+	}
+
+	for _, d := range deliveries {
+		account, err := s.ac.GetByID(ctx, d.AccountID)
+		if err != nil || account == nil {
+			return nil, fmt.Errorf("Error getting social account")
+		}
+
+		p := PostForDelivery(post, d, platformOverrides)
+		ap := AccountPreview{
+			AccountID:   d.AccountID,
+			Platform:    d.Platform,
+			AccountName: account.AccountName,
+			PostType:    p.PostType,
+			Caption:     p.Caption,
+			Title:       p.Title,
+			Requests:    previewRequests(p, account, media),
+			Warnings:    []ValidationIssue{},
+		}
+		if rules, ok := platformRules[d.Platform]; ok {
+			for _, issue := range checkRules(rules, p, mediaInfos(media)) {
+				issue.AccountID = d.AccountID
+				issue.Platform = d.Platform
+				ap.Warnings = append(ap.Warnings, issue)
+			}
+		}
+		preview.Accounts = append(preview.Accounts, ap)
+	}
+
+	return preview, nil
+}
+
+// previewRequests builds the requests the platform services send to publish
+// the post, with the same payloads they use.
+func previewRequests(post *models.Post, account *models.SocialAccount, media []*mediaItem) []PreviewRequest {
+	if len(media) == 0 {
+		return []PreviewRequest{}
+	}
+
+	switch account.Platform {
+	case "instagram":
+		url := instagramMediaURL(account.AccountID)
+		if post.PostType != PostTypeMultiple {
+			return []PreviewRequest{{
+				URL:  url,
+				Body: instagramMediaPayload(media[0].URL, media[0].Info.MIME, post.Caption, false),
+			}}
+		}
+
+		requests := make([]PreviewRequest, 0, len(media)+1)
+		children := make([]string, 0, len(media))
+		for i, m := range media {
+			requests = append(requests, PreviewRequest{
+				URL:  url,
+				Body: instagramMediaPayload(m.URL, m.Info.MIME, "", true),
+			})
+			// Container IDs are only known once Instagram creates them
+			children = append(children, fmt.Sprintf("<container %d>", i+1))
+		}
+		return append(requests, PreviewRequest{
+			URL:  url,
+			Body: instagramCarouselPayload(post.Caption, children),
+		})
+
+	case "tiktok":
+		if post.PostType != PostTypeMultiple {
+			return []PreviewRequest{{
+				URL:  tiktokVideoInitURL,
+				Body: tiktokVideoRequest(post, media[0].URL, media[0].coverTime()),
+			}}
+		}
+
+		photos := make([]string, 0, len(media))
+		for _, m := range media {
+			photos = append(photos, m.URL)
+		}
+		return []PreviewRequest{{
+			URL:  tiktokContentInitURL,
+			Body: tiktokPhotoRequest(post, photos),
+		}}
+
+	case "youtube":
+		return []PreviewRequest{{
+			URL:   youtubeUploadURL,
+			Body:  youtubeVideo(post.Caption, post.Title, post.Options),
+			Media: media[0].URL,
+		}}
+	}
+
+	return []PreviewRequest{}
+}
@@ -3,26 +3,43 @@ package service
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"log/slog"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	cfg "github.com/maheshrc27/scheduling-api/configs"
 )
 
+// R2Service stores files in Cloudflare R2, or any other S3-compatible
+// storage set in R2.Endpoint.
 type R2Service struct {
 	config cfg.Config
+	client *s3.Client
 }
 
 func NewR2Service(cfg cfg.Config) *R2Service {
-	return &R2Service{config: cfg}
+	r := &R2Service{config: cfg}
+	r.client = r.newClient()
+	return r
 }
 
+// R2Client returns the client shared by all requests
 func (r *R2Service) R2Client() *s3.Client {
+	return r.client
+}
+
+func (r *R2Service) newClient() *s3.Client {
 	cfg, err := config.LoadDefaultConfig(context.TODO(),
 		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(r.config.R2.AccessKey, r.config.R2.SecretKey, "")),
 		config.WithRegion("auto"),
@@ -33,24 +50,51 @@ func (r *R2Service) R2Client() *s3.Client {
 	}
 
 	return s3.NewFromConfig(cfg, func(o *s3.Options) {
-		o.BaseEndpoint = aws.String(fmt.Sprintf("https://%s.r2.cloudflarestorage.com", r.config.R2.AccountID))
+		if r.config.R2.Endpoint != "" {
+			o.BaseEndpoint = aws.String(r.config.R2.Endpoint)
+			o.UsePathStyle = true
+		} else {
+			o.BaseEndpoint = aws.String(fmt.Sprintf("https://%s.r2.cloudflarestorage.com", r.config.R2.AccountID))
+		}
 	})
 }
 
-// Function to upload file to Cloudflare R2 Storage
-func (r *R2Service) UploadToR2(ctx context.Context, key string, file []byte, filetype string) error {
+// Put uploads a file to Cloudflare R2 Storage. The file is streamed, its
+// size must be known up front.
+func (r *R2Service) Put(ctx context.Context, key string, file io.Reader, size int64, filetype string) error {
 	// Create a PutObjectInput with the specified bucket, key, file content, and content type
 	input := &s3.PutObjectInput{
-		Bucket:      aws.String(r.config.R2.BucketName),
-		Key:         aws.String(key),
-		Body:        bytes.NewReader(file),
-		ContentType: aws.String(filetype), // Set the content type to image/jpeg (change as needed)
+		Bucket:        aws.String(r.config.R2.BucketName),
+		Key:           aws.String(key),
+		Body:          file,
+		ContentLength: aws.Int64(size),
+		ContentType:   aws.String(filetype),
 	}
 
-	r2Client := r.R2Client()
+	// Upload the file to Cloudflare R2 Storage. The payload isn't signed,
+	// signing would read the whole file before sending it.
+	_, err := r.R2Client().PutObject(ctx, input, s3.WithAPIOptions(v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware))
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// URL is where a file in the bucket is publicly served from
+func (r *R2Service) URL(key string) string {
+	return strings.TrimSuffix(r.config.Storage.PublicURL, "/") + "/" + key
+}
+
+// Delete removes a file from Cloudflare R2 Storage
+func (r *R2Service) Delete(ctx context.Context, key string) error {
+	input := &s3.DeleteObjectInput{
+		Bucket: aws.String(r.config.R2.BucketName),
+		Key:    aws.String(key),
+	}
 
-	// Upload the file to Cloudflare R2 Storage
-	_, err := r2Client.PutObject(ctx, input)
+	_, err := r.R2Client().DeleteObject(ctx, input)
 	if err != nil {
 		slog.Info(err.Error())
 		return err
@@ -58,3 +102,171 @@ func (r *R2Service) UploadToR2(ctx context.Context, key string, file []byte, fil
 
 	return nil
 }
+
+// PresignPut returns a URL the client can PUT a file of the given type and
+// size to, along with the headers the request must be sent with.
+func (r *R2Service) PresignPut(ctx context.Context, key, filetype string, size int64, expires time.Duration) (string, http.Header, error) {
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(r.config.R2.BucketName),
+		Key:           aws.String(key),
+		ContentType:   aws.String(filetype),
+		ContentLength: aws.Int64(size),
+	}
+
+	presigned, err := s3.NewPresignClient(r.R2Client()).PresignPutObject(ctx, input, s3.WithPresignExpires(expires))
+	if err != nil {
+		slog.Info(err.Error())
+		return "", nil, err
+	}
+
+	return presigned.URL, presigned.SignedHeader, nil
+}
+
+// Size returns the size of a file in Cloudflare R2 Storage
+func (r *R2Service) Size(ctx context.Context, key string) (int64, error) {
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(r.config.R2.BucketName),
+		Key:    aws.String(key),
+	}
+
+	output, err := r.R2Client().HeadObject(ctx, input)
+	if err != nil {
+		slog.Info(err.Error())
+		return 0, err
+	}
+
+	return aws.ToInt64(output.ContentLength), nil
+}
+
+// ReadRange reads length bytes of a file in Cloudflare R2 Storage from offset
+func (r *R2Service) ReadRange(ctx context.Context, key string, offset, length int64) ([]byte, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(r.config.R2.BucketName),
+		Key:    aws.String(key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+	}
+
+	output, err := r.R2Client().GetObject(ctx, input)
+	if err != nil {
+		slog.Info(err.Error())
+		return nil, err
+	}
+	defer output.Body.Close()
+
+	return io.ReadAll(output.Body)
+}
+
+// CreateMultipartUpload starts an upload of a file in parts and returns its
+// upload ID
+func (r *R2Service) CreateMultipartUpload(ctx context.Context, key, filetype string) (string, error) {
+	input := &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(r.config.R2.BucketName),
+		Key:    aws.String(key),
+	}
+	if filetype != "" {
+		input.ContentType = aws.String(filetype)
+	}
+
+	output, err := r.R2Client().CreateMultipartUpload(ctx, input)
+	if err != nil {
+		slog.Info(err.Error())
+		return "", err
+	}
+
+	return aws.ToString(output.UploadId), nil
+}
+
+// UploadPart uploads a part of a multipart upload and returns its ETag.
+// Parts are numbered from 1, every part but the last must be at least 5 MB.
+func (r *R2Service) UploadPart(ctx context.Context, key, uploadID string, partNumber int32, data []byte) (string, error) {
+	input := &s3.UploadPartInput{
+		Bucket:     aws.String(r.config.R2.BucketName),
+		Key:        aws.String(key),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int32(partNumber),
+		Body:       bytes.NewReader(data),
+	}
+
+	output, err := r.R2Client().UploadPart(ctx, input)
+	if err != nil {
+		slog.Info(err.Error())
+		return "", err
+	}
+
+	return aws.ToString(output.ETag), nil
+}
+
+// CompleteMultipartUpload assembles the uploaded parts into the file
+func (r *R2Service) CompleteMultipartUpload(ctx context.Context, key, uploadID string, etags []string) error {
+	parts := make([]types.CompletedPart, 0, len(etags))
+	for i, etag := range etags {
+		parts = append(parts, types.CompletedPart{
+			ETag:       aws.String(etag),
+			PartNumber: aws.Int32(int32(i + 1)),
+		})
+	}
+
+	input := &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(r.config.R2.BucketName),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	}
+
+	_, err := r.R2Client().CompleteMultipartUpload(ctx, input)
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// AbortMultipartUpload drops a multipart upload along with its parts, an
+// upload that is already gone is left alone
+func (r *R2Service) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	input := &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(r.config.R2.BucketName),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	}
+
+	_, err := r.R2Client().AbortMultipartUpload(ctx, input)
+	var noSuchUpload *types.NoSuchUpload
+	if errors.As(err, &noSuchUpload) {
+		return nil
+	}
+	if err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// Walk lists the files of the bucket, a page of up to a thousand at a time
+func (r *R2Service) Walk(ctx context.Context, fn func(file StoredFile) error) error {
+	paginator := s3.NewListObjectsV2Paginator(r.R2Client(), &s3.ListObjectsV2Input{
+		Bucket: aws.String(r.config.R2.BucketName),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			slog.Info(err.Error())
+			return err
+		}
+
+		for _, object := range page.Contents {
+			file := StoredFile{
+				Key:        aws.ToString(object.Key),
+				Size:       aws.ToInt64(object.Size),
+				ModifiedAt: aws.ToTime(object.LastModified),
+			}
+			if err := fn(file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,379 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/maheshrc27/scheduling-api/internal/models"
+	"github.com/maheshrc27/scheduling-api/internal/repository"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+const (
+	// MaxResumableUploadSize is the largest file that can be uploaded in chunks
+	MaxResumableUploadSize = 10 * gb
+	// minPartSize is the smallest part of a multipart upload, but for the
+	// last. Every chunk is uploaded as a part, so it is also the smallest chunk
+	minPartSize = 5 * mb
+	// resumableUploadExpiry is how long an upload can be resumed for
+	resumableUploadExpiry = 24 * time.Hour
+)
+
+// Errors of resumable uploads the tus protocol answers with its own status.
+var (
+	ErrUploadNotFound = errors.New("upload doesn't exist")
+	ErrUploadExpired  = errors.New("upload has expired")
+	ErrUploadOffset   = errors.New("upload offset doesn't match")
+	ErrUploadTooLarge = errors.New("upload is too large")
+	ErrUploadType     = errors.New("unsupported media type")
+	ErrUploadChunk    = errors.New("chunk is too small")
+	ErrUploadLength   = errors.New("upload length must be greater than 0")
+)
+
+type ResumableUploadService interface {
+	Create(ctx context.Context, userID int64, fileName, fileType string, size int64) (*models.ResumableUpload, error)
+	Get(ctx context.Context, userID, uploadID int64) (*models.ResumableUpload, error)
+	Append(ctx context.Context, userID, uploadID, offset int64, chunk []byte) (*models.ResumableUpload, error)
+	Terminate(ctx context.Context, userID, uploadID int64) error
+}
+
+type resumableUploadService struct {
+	db    *sql.DB
+	ru    repository.ResumableUploadRepository
+	ma    repository.MediaAssetRepository
+	store Storage
+	mp    MediaProcessor
+}
+
+func NewResumableUploadService(db *sql.DB, ru repository.ResumableUploadRepository, ma repository.MediaAssetRepository, store Storage, mp MediaProcessor) ResumableUploadService {
+	return &resumableUploadService{
+		db:    db,
+		ru:    ru,
+		ma:    ma,
+		store: store,
+		mp:    mp,
+	}
+}
+
+// Create starts a resumable upload of size bytes. The file type is optional,
+// when given the file has to be of that type.
+func (s *resumableUploadService) Create(ctx context.Context, userID int64, fileName, fileType string, size int64) (*models.ResumableUpload, error) {
+	var err error
+
+	if size <= 0 {
+		slog.Info(ErrUploadLength.Error())
+		return nil, ErrUploadLength
+	}
+	if size > MaxResumableUploadSize {
+		err = fmt.Errorf("%w, files can be up to %s", ErrUploadTooLarge, formatSize(MaxResumableUploadSize))
+		slog.Info(err.Error())
+		return nil, err
+	}
+
+	if fileType != "" {
+		if _, ok := uploadTypes[fileType]; !ok {
+			err = fmt.Errorf("%w: %s", ErrUploadType, fileType)
+			slog.Info(err.Error())
+			return nil, err
+		}
+	}
+
+	key, err := gonanoid.New()
+	if err != nil {
+		return nil, fmt.Errorf("Error creating file name")
+	}
+
+	multipartID, err := s.store.CreateMultipartUpload(ctx, key, fileType)
+	if err != nil {
+		return nil, fmt.Errorf("Error starting upload")
+	}
+
+	upload := &models.ResumableUpload{
+		UserID:      userID,
+		FileKey:     key,
+		FileName:    fileName,
+		FileType:    fileType,
+		FileSize:    size,
+		MultipartID: multipartID,
+		ExpiresAt:   time.Now().Add(resumableUploadExpiry).UTC(),
+	}
+	upload.ID, err = s.ru.Create(ctx, upload)
+	if err != nil {
+		s.abort(ctx, upload)
+		return nil, fmt.Errorf("Error saving upload")
+	}
+
+	return upload, nil
+}
+
+// Get returns an upload. An upload that was fully received but couldn't be
+// turned into a media asset is completed again.
+func (s *resumableUploadService) Get(ctx context.Context, userID, uploadID int64) (*models.ResumableUpload, error) {
+	upload, err := s.get(ctx, userID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if upload.Offset == upload.FileSize && upload.AssetID == 0 {
+		return s.complete(ctx, upload.ID)
+	}
+	return upload, nil
+}
+
+func (s *resumableUploadService) get(ctx context.Context, userID, uploadID int64) (*models.ResumableUpload, error) {
+	upload, err := s.ru.GetByID(ctx, uploadID)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting upload")
+	}
+	if upload == nil || upload.UserID != userID {
+		slog.Info(ErrUploadNotFound.Error())
+		return nil, ErrUploadNotFound
+	}
+	return upload, nil
+}
+
+// Append adds a chunk at offset, which must be where the upload is at. Each
+// chunk is sent to storage as a part, so all but the last must be at least
+// minPartSize, and the last one turns the upload into a media asset.
+func (s *resumableUploadService) Append(ctx context.Context, userID, uploadID, offset int64, chunk []byte) (*models.ResumableUpload, error) {
+	upload, err := s.Get(ctx, userID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset != upload.Offset {
+		err = fmt.Errorf("%w, upload is at %d", ErrUploadOffset, upload.Offset)
+		slog.Info(err.Error())
+		return nil, err
+	}
+	if upload.AssetID != 0 || len(chunk) == 0 {
+		// Nothing is left to upload, a retried last chunk is empty
+		return upload, nil
+	}
+
+	if time.Now().After(upload.ExpiresAt) {
+		s.abort(ctx, upload)
+		slog.Info(ErrUploadExpired.Error())
+		return nil, ErrUploadExpired
+	}
+
+	end := offset + int64(len(chunk))
+	if end > upload.FileSize {
+		err = fmt.Errorf("%w, the chunk goes past the upload length", ErrUploadTooLarge)
+		slog.Info(err.Error())
+		return nil, err
+	}
+	if len(chunk) < minPartSize && end < upload.FileSize {
+		err = fmt.Errorf("%w, chunks but the last must be at least %s", ErrUploadChunk, formatSize(minPartSize))
+		slog.Info(err.Error())
+		return nil, err
+	}
+
+	// The type is checked on the first chunk, before more is uploaded
+	fileType := upload.FileType
+	if offset == 0 {
+		mime, err := detectType(chunk)
+		switch {
+		case err != nil:
+			err = fmt.Errorf("%w: %v", ErrUploadType, err)
+		case upload.FileType != "" && mime != upload.FileType:
+			err = fmt.Errorf("%w: uploaded file is %s, expected %s", ErrUploadType, mime, upload.FileType)
+		}
+		if err != nil {
+			s.abort(ctx, upload)
+			slog.Info(err.Error())
+			return nil, err
+		}
+		fileType = mime
+	}
+
+	// The upload is locked while the chunk is stored, a request sending the
+	// same chunk waits and then finds the offset moved on
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("Error saving upload")
+	}
+	defer tx.Rollback()
+
+	upload, err = s.ru.Lock(ctx, tx, uploadID)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting upload")
+	}
+	if upload == nil {
+		slog.Info(ErrUploadNotFound.Error())
+		return nil, ErrUploadNotFound
+	}
+	if offset != upload.Offset || upload.AssetID != 0 {
+		err = fmt.Errorf("%w, upload is at %d", ErrUploadOffset, upload.Offset)
+		slog.Info(err.Error())
+		return nil, err
+	}
+
+	// The file is hashed as it comes, so it isn't read again once complete
+	hashState, err := hashChunk(upload.HashState, chunk)
+	if err != nil {
+		return nil, fmt.Errorf("Error hashing upload")
+	}
+
+	etag, err := s.store.UploadPart(ctx, upload.FileKey, upload.MultipartID, int32(len(upload.Parts)+1), chunk)
+	if err != nil {
+		return nil, fmt.Errorf("Error uploading file")
+	}
+	upload.FileType = fileType
+	upload.Parts = append(upload.Parts, etag)
+	upload.Offset = end
+	upload.HashState = hashState
+
+	if err := s.ru.Update(ctx, tx, upload); err != nil {
+		return nil, fmt.Errorf("Error saving upload")
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("Error saving upload")
+	}
+
+	if upload.Offset == upload.FileSize {
+		return s.complete(ctx, upload.ID)
+	}
+	return upload, nil
+}
+
+// complete assembles the parts of a fully received upload and saves the
+// file as a media asset. When the user already has the file its asset is
+// used instead and the uploaded copy is deleted. An upload whose asset
+// couldn't be saved is completed again, its parts are then already
+// assembled.
+func (s *resumableUploadService) complete(ctx context.Context, uploadID int64) (*models.ResumableUpload, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("Error saving upload")
+	}
+	defer tx.Rollback()
+
+	upload, err := s.ru.Lock(ctx, tx, uploadID)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting upload")
+	}
+	if upload == nil {
+		slog.Info(ErrUploadNotFound.Error())
+		return nil, ErrUploadNotFound
+	}
+	if upload.AssetID != 0 {
+		// Another request completed it
+		return upload, nil
+	}
+
+	if err := s.store.CompleteMultipartUpload(ctx, upload.FileKey, upload.MultipartID, upload.Parts); err != nil {
+		if size, sizeErr := s.store.Size(ctx, upload.FileKey); sizeErr != nil || size != upload.FileSize {
+			return nil, fmt.Errorf("Error completing upload")
+		}
+	}
+
+	hash, err := hashSum(upload.HashState)
+	if err != nil {
+		return nil, fmt.Errorf("Error hashing upload")
+	}
+
+	found, err := s.ma.GetByHash(ctx, upload.UserID, hash)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting media asset")
+	}
+	existing := found != nil
+	if existing {
+		if !found.Library {
+			if err := s.ma.AddToLibrary(ctx, found.ID); err != nil {
+				return nil, fmt.Errorf("Error adding media asset to library")
+			}
+		}
+		upload.AssetID = found.ID
+	} else {
+		file := &storageFile{ctx: ctx, store: s.store, key: upload.FileKey}
+		info := probeFile(file, upload.FileSize, upload.FileType)
+		asset := newAsset(s.store, upload.UserID, upload.FileKey, hash, info)
+		asset.Library = true
+		var created bool
+		upload.AssetID, created, err = s.ma.Create(ctx, tx, &asset)
+		if err != nil {
+			return nil, fmt.Errorf("Error saving media asset")
+		}
+		// The same file was saved meanwhile by another upload
+		existing = !created
+	}
+
+	if err := s.ru.Update(ctx, tx, upload); err != nil {
+		return nil, fmt.Errorf("Error saving upload")
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("Error saving upload")
+	}
+
+	if existing {
+		// The user already has this file, the upload is only a copy of it
+		if err := s.store.Delete(ctx, upload.FileKey); err != nil {
+			slog.Error("unable to delete uploaded copy", "upload_id", upload.ID, "file", upload.FileKey, "error", err)
+		}
+	} else {
+		processAssets(s.mp, upload.AssetID)
+	}
+	return upload, nil
+}
+
+// hashChunk adds a chunk to the SHA-256 state of what was uploaded before.
+func hashChunk(state, chunk []byte) ([]byte, error) {
+	h := sha256.New()
+	if len(state) > 0 {
+		if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+			slog.Info(err.Error())
+			return nil, err
+		}
+	}
+	h.Write(chunk)
+	return h.(encoding.BinaryMarshaler).MarshalBinary()
+}
+
+// hashSum returns the hash of an upload from its SHA-256 state, as hashFile
+// does for a whole file.
+func hashSum(state []byte) (string, error) {
+	h := sha256.New()
+	if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+		slog.Info(err.Error())
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Terminate cancels an upload. The asset of a completed upload is kept.
+func (s *resumableUploadService) Terminate(ctx context.Context, userID, uploadID int64) error {
+	upload, err := s.get(ctx, userID, uploadID)
+	if err != nil {
+		return err
+	}
+
+	if upload.AssetID == 0 {
+		s.abort(ctx, upload)
+		return nil
+	}
+
+	if err := s.ru.Remove(ctx, upload.ID); err != nil {
+		return fmt.Errorf("Error removing upload")
+	}
+	return nil
+}
+
+// abort drops an unfinished upload along with its uploaded parts.
+func (s *resumableUploadService) abort(ctx context.Context, upload *models.ResumableUpload) {
+	if err := s.store.AbortMultipartUpload(ctx, upload.FileKey, upload.MultipartID); err != nil {
+		slog.Error("unable to abort multipart upload", "upload_id", upload.ID, "file", upload.FileKey, "error", err)
+	}
+	if upload.ID == 0 {
+		return
+	}
+	if err := s.ru.Remove(ctx, upload.ID); err != nil {
+		slog.Error("unable to remove upload", "upload_id", upload.ID, "error", err)
+	}
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"log/slog"
+	"time"
+)
+
+// PostScheduler enqueues and cancels the delayed task that publishes a post.
+// The task is queued under the ID stored on the post, so that Cancel can
+// remove it later and the task can tell it wasn't replaced when it runs.
+type PostScheduler interface {
+	Schedule(postID int64, taskID string, delay time.Duration) error
+	Cancel(taskID string) error
+}
+
+// MediaProcessor queues the processing of media assets in the background,
+// such as the generation of their thumbnails and the conversion of videos.
+type MediaProcessor interface {
+	GenerateThumbnail(assetID int64) error
+	// Transcode is queued once per asset, while it isn't done
+	Transcode(assetID int64) error
+}
+
+// processAssets queues the processing of new assets. Assets are usable
+// without it, a failure is only logged.
+func processAssets(mp MediaProcessor, assetIDs ...int64) {
+	for _, assetID := range assetIDs {
+		if err := mp.GenerateThumbnail(assetID); err != nil {
+			slog.Error("unable to queue thumbnail generation", "asset_id", assetID, "error", err)
+		}
+		if err := mp.Transcode(assetID); err != nil {
+			slog.Error("unable to queue video conversion", "asset_id", assetID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/maheshrc27/scheduling-api/internal/models"
+	"github.com/maheshrc27/scheduling-api/internal/repository"
+	"github.com/maheshrc27/scheduling-api/internal/transfer"
+)
+
+// slotSearchDays is how far ahead a free posting slot is looked for.
+const slotSearchDays = 366
+
+type SettingsService interface {
+	CreateSlot(ctx context.Context, userID int64, sc *transfer.SlotCreation) (int64, error)
+	ListSlots(ctx context.Context, userID int64) ([]*models.Settings, error)
+	RemoveSlot(ctx context.Context, userID, slotID int64) error
+}
+
+type settingsService struct {
+	st repository.SettingsRepository
+	ac repository.SocialAccountRepository
+}
+
+func NewSettingsService(st repository.SettingsRepository, ac repository.SocialAccountRepository) SettingsService {
+	return &settingsService{
+		st: st,
+		ac: ac,
+	}
+}
+
+func (s *settingsService) CreateSlot(ctx context.Context, userID int64, sc *transfer.SlotCreation) (int64, error) {
+	if sc == nil {
+		err := errors.New("slot data is nil")
+		slog.Error(err.Error())
+		return 0, err
+	}
+
+	if sc.Weekday < 0 || sc.Weekday > 6 {
+		err := errors.New("weekday must be between 0 (Sunday) and 6 (Saturday)")
+		slog.Info(err.Error())
+		return 0, err
+	}
+
+	postingTime, err := time.Parse("15:04", sc.PostingTime)
+	if err != nil {
+		err = fmt.Errorf("invalid posting time format: %w", err)
+		slog.Info(err.Error())
+		return 0, err
+	}
+
+	if sc.AccountID != 0 {
+		exists, err := s.ac.CheckByUserID(ctx, sc.AccountID, userID)
+		if err != nil {
+			return 0, fmt.Errorf("Error checking social account")
+		}
+		if !exists {
+			err = fmt.Errorf("social account %d does not exist", sc.AccountID)
+			slog.Info(err.Error())
+			return 0, err
+		}
+	}
+
+	slot := &models.Settings{
+		UserID:      userID,
+		AccountID:   sc.AccountID,
+		Weekday:     sc.Weekday,
+		PostingTime: postingTime,
+		Category:    sc.Category,
+	}
+
+	id, err := s.st.Create(ctx, slot)
+	if err != nil {
+		return 0, fmt.Errorf("Error saving posting slot")
+	}
+	return id, nil
+}
+
+func (s *settingsService) ListSlots(ctx context.Context, userID int64) ([]*models.Settings, error) {
+	slots, err := s.st.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting posting slots")
+	}
+	return slots, nil
+}
+
+func (s *settingsService) RemoveSlot(ctx context.Context, userID, slotID int64) error {
+	var err error
+
+	if slotID == 0 {
+		err = errors.New("slot id is not valid")
+		slog.Info(err.Error())
+		return err
+	}
+
+	isValid, err := s.st.CheckByUserID(ctx, slotID, userID)
+	if err != nil {
+		return err
+	}
+
+	if !isValid {
+		err = errors.New("Slot doesn't exist")
+		slog.Info(err.Error())
+		return err
+	}
+
+	if err = s.st.Remove(ctx, slotID); err != nil {
+		return fmt.Errorf("Error removing posting slot")
+	}
+	return nil
+}
+
+// takenSlot is a posting time used by a queued post for one of its accounts
+type takenSlot struct {
+	unix      int64
+	accountID int64
+}
+
+// takeSlot marks a posting time as used for the accounts of a queued post
+func takeSlot(taken map[takenSlot]bool, t time.Time, accounts []int64) {
+	for _, accountID := range accounts {
+		taken[takenSlot{unix: t.Unix(), accountID: accountID}] = true
+	}
+}
+
+// nextSlot returns the first posting slot after from at which none of the
+// accounts has a queued post yet, in the location of from. A slot can be
+// used when it is for all accounts or for one of the given accounts, and
+// when it matches the category if one is given.
+func nextSlot(slots []*models.Settings, accounts []int64, category string, from time.Time, taken map[takenSlot]bool) (time.Time, bool) {
+	var usable []*models.Settings
+	for _, slot := range slots {
+		if category != "" && slot.Category != category {
+			continue
+		}
+		if slot.AccountID != 0 && !containsID(accounts, slot.AccountID) {
+			continue
+		}
+		usable = append(usable, slot)
+	}
+	if len(usable) == 0 {
+		return time.Time{}, false
+	}
+
+	sort.Slice(usable, func(i, j int) bool {
+		return usable[i].PostingTime.Before(usable[j].PostingTime)
+	})
+
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	for i := 0; i < slotSearchDays; i++ {
+		date := day.AddDate(0, 0, i)
+		for _, slot := range usable {
+			if time.Weekday(slot.Weekday) != date.Weekday() {
+				continue
+			}
+			hour, min, _ := slot.PostingTime.Clock()
+			t := time.Date(date.Year(), date.Month(), date.Day(), hour, min, 0, 0, date.Location())
+			if t.After(from) && !slotTaken(taken, t, accounts) {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func slotTaken(taken map[takenSlot]bool, t time.Time, accounts []int64) bool {
+	for _, accountID := range accounts {
+		if taken[takenSlot{unix: t.Unix(), accountID: accountID}] {
+			return true
+		}
+	}
+	return false
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
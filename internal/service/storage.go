@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	cfg "github.com/maheshrc27/scheduling-api/configs"
+)
+
+// Storage keeps the media files. Files are addressed by key and served
+// publicly from URL(key), which is what the platforms fetch them from.
+type Storage interface {
+	Put(ctx context.Context, key string, file io.Reader, size int64, filetype string) error
+	Delete(ctx context.Context, key string) error
+	Size(ctx context.Context, key string) (int64, error)
+	ReadRange(ctx context.Context, key string, offset, length int64) ([]byte, error)
+	URL(key string) string
+
+	// PresignPut returns a URL the client can PUT a file to, along with the
+	// headers the request must be sent with.
+	PresignPut(ctx context.Context, key, filetype string, size int64, expires time.Duration) (string, http.Header, error)
+
+	// Multipart uploads are assembled from parts numbered from 1, every part
+	// but the last must be at least 5 MB.
+	CreateMultipartUpload(ctx context.Context, key, filetype string) (string, error)
+	UploadPart(ctx context.Context, key, uploadID string, partNumber int32, data []byte) (string, error)
+	CompleteMultipartUpload(ctx context.Context, key, uploadID string, etags []string) error
+	AbortMultipartUpload(ctx context.Context, key, uploadID string) error
+
+	// Walk calls fn with every stored file, in no particular order. It stops
+	// at the first error fn returns.
+	Walk(ctx context.Context, fn func(file StoredFile) error) error
+}
+
+// StoredFile is a file listed by Storage.Walk.
+type StoredFile struct {
+	Key        string
+	Size       int64
+	ModifiedAt time.Time
+}
+
+// NewStorage returns the storage set in the config.
+func NewStorage(cfg cfg.Config) (Storage, error) {
+	switch cfg.Storage.Driver {
+	case "", "r2":
+		return NewR2Service(cfg), nil
+	case "local":
+		return NewLocalStorage(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Storage.Driver)
+	}
+}
+
+// storageFile reads a stored file in parts.
+type storageFile struct {
+	ctx   context.Context
+	store Storage
+	key   string
+}
+
+func (f *storageFile) ReadAt(p []byte, off int64) (int, error) {
+	data, err := f.store.ReadRange(f.ctx, f.key, off, int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, data)
+	if n < len(p) {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, nil
+}
+
+// storageKey returns the key of a file from its URL, when it is a file of
+// the storage.
+func storageKey(store Storage, url string) (string, bool) {
+	key, ok := strings.CutPrefix(url, store.URL(""))
+	return key, ok && key != ""
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"os/exec"
+	"strings"
+	"time"
+
+	config "github.com/maheshrc27/scheduling-api/configs"
+	"github.com/maheshrc27/scheduling-api/internal/models"
+	"github.com/maheshrc27/scheduling-api/internal/repository"
+	"github.com/maheshrc27/scheduling-api/pkg/utils"
+)
+
+const (
+	// thumbnailSize is the longest side of thumbnails, smaller media keep
+	// their size
+	thumbnailSize    = 640
+	thumbnailQuality = 80
+
+	// defaultCoverTimeMs is the frame of videos used as cover until the user
+	// picks another one
+	defaultCoverTimeMs = 1000
+
+	// maxImagePixels is the size of the largest image decoded, which takes
+	// 4 bytes a pixel in memory
+	maxImagePixels = 50_000_000
+)
+
+// ErrAssetNotFound is returned when an asset was removed before it could be
+// processed.
+var ErrAssetNotFound = errors.New("media asset doesn't exist")
+
+// ErrImageTooLarge is returned for images with more than maxImagePixels
+// pixels, they are never decoded.
+var ErrImageTooLarge = errors.New("image is too large to be processed")
+
+// ThumbnailService makes the thumbnails of media assets: a smaller copy of
+// images, and the frame at the cover time of videos.
+type ThumbnailService interface {
+	Generate(ctx context.Context, assetID int64) error
+}
+
+type thumbnailService struct {
+	ffmpeg string
+	ma     repository.MediaAssetRepository
+	store  Storage
+}
+
+func NewThumbnailService(cfg config.Config, ma repository.MediaAssetRepository, store Storage) ThumbnailService {
+	return &thumbnailService{
+		ffmpeg: cfg.FFmpegPath,
+		ma:     ma,
+		store:  store,
+	}
+}
+
+// Generate stores the thumbnail of an asset next to its file and sets it as
+// the thumbnail of the asset, replacing the previous one.
+func (s *thumbnailService) Generate(ctx context.Context, assetID int64) error {
+	asset, err := s.ma.GetByID(ctx, assetID)
+	if err != nil {
+		return fmt.Errorf("error getting media asset: %w", err)
+	}
+	if asset == nil {
+		return ErrAssetNotFound
+	}
+
+	var thumbnail []byte
+	if strings.HasPrefix(asset.FileType, "video/") {
+		thumbnail, err = s.posterFrame(ctx, asset)
+	} else {
+		thumbnail, err = s.imageThumbnail(ctx, asset)
+	}
+	if err != nil {
+		return err
+	}
+
+	key := thumbnailKey(asset)
+	if err := s.store.Put(ctx, key, bytes.NewReader(thumbnail), int64(len(thumbnail)), "image/jpeg"); err != nil {
+		return fmt.Errorf("error storing thumbnail: %w", err)
+	}
+
+	url := s.store.URL(key)
+	if url == asset.ThumbnailURL {
+		return nil
+	}
+	if err := s.ma.UpdateThumbnail(ctx, asset.ID, url); err != nil {
+		return fmt.Errorf("error saving thumbnail: %w", err)
+	}
+
+	// The poster frame of a previous cover time
+	if oldKey, ok := storageKey(s.store, asset.ThumbnailURL); ok {
+		if err := s.store.Delete(ctx, oldKey); err != nil {
+			return fmt.Errorf("error deleting previous thumbnail: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *thumbnailService) imageThumbnail(ctx context.Context, asset *models.MediaAsset) ([]byte, error) {
+	data, err := s.store.ReadRange(ctx, asset.FileName, 0, asset.FileSize)
+	if err != nil {
+		return nil, fmt.Errorf("error reading image: %w", err)
+	}
+
+	img, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := utils.FitSize(img.Bounds().Dx(), img.Bounds().Dy(), thumbnailSize)
+	return utils.EncodeJPEG(utils.Resize(img, width, height), thumbnailQuality)
+}
+
+// decodeImage decodes a JPEG or PNG image, once its headers show that it is
+// small enough.
+func decodeImage(data []byte) (image.Image, error) {
+	width, height, err := utils.ImageSize(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %w", err)
+	}
+	if width*height > maxImagePixels {
+		return nil, fmt.Errorf("%w: %dx%d", ErrImageTooLarge, width, height)
+	}
+
+	img, err := utils.DecodeImage(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %w", err)
+	}
+	return img, nil
+}
+
+// posterFrame extracts the frame at the cover time of a video with ffmpeg,
+// which only downloads the part of the file it needs.
+func (s *thumbnailService) posterFrame(ctx context.Context, asset *models.MediaAsset) ([]byte, error) {
+	seek := time.Duration(asset.CoverTimeMs) * time.Millisecond
+	scale := fmt.Sprintf("scale=w='min(%d,iw)':h='min(%d,ih)':force_original_aspect_ratio=decrease", thumbnailSize, thumbnailSize)
+
+	cmd := exec.CommandContext(ctx, s.ffmpeg,
+		"-v", "error",
+		"-ss", fmt.Sprintf("%.3f", seek.Seconds()),
+		"-i", asset.FileURL,
+		"-frames:v", "1",
+		"-vf", scale,
+		"-q:v", "3",
+		"-f", "image2",
+		"-c:v", "mjpeg",
+		"pipe:1",
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("error extracting poster frame: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("video has no frame at %s", seek)
+	}
+	return stdout.Bytes(), nil
+}
+
+// thumbnailKey is the storage key of the thumbnail of an asset. Poster
+// frames are named after their time, so that a new cover doesn't get a
+// cached copy of the previous one.
+func thumbnailKey(asset *models.MediaAsset) string {
+	if strings.HasPrefix(asset.FileType, "video/") {
+		return fmt.Sprintf("%s-poster-%d.jpg", asset.FileName, asset.CoverTimeMs)
+	}
+	return asset.FileName + "-thumb.jpg"
+}
+
+// defaultCoverTime picks the cover frame of a new video: a second in, or
+// the middle of shorter videos.
+func defaultCoverTime(info MediaInfo) int {
+	if !info.IsVideo() {
+		return 0
+	}
+	if duration := int(info.Duration.Milliseconds()); duration > 0 && duration < 2*defaultCoverTimeMs {
+		return duration / 2
+	}
+	return defaultCoverTimeMs
+}
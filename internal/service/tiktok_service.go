@@ -23,10 +23,15 @@ import (
 
 const tiktokTokenURL = "https://open.tiktokapis.com/v2/oauth/token/"
 
+const (
+	tiktokVideoInitURL   = "https://open.tiktokapis.com/v2/post/publish/video/init/"
+	tiktokContentInitURL = "https://open.tiktokapis.com/v2/post/publish/content/init/"
+)
+
 type TiktokService interface {
 	TiktokCallback(ctx context.Context, code string, userID int64) (err error)
 	RefreshTiktokToken(ctx context.Context, userID int64, accessToken, refreshToken string) error
-	HandleTiktokPost(ctx context.Context, post *models.Post, acc *models.SocialAccount) error
+	HandleTiktokPost(ctx context.Context, post *models.Post, acc *models.SocialAccount) (*transfer.PublishResult, error)
 }
 
 type tiktokService struct {
@@ -35,6 +40,8 @@ type tiktokService struct {
 	sa  repository.SocialAccountRepository
 	pm  repository.PostMediaRepository
 	ma  repository.MediaAssetRepository
+	iv  ImageVariants
+	vr  VideoRenditions
 }
 
 func NewTiktokService(
@@ -42,13 +49,17 @@ func NewTiktokService(
 	p repository.PostRepository,
 	sa repository.SocialAccountRepository,
 	pm repository.PostMediaRepository,
-	ma repository.MediaAssetRepository) TiktokService {
+	ma repository.MediaAssetRepository,
+	iv ImageVariants,
+	vr VideoRenditions) TiktokService {
 	return &tiktokService{
 		cfg: cfg,
 		p:   p,
 		sa:  sa,
 		pm:  pm,
 		ma:  ma,
+		iv:  iv,
+		vr:  vr,
 	}
 }
 
@@ -241,89 +252,74 @@ func (s *tiktokService) RefreshTiktokToken(ctx context.Context, userID int64, ac
 	return nil
 }
 
-func (s *tiktokService) HandleTiktokPost(ctx context.Context, post *models.Post, acc *models.SocialAccount) error {
+func (s *tiktokService) HandleTiktokPost(ctx context.Context, post *models.Post, acc *models.SocialAccount) (*transfer.PublishResult, error) {
+	var publishID string
 	var err error
 	switch post.PostType {
 
 	case "multiple":
-		err = s.PostTiktokPhotos(ctx, post, acc)
+		publishID, err = s.PostTiktokPhotos(ctx, post, acc)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	default:
-		err = s.PostTiktokVideo(ctx, post, acc)
+		publishID, err = s.PostTiktokVideo(ctx, post, acc)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	if err := s.p.UpdatePostStatus(ctx, models.PostStatusPosted, post.ID); err != nil {
-		return fmt.Errorf("failed to update status: %w", err)
-	}
-
-	return nil
+	// TikTok only exposes the public post ID once moderation is done, so the
+	// publish ID is what we can track the post by.
+	return &transfer.PublishResult{MediaID: publishID}, nil
 }
 
-func (s *tiktokService) PostTiktokVideo(ctx context.Context, post *models.Post, acc *models.SocialAccount) error {
+func (s *tiktokService) PostTiktokVideo(ctx context.Context, post *models.Post, acc *models.SocialAccount) (string, error) {
 
 	decryptedAccessToken, err := utils.Decrypt(acc.AccessToken, []byte(s.cfg.SecretKey))
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	postMedia, err := s.pm.GetByPostID(ctx, post.ID)
 	if err != nil {
 		log.Printf("Error getting post media: %v", err)
-		return err
+		return "", err
 	}
 
 	videoInfo, err := s.ma.GetByID(ctx, postMedia.AssetID)
 	if err != nil {
 		log.Printf("Error getting asset info: %v", err)
-		return err
-	}
-
-	// Set post_info
-	postInfo := transfer.VideoPostInfo{
-		Title:                 post.Caption,
-		PrivacyLevel:          "PUBLIC_TO_EVERYONE",
-		DisableDuet:           false,
-		DisableComment:        false,
-		DisableStitch:         false,
-		VideoCoverTimestampMs: 1000,
+		return "", err
 	}
 
-	// Set video source_info
-	sourceInfo := transfer.VideoSourceInfo{
-		Source:   "PULL_FROM_URL",
-		VideoURL: videoInfo.FileURL,
+	videoURL, err := s.vr.URL(ctx, videoInfo, "tiktok")
+	if err != nil {
+		return "", err
 	}
 
 	// Prepare the request payload
-	videoUploadRequest := transfer.VideoUploadRequest{
-		PostInfo:   postInfo,
-		SourceInfo: sourceInfo,
-	}
+	videoUploadRequest := tiktokVideoRequest(post, videoURL, videoInfo.CoverTimeMs)
 
 	// Marshal the request data into JSON
 	jsonData, err := json.Marshal(videoUploadRequest)
 	if err != nil {
 		log.Println("Error marshalling data:", err)
-		return err
+		return "", err
 	}
 
 	err = QueryCreatorInfoRequest(decryptedAccessToken)
 	if err != nil {
 		log.Println("Error querying creator info: ", err.Error())
-		return err
+		return "", err
 	}
 
 	// Send the request to TikTok API
-	uploadURL := "https://open.tiktokapis.com/v2/post/publish/video/init/"
+	uploadURL := tiktokVideoInitURL
 	req, err := http.NewRequest("POST", uploadURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		log.Println("Error creating request:", err)
-		return err
+		return "", err
 	}
 	req.Header.Set("Authorization", "Bearer "+decryptedAccessToken)
 	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
@@ -333,87 +329,71 @@ func (s *tiktokService) PostTiktokVideo(ctx context.Context, post *models.Post,
 	resp, err := client.Do(req)
 	if err != nil {
 		log.Println("Error uploading video:", err)
-		return err
+		return "", err
 	}
 	defer resp.Body.Close()
 
 	var result transfer.TikTokUploadResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		log.Println(err.Error())
-		return err
+		return "", err
 	}
 
 	// Check the response status
 	if resp.StatusCode != http.StatusOK {
 		log.Printf("Error posting video on tiktok: %s", result.Error.Message)
-		return err
+		return "", fmt.Errorf("error posting video on tiktok: %s", result.Error.Message)
 	}
 
 	log.Printf("Tiktok Publish Data: %v", result)
 
-	return nil
+	return result.Data.PublishID, nil
 }
 
-func (s *tiktokService) PostTiktokPhotos(ctx context.Context, post *models.Post, acc *models.SocialAccount) error {
+func (s *tiktokService) PostTiktokPhotos(ctx context.Context, post *models.Post, acc *models.SocialAccount) (string, error) {
 	decryptedAccessToken, err := utils.Decrypt(acc.AccessToken, []byte(s.cfg.SecretKey))
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	postMedias, err := s.pm.ListByPostID(ctx, post.ID)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	photos := make([]string, len(postMedias))
+	photos := make([]string, 0, len(postMedias))
 
 	for _, postMedia := range postMedias {
 		assetInfo, err := s.ma.GetByID(ctx, postMedia.AssetID)
 		if err != nil {
-			return err
+			return "", err
 		}
-		photos = append(photos, assetInfo.FileURL)
-	}
-
-	postInfo := transfer.PhotoPostInfo{
-		Title:                post.Caption,
-		PrivacyLevel:         "PUBLIC_TO_EVERYONE",
-		AutoAddMusic:         true,
-		DisableComment:       false,
-		BrandContentToggle:   false,
-		Brand_Organic_Toggle: false,
-	}
-
-	sourceInfo := transfer.PhotoSourceInfo{
-		Source:          "PULL_FROM_URL",
-		PhotoCoverIndex: 1,
-		PhotoImages:     photos,
+		photoURL, err := s.iv.URL(ctx, assetInfo, "tiktok", post.Options.ImageFit)
+		if err != nil {
+			return "", err
+		}
+		photos = append(photos, photoURL)
 	}
 
-	photoUploadRequest := transfer.PhotUploadRequest{
-		PostInfo:   postInfo,
-		SourceInfo: sourceInfo,
-		PostMode:   "DIRECT_POST",
-		MediaType:  "PHOTO",
-	}
+	photoUploadRequest := tiktokPhotoRequest(post, photos)
 
 	jsonData, err := json.Marshal(photoUploadRequest)
 	if err != nil {
 		log.Println("Error marshalling data:", err)
-		return err
+		return "", err
 	}
 
 	err = QueryCreatorInfoRequest(decryptedAccessToken)
 	if err != nil {
 		log.Println("Error querying creator info: ", err.Error())
-		return err
+		return "", err
 	}
 
-	uploadURL := "https://open.tiktokapis.com/v2/post/publish/content/init/"
+	uploadURL := tiktokContentInitURL
 	req, err := http.NewRequest("POST", uploadURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		log.Println("Error creating request:", err)
-		return err
+		return "", err
 	}
 	req.Header.Set("Authorization", "Bearer "+decryptedAccessToken)
 	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
@@ -422,24 +402,75 @@ func (s *tiktokService) PostTiktokPhotos(ctx context.Context, post *models.Post,
 	resp, err := client.Do(req)
 	if err != nil {
 		log.Println("Error uploading video:", err)
-		return err
+		return "", err
 	}
 	defer resp.Body.Close()
 
 	var result transfer.TikTokUploadResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		log.Println(err.Error())
-		return err
+		return "", err
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		log.Printf("Error posting phostos on tiktok: %s", result.Error.Message)
-		return err
+		return "", fmt.Errorf("error posting photos on tiktok: %s", result.Error.Message)
 	}
 
 	log.Printf("Tiktok Publish Data: %v", result)
 
-	return nil
+	return result.Data.PublishID, nil
+}
+
+// tiktokVideoRequest is the body of the request publishing a video post,
+// with the frame at coverTimeMs as cover.
+func tiktokVideoRequest(post *models.Post, videoURL string, coverTimeMs int) transfer.VideoUploadRequest {
+	return transfer.VideoUploadRequest{
+		PostInfo: transfer.VideoPostInfo{
+			Title:                 post.Caption,
+			PrivacyLevel:          tiktokPrivacyLevel(post.Options),
+			DisableDuet:           optionSet(post.Options.DisableDuet),
+			DisableComment:        optionSet(post.Options.DisableComment),
+			DisableStitch:         optionSet(post.Options.DisableStitch),
+			VideoCoverTimestampMs: coverTimeMs,
+		},
+		SourceInfo: transfer.VideoSourceInfo{
+			Source:   "PULL_FROM_URL",
+			VideoURL: videoURL,
+		},
+	}
+}
+
+// tiktokPhotoRequest is the body of the request publishing a photo post.
+func tiktokPhotoRequest(post *models.Post, photos []string) transfer.PhotUploadRequest {
+	return transfer.PhotUploadRequest{
+		PostInfo: transfer.PhotoPostInfo{
+			Title:                post.Caption,
+			PrivacyLevel:         tiktokPrivacyLevel(post.Options),
+			AutoAddMusic:         true,
+			DisableComment:       optionSet(post.Options.DisableComment),
+			BrandContentToggle:   false,
+			Brand_Organic_Toggle: false,
+		},
+		SourceInfo: transfer.PhotoSourceInfo{
+			Source:          "PULL_FROM_URL",
+			PhotoCoverIndex: 1,
+			PhotoImages:     photos,
+		},
+		PostMode:  "DIRECT_POST",
+		MediaType: "PHOTO",
+	}
+}
+
+func tiktokPrivacyLevel(options models.PublishOptions) string {
+	if options.PrivacyLevel != "" {
+		return options.PrivacyLevel
+	}
+	return "PUBLIC_TO_EVERYONE"
+}
+
+func optionSet(option *bool) bool {
+	return option != nil && *option
 }
 
 func QueryCreatorInfoRequest(accessToken string) error {
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/maheshrc27/scheduling-api/internal/repository"
+)
+
+const DefaultTimezone = "UTC"
+
+// LoadZone returns the location for an IANA zone name such as
+// "Europe/Berlin" or a fixed RFC 3339 offset such as "+02:00". An empty
+// name is UTC.
+func LoadZone(name string) (*time.Location, error) {
+	if name == "" || name == "Z" {
+		return time.UTC, nil
+	}
+
+	if offset, err := time.Parse("-07:00", name); err == nil {
+		_, seconds := offset.Zone()
+		return time.FixedZone(name, seconds), nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q", name)
+	}
+	return loc, nil
+}
+
+// userZone returns the default timezone of the user.
+func userZone(ctx context.Context, ur repository.UserRepository, userID int64) string {
+	user, exists, err := ur.GetByID(ctx, userID)
+	if err != nil || !exists || user.Timezone == "" {
+		return DefaultTimezone
+	}
+	return user.Timezone
+}
+
+// locationOrUTC is LoadZone for zones that were already validated when they
+// were stored.
+func locationOrUTC(name string) *time.Location {
+	loc, err := LoadZone(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// parseScheduledTime reads a scheduling time either as RFC 3339 with its own
+// offset or as "2006-01-02T15:04" in zone, or in fallback when no zone is
+// given. It returns the time in UTC along with the zone to keep on the post,
+// which for an RFC 3339 time without a zone is its offset.
+func parseScheduledTime(value, zone, fallback string) (time.Time, string, error) {
+	if _, err := LoadZone(zone); err != nil {
+		slog.Info(err.Error())
+		return time.Time{}, "", err
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		if zone == "" {
+			zone = t.Format("Z07:00")
+		}
+		return t.UTC(), zone, nil
+	}
+
+	if zone == "" {
+		zone = fallback
+	}
+	loc, err := LoadZone(zone)
+	if err != nil {
+		slog.Info(err.Error())
+		return time.Time{}, "", err
+	}
+
+	t, err := time.ParseInLocation("2006-01-02T15:04", value, loc)
+	if err != nil {
+		err = fmt.Errorf("invalid scheduled time format: %w", err)
+		slog.Error(err.Error())
+		return time.Time{}, "", err
+	}
+	if zone == "" {
+		zone = DefaultTimezone
+	}
+	return t.UTC(), zone, nil
+}
@@ -12,6 +12,7 @@ import (
 
 type UserService interface {
 	GetUserInfo(ctx context.Context, id int64) (*models.User, error)
+	SetTimezone(ctx context.Context, userID int64, timezone string) error
 	RemoveUser(ctx context.Context, userID int64) error
 }
 
@@ -40,6 +41,24 @@ func (s *userService) GetUserInfo(ctx context.Context, id int64) (*models.User,
 	return user, nil
 }
 
+func (s *userService) SetTimezone(ctx context.Context, userID int64, timezone string) error {
+	if timezone == "" {
+		err := errors.New("timezone cannot be empty")
+		slog.Info(err.Error())
+		return err
+	}
+
+	if _, err := LoadZone(timezone); err != nil {
+		slog.Info(err.Error())
+		return err
+	}
+
+	if err := s.u.SetTimezone(ctx, userID, timezone); err != nil {
+		return fmt.Errorf("Error updating timezone")
+	}
+	return nil
+}
+
 func (s *userService) RemoveUser(ctx context.Context, userID int64) error {
 	err := s.u.Remove(ctx, userID)
 	if err != nil {
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/maheshrc27/scheduling-api/internal/models"
+)
+
+const (
+	mb = 1 << 20
+	gb = 1 << 30
+)
+
+// PlatformRules are the limits a post has to fit in to be published to a
+// platform. Zero values mean there is no limit.
+type PlatformRules struct {
+	CaptionLength      int
+	PhotoCaptionLength int // caption limit of posts with only images
+	TitleLength        int
+	TitleRequired      bool
+	Hashtags           int
+	MaxMedia           int
+	MaxVideos          int
+	Images             bool
+	Videos             bool
+	MixedMedia         bool // images and videos in the same post
+	ImageSize          int64
+	VideoSize          int64
+	VideoDuration      time.Duration
+	// Images are published as variants that fit these, see ImageVariants
+	MinAspectRatio    float64 // width over height
+	MaxAspectRatio    float64
+	MaxImageDimension int
+	JPEGImages        bool // other formats are re-encoded
+	MinVideoDimension int  // of the width and the height
+	// Videos are published as renditions that fit these, see VideoRenditions
+	VideoCodecs       []string // published without being converted
+	MaxVideoDimension int
+	MaxVideoBitrate   int64 // bits per second
+	MinFrameRate      float64
+	MaxFrameRate      float64
+}
+
+var platformRules = map[string]PlatformRules{
+	"instagram": {
+		CaptionLength:     2200,
+		Hashtags:          30,
+		MaxMedia:          10,
+		Images:            true,
+		Videos:            true,
+		MixedMedia:        true,
+		ImageSize:         8 * mb,
+		VideoSize:         1 * gb,
+		VideoDuration:     15 * time.Minute,
+		MinAspectRatio:    4.0 / 5,
+		MaxAspectRatio:    1.91,
+		MaxImageDimension: 1440,
+		JPEGImages:        true,
+		VideoCodecs:       []string{"h264", "hevc"},
+		MaxVideoDimension: 1920,
+		MaxVideoBitrate:   8_000_000,
+		MinFrameRate:      23,
+		MaxFrameRate:      60,
+	},
+	"tiktok": {
+		CaptionLength:      2200,
+		PhotoCaptionLength: 90,
+		MaxMedia:           35,
+		MaxVideos:          1,
+		Images:             true,
+		Videos:             true,
+		ImageSize:          20 * mb,
+		VideoSize:          4 * gb,
+		VideoDuration:      10 * time.Minute,
+		MaxImageDimension:  1920,
+		JPEGImages:         true,
+		MinVideoDimension:  360,
+		VideoCodecs:        []string{"h264", "hevc"},
+		MaxVideoDimension:  1920,
+	},
+	"youtube": {
+		CaptionLength: 5000,
+		TitleLength:   100,
+		TitleRequired: true,
+		Hashtags:      15,
+		MaxMedia:      1,
+		Videos:        true,
+		VideoSize:     256 * gb,
+		VideoDuration: 12 * time.Hour,
+	},
+}
+
+// resizesImages tells whether images are published as variants.
+func (r PlatformRules) resizesImages() bool {
+	return r.MinAspectRatio > 0 || r.MaxAspectRatio > 0 || r.MaxImageDimension > 0 || r.JPEGImages
+}
+
+// transcodesVideos tells whether videos are published as renditions.
+func (r PlatformRules) transcodesVideos() bool {
+	return len(r.VideoCodecs) > 0
+}
+
+var hashtagPattern = regexp.MustCompile(`#[\p{L}\p{N}_]+`)
+
+// MediaInfo describes a media file of a post for validation. Properties
+// that couldn't be read are left zero.
+type MediaInfo struct {
+	MIME      string
+	Size      int64
+	Width     int
+	Height    int
+	Duration  time.Duration // videos only
+	FrameRate float64
+	Codec     string
+}
+
+func (m MediaInfo) IsVideo() bool {
+	return strings.HasPrefix(m.MIME, "video/")
+}
+
+// AspectRatio is the width over the height, 0 when the size is unknown.
+func (m MediaInfo) AspectRatio() float64 {
+	if m.Width == 0 || m.Height == 0 {
+		return 0
+	}
+	return float64(m.Width) / float64(m.Height)
+}
+
+// assetInfo describes a stored media asset.
+func assetInfo(asset *models.MediaAsset) MediaInfo {
+	return MediaInfo{
+		MIME:      asset.FileType,
+		Size:      asset.FileSize,
+		Width:     asset.Width,
+		Height:    asset.Height,
+		Duration:  time.Duration(asset.Duration) * time.Second,
+		FrameRate: asset.FrameRate,
+		Codec:     asset.Codec,
+	}
+}
+
+// ValidationIssue is a reason a post can't be published to one of its
+// accounts.
+type ValidationIssue struct {
+	AccountID int64  `json:"account_id"`
+	Platform  string `json:"platform"`
+	Field     string `json:"field"`
+	Message   string `json:"message"`
+}
+
+// ValidationError lists every issue found for the accounts of a post.
+type ValidationError struct {
+	Issues []ValidationIssue
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Issues) == 1 {
+		i := e.Issues[0]
+		return fmt.Sprintf("post is not valid for %s account %d: %s", i.Platform, i.AccountID, i.Message)
+	}
+	return fmt.Sprintf("post is not valid for its accounts, %d issues found", len(e.Issues))
+}
+
+// validatePost checks the post, with the overrides of each delivery
+// applied, against the rules of the delivery's platform. It returns a
+// *ValidationError when any of them is broken.
+func validatePost(post *models.Post, deliveries []*models.SelectedAccount, overrides []*models.PlatformOverride, media []MediaInfo) error {
+	var issues []ValidationIssue
+	for _, d := range deliveries {
+		rules, ok := platformRules[d.Platform]
+		if !ok {
+			continue
+		}
+		for _, issue := range checkRules(rules, PostForDelivery(post, d, overrides), media) {
+			issue.AccountID = d.AccountID
+			issue.Platform = d.Platform
+			issues = append(issues, issue)
+		}
+	}
+
+	if len(issues) > 0 {
+		return &ValidationError{Issues: issues}
+	}
+	return nil
+}
+
+// checkRules returns the rules the post breaks, without the account they
+// are for.
+func checkRules(rules PlatformRules, post *models.Post, media []MediaInfo) []ValidationIssue {
+	var broken []ValidationIssue
+	add := func(field, format string, args ...any) {
+		broken = append(broken, ValidationIssue{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var images, videos int
+	for _, m := range media {
+		if m.IsVideo() {
+			videos++
+		} else {
+			images++
+		}
+	}
+
+	captionLength := rules.CaptionLength
+	if videos == 0 && images > 0 && rules.PhotoCaptionLength > 0 {
+		captionLength = rules.PhotoCaptionLength
+	}
+	if n := utf8.RuneCountInString(post.Caption); captionLength > 0 && n > captionLength {
+		add("caption", "caption is %d characters long, the limit is %d", n, captionLength)
+	}
+	if n := len(hashtagPattern.FindAllString(post.Caption, -1)); rules.Hashtags > 0 && n > rules.Hashtags {
+		add("hashtags", "caption has %d hashtags, the limit is %d", n, rules.Hashtags)
+	}
+
+	if rules.TitleRequired && strings.TrimSpace(post.Title) == "" {
+		add("title", "title is required")
+	}
+	if n := utf8.RuneCountInString(post.Title); rules.TitleLength > 0 && n > rules.TitleLength {
+		add("title", "title is %d characters long, the limit is %d", n, rules.TitleLength)
+	}
+
+	switch {
+	case len(media) == 0:
+		add("media", "at least one media file is required")
+	case rules.MaxMedia > 0 && len(media) > rules.MaxMedia:
+		add("media", "post has %d media files, the limit is %d", len(media), rules.MaxMedia)
+	}
+	if images > 0 && !rules.Images {
+		add("media", "images are not supported")
+	}
+	if videos > 0 && !rules.Videos {
+		add("media", "videos are not supported")
+	}
+	if images > 0 && videos > 0 && !rules.MixedMedia && rules.Images && rules.Videos {
+		add("media", "images and videos can't be posted together")
+	}
+	if rules.MaxVideos > 0 && videos > rules.MaxVideos {
+		add("media", "post has %d videos, the limit is %d", videos, rules.MaxVideos)
+	}
+
+	for i, m := range media {
+		// Images are resized to fit the platform, see ImageVariants
+		if !m.IsVideo() && rules.resizesImages() {
+			continue
+		}
+		if m.IsVideo() && m.Width > 0 && rules.MinVideoDimension > 0 && min(m.Width, m.Height) < rules.MinVideoDimension {
+			add("media", "video %d is %dx%d, it must be at least %d pixels wide and high", i+1, m.Width, m.Height, rules.MinVideoDimension)
+		}
+		// Videos are converted and trimmed to fit, see VideoRenditions
+		if m.IsVideo() && rules.transcodesVideos() {
+			continue
+		}
+
+		limit := rules.ImageSize
+		if m.IsVideo() {
+			limit = rules.VideoSize
+		}
+		if limit > 0 && m.Size > limit {
+			add("media", "file %d is %s, the limit is %s", i+1, formatSize(m.Size), formatSize(limit))
+		}
+		if m.IsVideo() && rules.VideoDuration > 0 && m.Duration > rules.VideoDuration {
+			add("media", "video %d is %s long, the limit is %s", i+1, m.Duration.Round(time.Second), rules.VideoDuration)
+		}
+		if m.IsVideo() && m.FrameRate > 0 {
+			if (rules.MinFrameRate > 0 && m.FrameRate < rules.MinFrameRate) || (rules.MaxFrameRate > 0 && m.FrameRate > rules.MaxFrameRate+0.5) {
+				add("media", "video %d is %.0f fps, the frame rate must be between %.0f and %.0f", i+1, m.FrameRate, rules.MinFrameRate, rules.MaxFrameRate)
+			}
+		}
+	}
+
+	return broken
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size >= gb:
+		return fmt.Sprintf("%.1f GB", float64(size)/gb)
+	case size >= mb:
+		return fmt.Sprintf("%.1f MB", float64(size)/mb)
+	default:
+		return fmt.Sprintf("%d bytes", size)
+	}
+}
@@ -0,0 +1,402 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	config "github.com/maheshrc27/scheduling-api/configs"
+	"github.com/maheshrc27/scheduling-api/internal/models"
+	"github.com/maheshrc27/scheduling-api/internal/repository"
+	"github.com/maheshrc27/scheduling-api/pkg/utils"
+)
+
+// ErrRenditionPending is returned while a video is converted for a
+// platform, it can't be published there yet.
+var ErrRenditionPending = errors.New("video is still being converted")
+
+const (
+	renditionCRF          = "21"
+	renditionAudioBitrate = "128k"
+)
+
+// VideoRenditions converts videos that don't fit the rules of a platform to
+// MP4 files it takes: H.264 and AAC, within its size, frame rates and
+// bitrate, and trimmed to its duration limit. Renditions are made in the
+// background once a video is uploaded.
+type VideoRenditions interface {
+	// URL returns the URL to publish the video of an asset from, the asset
+	// itself when it already fits the platform. ErrRenditionPending is
+	// returned until its rendition is ready.
+	URL(ctx context.Context, asset *models.MediaAsset, platform string) (string, error)
+	// Transcode makes the renditions an asset needs.
+	Transcode(ctx context.Context, assetID int64) error
+	// Retry converts the renditions of an asset that failed again.
+	Retry(ctx context.Context, assetID int64) error
+	List(ctx context.Context, assetID int64) ([]*models.MediaRendition, error)
+}
+
+type videoRenditions struct {
+	ffmpeg string
+	ma     repository.MediaAssetRepository
+	mr     repository.MediaRenditionRepository
+	store  Storage
+	mp     MediaProcessor
+}
+
+func NewVideoRenditions(
+	cfg config.Config,
+	ma repository.MediaAssetRepository,
+	mr repository.MediaRenditionRepository,
+	store Storage,
+	mp MediaProcessor) VideoRenditions {
+	return &videoRenditions{
+		ffmpeg: cfg.FFmpegPath,
+		ma:     ma,
+		mr:     mr,
+		store:  store,
+		mp:     mp,
+	}
+}
+
+func (v *videoRenditions) URL(ctx context.Context, asset *models.MediaAsset, platform string) (string, error) {
+	if !strings.HasPrefix(asset.FileType, "video/") || fitsVideoRules(platformRules[platform], asset) {
+		return asset.FileURL, nil
+	}
+
+	rendition, err := v.mr.Get(ctx, asset.ID, platform)
+	if err != nil {
+		return "", fmt.Errorf("error getting video rendition: %w", err)
+	}
+
+	switch {
+	case rendition == nil:
+		// Videos uploaded before the platform needed a rendition
+		if err := v.mp.Transcode(asset.ID); err != nil {
+			return "", fmt.Errorf("error queueing video conversion: %w", err)
+		}
+		return "", ErrRenditionPending
+	case rendition.Status == models.RenditionStatusReady:
+		return rendition.FileURL, nil
+	case rendition.Status == models.RenditionStatusFailed:
+		return "", fmt.Errorf("video could not be converted for %s: %s", platform, rendition.ErrorMessage)
+	default:
+		return "", ErrRenditionPending
+	}
+}
+
+func (v *videoRenditions) Transcode(ctx context.Context, assetID int64) (err error) {
+	// Renditions the task didn't get to fail with it, rather than keeping
+	// posts waiting
+	defer func() {
+		if err != nil && !errors.Is(err, ErrAssetNotFound) {
+			v.failUnfinished(context.WithoutCancel(ctx), assetID, err)
+		}
+	}()
+
+	asset, err := v.ma.GetByID(ctx, assetID)
+	if err != nil {
+		return fmt.Errorf("error getting media asset: %w", err)
+	}
+	if asset == nil {
+		return ErrAssetNotFound
+	}
+	if !strings.HasPrefix(asset.FileType, "video/") {
+		return nil
+	}
+
+	// Every rendition is pending before the first one starts, so that
+	// publishing waits for all of them
+	var platforms []string
+	for platform, rules := range platformRules {
+		if !fitsVideoRules(rules, asset) {
+			platforms = append(platforms, platform)
+		}
+	}
+	slices.Sort(platforms)
+
+	for _, platform := range platforms {
+		rendition := &models.MediaRendition{AssetID: asset.ID, Platform: platform, Status: models.RenditionStatusPending}
+		if err := v.mr.Create(ctx, rendition); err != nil {
+			return fmt.Errorf("error saving video rendition: %w", err)
+		}
+	}
+
+	var failed []string
+	for _, platform := range platforms {
+		rendition, err := v.mr.Get(ctx, asset.ID, platform)
+		if err != nil {
+			return fmt.Errorf("error getting video rendition: %w", err)
+		}
+		if rendition == nil || rendition.Status == models.RenditionStatusReady {
+			continue
+		}
+
+		if err := v.transcode(ctx, asset, platformRules[platform], rendition); err != nil {
+			slog.Error("unable to convert video", "asset_id", asset.ID, "platform", platform, "error", err)
+
+			rendition.Status = models.RenditionStatusFailed
+			rendition.ErrorMessage = err.Error()
+			if err := v.mr.Update(context.WithoutCancel(ctx), rendition); err != nil {
+				slog.Error("unable to save failed video rendition", "asset_id", asset.ID, "platform", platform, "error", err)
+			}
+			failed = append(failed, platform)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("video could not be converted for %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// transcode converts the video of an asset for the rules of a platform and
+// stores it next to the video.
+func (v *videoRenditions) transcode(ctx context.Context, asset *models.MediaAsset, rules PlatformRules, rendition *models.MediaRendition) error {
+	rendition.Status = models.RenditionStatusProcessing
+	rendition.Progress = 0
+	rendition.ErrorMessage = ""
+	if err := v.mr.Update(ctx, rendition); err != nil {
+		return fmt.Errorf("error saving video rendition: %w", err)
+	}
+
+	output, err := os.CreateTemp("", "rendition-*.mp4")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+	output.Close()
+	defer os.Remove(output.Name())
+
+	// The duration of the output, for the progress
+	duration := time.Duration(asset.Duration) * time.Second
+	if rules.VideoDuration > 0 && (duration == 0 || duration > rules.VideoDuration) {
+		duration = rules.VideoDuration
+	}
+
+	cmd := exec.CommandContext(ctx, v.ffmpeg, renditionArgs(asset, rules, output.Name())...)
+	progress, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error starting ffmpeg: %w", err)
+	}
+	v.trackProgress(ctx, rendition, progress, duration)
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("ffmpeg: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	file, err := os.Open(output.Name())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	info, err := utils.MP4Info(file, stat.Size())
+	if err != nil {
+		return fmt.Errorf("error reading converted video: %w", err)
+	}
+
+	key := fmt.Sprintf("%s-%s.mp4", asset.FileName, rendition.Platform)
+	if err := v.store.Put(ctx, key, file, stat.Size(), "video/mp4"); err != nil {
+		return fmt.Errorf("error storing converted video: %w", err)
+	}
+
+	rendition.Status = models.RenditionStatusReady
+	rendition.Progress = 100
+	rendition.FileKey = key
+	rendition.FileURL = v.store.URL(key)
+	rendition.FileSize = stat.Size()
+	rendition.Width = info.Width
+	rendition.Height = info.Height
+	rendition.Duration = int(info.Duration.Round(time.Second).Seconds())
+	if err := v.mr.Update(ctx, rendition); err != nil {
+		return fmt.Errorf("error saving video rendition: %w", err)
+	}
+	return nil
+}
+
+// trackProgress saves the progress ffmpeg reports as it converts a video of
+// the given duration, every few percent.
+func (v *videoRenditions) trackProgress(ctx context.Context, rendition *models.MediaRendition, progress io.Reader, duration time.Duration) {
+	scanner := bufio.NewScanner(progress)
+	for scanner.Scan() {
+		// out_time_us is the position reached in the output, in microseconds
+		value, ok := strings.CutPrefix(scanner.Text(), "out_time_us=")
+		if !ok || duration <= 0 {
+			continue
+		}
+		position, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		percent := min(99, int(time.Duration(position)*time.Microsecond*100/duration))
+		if percent >= rendition.Progress+5 {
+			rendition.Progress = percent
+			if err := v.mr.UpdateProgress(ctx, rendition.ID, percent); err != nil {
+				slog.Error("unable to save video conversion progress", "rendition_id", rendition.ID, "error", err)
+			}
+		}
+	}
+}
+
+// renditionArgs are the ffmpeg arguments converting the video of an asset
+// for the rules of a platform. ffmpeg reads the video from its URL.
+func renditionArgs(asset *models.MediaAsset, rules PlatformRules, output string) []string {
+	args := []string{
+		"-v", "error",
+		"-nostats",
+		"-progress", "pipe:1",
+		"-y",
+		"-i", asset.FileURL,
+	}
+	if rules.VideoDuration > 0 {
+		args = append(args, "-t", strconv.Itoa(int(rules.VideoDuration.Seconds())))
+	}
+
+	maxDimension := rules.MaxVideoDimension
+	if maxDimension == 0 {
+		maxDimension = 4096
+	}
+	filters := fmt.Sprintf("scale=w='min(%d,iw)':h='min(%d,ih)':force_original_aspect_ratio=decrease:force_divisible_by=2", maxDimension, maxDimension)
+	switch {
+	case asset.FrameRate == 0:
+	case rules.MaxFrameRate > 0 && asset.FrameRate > rules.MaxFrameRate:
+		filters += fmt.Sprintf(",fps=%g", rules.MaxFrameRate)
+	case rules.MinFrameRate > 0 && asset.FrameRate < rules.MinFrameRate:
+		filters += fmt.Sprintf(",fps=%g", rules.MinFrameRate)
+	}
+
+	args = append(args,
+		"-map", "0:v:0",
+		"-map", "0:a:0?",
+		"-vf", filters,
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-profile:v", "high",
+		"-pix_fmt", "yuv420p",
+		"-crf", renditionCRF,
+	)
+	if rules.MaxVideoBitrate > 0 {
+		args = append(args,
+			"-maxrate", strconv.FormatInt(rules.MaxVideoBitrate, 10),
+			"-bufsize", strconv.FormatInt(2*rules.MaxVideoBitrate, 10),
+		)
+	}
+	return append(args,
+		"-c:a", "aac",
+		"-b:a", renditionAudioBitrate,
+		"-ac", "2",
+		"-ar", "48000",
+		"-movflags", "+faststart",
+		output,
+	)
+}
+
+// failUnfinished marks the renditions of an asset that are still pending or
+// being converted as failed.
+func (v *videoRenditions) failUnfinished(ctx context.Context, assetID int64, cause error) {
+	renditions, err := v.mr.ListByAssetID(ctx, assetID)
+	if err != nil {
+		slog.Error("unable to get video renditions", "asset_id", assetID, "error", err)
+		return
+	}
+
+	for _, rendition := range renditions {
+		if rendition.Status != models.RenditionStatusPending && rendition.Status != models.RenditionStatusProcessing {
+			continue
+		}
+		rendition.Status = models.RenditionStatusFailed
+		rendition.ErrorMessage = cause.Error()
+		if err := v.mr.Update(ctx, rendition); err != nil {
+			slog.Error("unable to save failed video rendition", "asset_id", assetID, "platform", rendition.Platform, "error", err)
+		}
+	}
+}
+
+func (v *videoRenditions) Retry(ctx context.Context, assetID int64) error {
+	renditions, err := v.mr.ListByAssetID(ctx, assetID)
+	if err != nil {
+		return fmt.Errorf("error getting video renditions: %w", err)
+	}
+
+	var failed int
+	for _, rendition := range renditions {
+		if rendition.Status != models.RenditionStatusFailed {
+			continue
+		}
+		rendition.Status = models.RenditionStatusPending
+		rendition.Progress = 0
+		rendition.ErrorMessage = ""
+		if err := v.mr.Update(ctx, rendition); err != nil {
+			return fmt.Errorf("error saving video rendition: %w", err)
+		}
+		failed++
+	}
+	if failed == 0 {
+		return errors.New("video has no failed conversions to retry")
+	}
+
+	if err := v.mp.Transcode(assetID); err != nil {
+		return fmt.Errorf("error queueing video conversion: %w", err)
+	}
+	return nil
+}
+
+func (v *videoRenditions) List(ctx context.Context, assetID int64) ([]*models.MediaRendition, error) {
+	return v.mr.ListByAssetID(ctx, assetID)
+}
+
+// publishURL returns the URL to publish an asset to a platform from: its
+// image variant or video rendition when it needs one.
+func publishURL(ctx context.Context, iv ImageVariants, vr VideoRenditions, asset *models.MediaAsset, platform, fit string) (string, error) {
+	if strings.HasPrefix(asset.FileType, "video/") {
+		return vr.URL(ctx, asset, platform)
+	}
+	return iv.URL(ctx, asset, platform, fit)
+}
+
+// fitsVideoRules tells whether a video can be published as it is. Videos
+// that couldn't be probed are converted, in case they don't fit.
+func fitsVideoRules(rules PlatformRules, asset *models.MediaAsset) bool {
+	if !rules.transcodesVideos() {
+		return true
+	}
+
+	duration := time.Duration(asset.Duration) * time.Second
+	switch {
+	case !slices.Contains(rules.VideoCodecs, asset.Codec):
+		return false
+	case asset.Width == 0 || asset.Height == 0 || asset.FrameRate == 0:
+		return false
+	case rules.MaxVideoDimension > 0 && max(asset.Width, asset.Height) > rules.MaxVideoDimension:
+		return false
+	case rules.MinFrameRate > 0 && asset.FrameRate < rules.MinFrameRate:
+		return false
+	case rules.MaxFrameRate > 0 && asset.FrameRate > rules.MaxFrameRate+0.5:
+		return false
+	case rules.VideoDuration > 0 && (duration == 0 || duration > rules.VideoDuration):
+		return false
+	case rules.MaxVideoBitrate > 0 && asset.FileSize*8/int64(max(asset.Duration, 1)) > rules.MaxVideoBitrate:
+		return false
+	}
+	return true
+}
@@ -23,10 +23,14 @@ import (
 	"google.golang.org/api/youtube/v3"
 )
 
+// youtubeUploadURL is where Videos.Insert uploads to, with the parts the
+// video is inserted with.
+const youtubeUploadURL = "https://www.googleapis.com/upload/youtube/v3/videos?part=snippet,status"
+
 type YoutubeService interface {
 	YoutubeCallback(ctx context.Context, code string, userID int64) (err error)
 	RefreshYoutubeToken(ctx context.Context, userID int64, accessToken, refreshToken string) error
-	PostYoutubeVideo(ctx context.Context, post *models.Post, socialAcc *models.SocialAccount) error
+	PostYoutubeVideo(ctx context.Context, post *models.Post, socialAcc *models.SocialAccount) (*transfer.PublishResult, error)
 }
 
 type youtubeService struct {
@@ -161,11 +165,11 @@ func (s *youtubeService) RefreshYoutubeToken(ctx context.Context, userID int64,
 	return nil
 }
 
-func (s *youtubeService) PostYoutubeVideo(ctx context.Context, post *models.Post, socialAcc *models.SocialAccount) error {
+func (s *youtubeService) PostYoutubeVideo(ctx context.Context, post *models.Post, socialAcc *models.SocialAccount) (*transfer.PublishResult, error) {
 
 	decryptedAccessToken, err := utils.Decrypt(socialAcc.AccessToken, []byte(s.cfg.SecretKey))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	token := &oauth2.Token{
@@ -175,33 +179,43 @@ func (s *youtubeService) PostYoutubeVideo(ctx context.Context, post *models.Post
 	service, err := youtube.NewService(ctx, option.WithHTTPClient(client))
 	if err != nil {
 		log.Printf("Error creating YouTube service: %v", err)
-		return err
+		return nil, err
 	}
 
 	postMedia, err := s.pm.GetByPostID(ctx, post.ID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	videoInfo, err := s.ma.GetByID(ctx, postMedia.AssetID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	uploadVideoFromS3(service, post.Caption, post.Title, videoInfo.FileURL)
+	videoID, err := uploadVideoFromS3(service, post.Caption, post.Title, post.Options, videoInfo.FileURL)
+	if err != nil {
+		return nil, err
+	}
 
-	if err := s.p.UpdatePostStatus(ctx, models.PostStatusPosted, post.ID); err != nil {
-		return fmt.Errorf("failed to update status: %w", err)
+	// The video is public without its cover, a failure is only logged
+	if videoInfo.ThumbnailURL != "" {
+		if err := setYoutubeThumbnail(ctx, service, videoID, videoInfo.ThumbnailURL); err != nil {
+			log.Printf("Error setting thumbnail of video %s: %v", videoID, err)
+		}
 	}
 
-	return nil
+	return &transfer.PublishResult{
+		MediaID:   videoID,
+		Permalink: fmt.Sprintf("https://youtu.be/%s", videoID),
+	}, nil
 }
 
-func uploadVideoFromS3(service *youtube.Service, caption, title, s3URL string) error {
+func uploadVideoFromS3(service *youtube.Service, caption, title string, options models.PublishOptions, s3URL string) (string, error) {
 	// Step 1: Download video from S3
 	tempFile, err := downloadVideoFromS3(s3URL)
 	if err != nil {
-		log.Fatalf("Error downloading video from S3: %v", err)
+		log.Printf("Error downloading video from S3: %v", err)
+		return "", err
 	}
 	defer os.Remove(tempFile) // Ensure the temporary file is deleted after use
 
@@ -209,33 +223,68 @@ func uploadVideoFromS3(service *youtube.Service, caption, title, s3URL string) e
 	file, err := os.Open(tempFile)
 	if err != nil {
 		log.Printf("Error opening video file: %v", err)
-		return err
+		return "", err
 	}
 	defer file.Close()
 
 	// Step 3: Prepare video metadata
-	video := &youtube.Video{
-		Snippet: &youtube.VideoSnippet{
-			Description: caption,
-			Title:       title,
-			CategoryId:  "22",
-		},
-		Status: &youtube.VideoStatus{
-			PrivacyStatus: "public",
-		},
-	}
+	video := youtubeVideo(caption, title, options)
 
 	// Step 4: Upload the video to YouTube
 	call := service.Videos.Insert([]string{"snippet", "status"}, video)
 	response, err := call.Media(file).Do()
 	if err != nil {
 		log.Printf("Error uploading video: %v", err)
-		return err
+		return "", err
 	}
 
 	// Step 5: Log success
 	fmt.Printf("Video uploaded successfully: https://youtu.be/%s\n", response.Id)
-	return nil
+	return response.Id, nil
+}
+
+// setYoutubeThumbnail sets the cover of a video to an image. Custom
+// thumbnails need a verified channel.
+func setYoutubeThumbnail(ctx context.Context, service *youtube.Service, videoID, thumbnailURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, thumbnailURL, nil)
+	if err != nil {
+		return err
+	}
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error downloading thumbnail: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response status: %d", response.StatusCode)
+	}
+
+	_, err = service.Thumbnails.Set(videoID).Media(response.Body).Context(ctx).Do()
+	return err
+}
+
+// youtubeVideo is the metadata a video is uploaded with.
+func youtubeVideo(caption, title string, options models.PublishOptions) *youtube.Video {
+	categoryID := "22"
+	if options.CategoryID != "" {
+		categoryID = options.CategoryID
+	}
+	privacyStatus := "public"
+	if options.PrivacyLevel != "" {
+		privacyStatus = options.PrivacyLevel
+	}
+
+	return &youtube.Video{
+		Snippet: &youtube.VideoSnippet{
+			Description: caption,
+			Title:       title,
+			CategoryId:  categoryID,
+		},
+		Status: &youtube.VideoStatus{
+			PrivacyStatus: privacyStatus,
+		},
+	}
 }
 
 func downloadVideoFromS3(s3URL string) (string, error) {
@@ -1,8 +1,127 @@
 package transfer
 
+import (
+	"time"
+
+	"github.com/maheshrc27/scheduling-api/internal/models"
+)
+
 type PostCreation struct {
 	Caption          string `json:"caption"`
 	Title            string `json:"title"`
 	ScheduledTime    string `json:"scheduled_time"`
 	SelectedAccounts string `json:"selected_account"`
+	Draft            bool   `json:"draft"`
+	Recurrence       string `json:"recurrence"`
+	Queue            bool   `json:"queue"`
+	Category         string `json:"category"`
+	Timezone         string `json:"timezone"`
+	Overrides        string `json:"overrides"` // JSON list of PostOverride
+}
+
+// PostCreationJSON is the JSON variant of PostCreation. Its media are
+// existing assets or remote files instead of uploads.
+type PostCreationJSON struct {
+	Caption          string           `json:"caption"`
+	Title            string           `json:"title"`
+	ScheduledTime    string           `json:"scheduled_time"`
+	SelectedAccounts []int            `json:"selected_accounts"`
+	Draft            bool             `json:"draft"`
+	Recurrence       string           `json:"recurrence"`
+	Queue            bool             `json:"queue"`
+	Category         string           `json:"category"`
+	Timezone         string           `json:"timezone"`
+	Overrides        []PostOverride   `json:"overrides"`
+	Media            []PostMediaInput `json:"media"`
+}
+
+// PostMediaInput is a media of a post created from JSON, either an asset
+// or a URL to fetch the file from.
+type PostMediaInput struct {
+	AssetID      int64  `json:"asset_id"`
+	URL          string `json:"url"`
+	DisplayOrder int    `json:"display_order"`
+}
+
+type PostUpdate struct {
+	Caption          *string        `json:"caption"`
+	Title            *string        `json:"title"`
+	ScheduledTime    *string        `json:"scheduled_time"`
+	SelectedAccounts []int          `json:"selected_accounts"`
+	MediaOrder       []int64        `json:"media_order"`
+	Timezone         *string        `json:"timezone"`
+	Overrides        []PostOverride `json:"overrides"`
+}
+
+// PostOverride is an override of the post for one account or for every
+// account of a platform, exactly one of the two is set.
+type PostOverride struct {
+	AccountID int64  `json:"account_id"`
+	Platform  string `json:"platform"`
+	models.Override
+}
+
+// PublishResult identifies the media created on a platform by a publish.
+type PublishResult struct {
+	MediaID   string `json:"media_id"`
+	Permalink string `json:"permalink"`
+}
+
+type PostRetry struct {
+	AccountIDs []int64 `json:"account_ids"`
+}
+
+type HistoryQuery struct {
+	PostID    int64
+	AccountID int64
+	Platform  string
+	Status    string
+	From      string
+	To        string
+	Page      int
+	PerPage   int
+}
+
+type MediaQuery struct {
+	Type    string
+	MinSize int64
+	MaxSize int64
+	From    string
+	To      string
+	Page    int
+	PerPage int
+}
+
+// UploadRequest describes a file the client wants to upload straight to
+// storage.
+type UploadRequest struct {
+	FileName string `json:"file_name"`
+	FileType string `json:"file_type"`
+	FileSize int64  `json:"file_size"`
+}
+
+// PresignedUpload is where and how to upload a file. The request must be
+// sent with the given method and headers.
+type PresignedUpload struct {
+	UploadID  int64             `json:"upload_id"`
+	URL       string            `json:"url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// CoverRequest picks the cover frame of a video.
+type CoverRequest struct {
+	TimeMs int `json:"time_ms"`
+}
+
+type SlotCreation struct {
+	AccountID   int64  `json:"account_id"`
+	Weekday     int    `json:"weekday"`
+	PostingTime string `json:"posting_time"`
+	Category    string `json:"category"`
+}
+
+type TimezoneUpdate struct {
+	Timezone string `json:"timezone"`
 }
@@ -0,0 +1,246 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png" // decoder of the allowed image types, with image/jpeg
+	"io"
+	"math"
+)
+
+// ImageSize reads the size of a JPEG or PNG image as displayed, with the
+// EXIF orientation of JPEGs applied. Only the headers are read.
+func ImageSize(r io.ReaderAt, size int64) (int, int, error) {
+	config, format, err := image.DecodeConfig(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	width, height := config.Width, config.Height
+	// Orientations 5 to 8 are rotated by a quarter turn
+	if format == "jpeg" && jpegOrientation(bufio.NewReader(io.NewSectionReader(r, 0, size))) >= 5 {
+		width, height = height, width
+	}
+	return width, height, nil
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG, 1 when it has
+// none.
+func jpegOrientation(r io.Reader) int {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header[:2]); err != nil || header[0] != 0xFF || header[1] != 0xD8 {
+		return 1
+	}
+
+	// The EXIF data is in an APP1 segment before the image data
+	for {
+		if _, err := io.ReadFull(r, header); err != nil || header[0] != 0xFF {
+			return 1
+		}
+		marker := header[1]
+		length := int(binary.BigEndian.Uint16(header[2:4])) - 2
+		if marker == 0xDA || length < 0 {
+			return 1
+		}
+
+		segment := make([]byte, length)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return 1
+		}
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+	}
+}
+
+// exifOrientation reads the orientation tag of the first IFD of TIFF data.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 1
+}
+
+// DecodeImage decodes a JPEG or PNG image, turned upright according to its
+// EXIF orientation.
+func DecodeImage(r io.ReaderAt, size int64) (image.Image, error) {
+	img, format, err := image.Decode(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil, err
+	}
+	if format != "jpeg" {
+		return img, nil
+	}
+	return orient(img, jpegOrientation(bufio.NewReader(io.NewSectionReader(r, 0, size)))), nil
+}
+
+// orient applies an EXIF orientation to an image.
+func orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	src := img.Bounds()
+	w, h := src.Dx(), src.Dy()
+	if orientation >= 5 {
+		w, h = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			// The pixel of the stored image shown at x, y
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored
+				sx, sy = w-1-x, y
+			case 3: // half turn
+				sx, sy = w-1-x, h-1-y
+			case 4: // mirrored upside down
+				sx, sy = x, h-1-y
+			case 5: // mirrored along the top-left diagonal
+				sx, sy = y, x
+			case 6: // quarter turn clockwise
+				sx, sy = y, w-1-x
+			case 7: // mirrored along the top-right diagonal
+				sx, sy = h-1-y, w-1-x
+			case 8: // quarter turn counterclockwise
+				sx, sy = h-1-y, x
+			}
+			dst.Set(x, y, img.At(src.Min.X+sx, src.Min.Y+sy))
+		}
+	}
+	return dst
+}
+
+// FitSize scales a size down to fit in a square of maxSize, keeping its
+// aspect ratio. Sizes that fit are kept.
+func FitSize(width, height, maxSize int) (int, int) {
+	if width <= maxSize && height <= maxSize {
+		return width, height
+	}
+	if width >= height {
+		return maxSize, max(1, height*maxSize/width)
+	}
+	return max(1, width*maxSize/height), maxSize
+}
+
+// Resize scales an image to width by height. Pixels are averaged when
+// scaling down, which keeps thumbnails smooth.
+func Resize(img image.Image, width, height int) *image.RGBA {
+	src := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(src, src.Bounds(), img, img.Bounds().Min, draw.Src)
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := y * sh / height
+		y1 := max(y0+1, (y+1)*sh/height)
+		for x := 0; x < width; x++ {
+			x0 := x * sw / width
+			x1 := max(x0+1, (x+1)*sw/width)
+
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += int(p[0])
+					g += int(p[1])
+					b += int(p[2])
+					a += int(p[3])
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// EncodeJPEG encodes an image as a JPEG, transparent areas turn white.
+func EncodeJPEG(img image.Image, quality int) ([]byte, error) {
+	opaque := image.NewRGBA(img.Bounds())
+	draw.Draw(opaque, opaque.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(opaque, opaque.Bounds(), img, img.Bounds().Min, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, opaque, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// FitAspectRatio brings the aspect ratio of an image, its width over its
+// height, between minRatio and maxRatio. The image is cropped around its
+// center, or padded with white when pad is set. Zero bounds are ignored.
+func FitAspectRatio(img image.Image, minRatio, maxRatio float64, pad bool) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	ratio := float64(w) / float64(h)
+
+	var target float64
+	switch {
+	case minRatio > 0 && ratio < minRatio:
+		target = minRatio
+	case maxRatio > 0 && ratio > maxRatio:
+		target = maxRatio
+	default:
+		return img
+	}
+
+	// Too tall images lose height or gain width, too wide ones the opposite
+	nw, nh := w, h
+	switch {
+	case ratio < target && pad:
+		nw = int(math.Round(float64(h) * target))
+	case ratio < target:
+		nh = int(math.Round(float64(w) / target))
+	case pad:
+		nh = int(math.Round(float64(w) / target))
+	default:
+		nw = int(math.Round(float64(h) * target))
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, nw, nh))
+	if pad {
+		draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+		offset := image.Pt((nw-w)/2, (nh-h)/2)
+		draw.Draw(dst, bounds.Sub(bounds.Min).Add(offset), img, bounds.Min, draw.Over)
+	} else {
+		offset := image.Pt((w-nw)/2, (h-nh)/2)
+		draw.Draw(dst, dst.Bounds(), img, bounds.Min.Add(offset), draw.Src)
+	}
+	return dst
+}
@@ -0,0 +1,264 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// byteOrder is binary.LittleEndian or binary.BigEndian.
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// exifTIFF builds TIFF data whose first IFD holds an orientation tag, after
+// another tag.
+func exifTIFF(order byteOrder, orientation uint16) []byte {
+	b := []byte("II")
+	if order == binary.BigEndian {
+		b = []byte("MM")
+	}
+	b = order.AppendUint16(b, 42)
+	b = order.AppendUint32(b, 8)
+
+	b = order.AppendUint16(b, 2)
+	// ImageWidth, LONG
+	b = order.AppendUint16(b, 0x0100)
+	b = order.AppendUint16(b, 4)
+	b = order.AppendUint32(b, 1)
+	b = order.AppendUint32(b, 640)
+	// Orientation, SHORT
+	b = order.AppendUint16(b, 0x0112)
+	b = order.AppendUint16(b, 3)
+	b = order.AppendUint32(b, 1)
+	b = order.AppendUint16(b, orientation)
+	b = order.AppendUint16(b, 0)
+
+	return order.AppendUint32(b, 0)
+}
+
+// segment builds a JPEG marker segment.
+func segment(marker byte, payload []byte) []byte {
+	b := []byte{0xFF, marker}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(payload)+2))
+	return append(b, payload...)
+}
+
+// withExif inserts an APP1 segment with the TIFF data after the start of a
+// JPEG.
+func withExif(jpg, tiff []byte) []byte {
+	app1 := segment(0xE1, append([]byte("Exif\x00\x00"), tiff...))
+	return bytes.Join([][]byte{jpg[:2], app1, jpg[2:]}, nil)
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	start := []byte{0xFF, 0xD8}
+	app0 := segment(0xE0, []byte("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00"))
+	scan := segment(0xDA, make([]byte, 10))
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{name: "not a jpeg", data: []byte("\x89PNG\r\n\x1a\n"), want: 1},
+		{name: "empty", data: nil, want: 1},
+		{name: "no exif", data: bytes.Join([][]byte{start, app0, scan}, nil), want: 1},
+		{name: "exif after app0", data: bytes.Join([][]byte{start, app0, segment(0xE1, append([]byte("Exif\x00\x00"), exifTIFF(binary.BigEndian, 6)...)), scan}, nil), want: 6},
+		{name: "app1 without exif", data: bytes.Join([][]byte{start, segment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00")), scan}, nil), want: 1},
+		{name: "exif after the scan", data: bytes.Join([][]byte{start, scan, segment(0xE1, append([]byte("Exif\x00\x00"), exifTIFF(binary.BigEndian, 6)...))}, nil), want: 1},
+		{name: "truncated segment", data: bytes.Join([][]byte{start, segment(0xE1, append([]byte("Exif\x00\x00"), exifTIFF(binary.BigEndian, 6)...))[:20]}, nil), want: 1},
+		{name: "invalid segment length", data: bytes.Join([][]byte{start, {0xFF, 0xE1, 0, 1}}, nil), want: 1},
+	}
+	for _, order := range []byteOrder{binary.LittleEndian, binary.BigEndian} {
+		for orientation := 1; orientation <= 8; orientation++ {
+			tests = append(tests, struct {
+				name string
+				data []byte
+				want int
+			}{
+				name: fmt.Sprintf("%s %d", order, orientation),
+				data: bytes.Join([][]byte{start, segment(0xE1, append([]byte("Exif\x00\x00"), exifTIFF(order, uint16(orientation))...)), scan}, nil),
+				want: orientation,
+			})
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(bufio.NewReader(bytes.NewReader(tt.data))); got != tt.want {
+				t.Errorf("jpegOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExifOrientation(t *testing.T) {
+	valid := exifTIFF(binary.LittleEndian, 8)
+
+	noOrientation := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	noOrientation = binary.BigEndian.AppendUint16(noOrientation, 1)
+	noOrientation = append(noOrientation, 0x01, 0x00, 0, 4, 0, 0, 0, 1, 0, 0, 2, 0x80)
+
+	badOffset := append([]byte{}, valid...)
+	binary.LittleEndian.PutUint32(badOffset[4:], 1000)
+
+	tests := []struct {
+		name string
+		tiff []byte
+		want int
+	}{
+		{name: "little endian", tiff: valid, want: 8},
+		{name: "big endian", tiff: exifTIFF(binary.BigEndian, 3), want: 3},
+		{name: "too short", tiff: valid[:6], want: 1},
+		{name: "unknown byte order", tiff: append([]byte("XX"), valid[2:]...), want: 1},
+		{name: "IFD past the end", tiff: badOffset, want: 1},
+		{name: "truncated entries", tiff: valid[:20], want: 1},
+		{name: "no orientation tag", tiff: noOrientation, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exifOrientation(tt.tiff); got != tt.want {
+				t.Errorf("exifOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// markedImage returns a 3x2 image, black but for a red top-left pixel and a
+// green top-right one.
+func markedImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xFF
+	}
+	img.Set(0, 0, color.RGBA{R: 0xFF, A: 0xFF})
+	img.Set(2, 0, color.RGBA{G: 0xFF, A: 0xFF})
+	return img
+}
+
+func TestOrient(t *testing.T) {
+	tests := []struct {
+		orientation   int
+		width, height int
+		red, green    image.Point // where the top corners end up
+	}{
+		{orientation: 0, width: 3, height: 2, red: image.Pt(0, 0), green: image.Pt(2, 0)},
+		{orientation: 1, width: 3, height: 2, red: image.Pt(0, 0), green: image.Pt(2, 0)},
+		{orientation: 2, width: 3, height: 2, red: image.Pt(2, 0), green: image.Pt(0, 0)},
+		{orientation: 3, width: 3, height: 2, red: image.Pt(2, 1), green: image.Pt(0, 1)},
+		{orientation: 4, width: 3, height: 2, red: image.Pt(0, 1), green: image.Pt(2, 1)},
+		{orientation: 5, width: 2, height: 3, red: image.Pt(0, 0), green: image.Pt(0, 2)},
+		{orientation: 6, width: 2, height: 3, red: image.Pt(1, 0), green: image.Pt(1, 2)},
+		{orientation: 7, width: 2, height: 3, red: image.Pt(1, 2), green: image.Pt(1, 0)},
+		{orientation: 8, width: 2, height: 3, red: image.Pt(0, 2), green: image.Pt(0, 0)},
+		{orientation: 9, width: 3, height: 2, red: image.Pt(0, 0), green: image.Pt(2, 0)},
+	}
+
+	for _, tt := range tests {
+		img := orient(markedImage(), tt.orientation)
+		if size := img.Bounds().Size(); size != image.Pt(tt.width, tt.height) {
+			t.Errorf("orient(%d) size = %v, want %dx%d", tt.orientation, size, tt.width, tt.height)
+			continue
+		}
+		if r, _, _, _ := img.At(tt.red.X, tt.red.Y).RGBA(); r != 0xFFFF {
+			t.Errorf("orient(%d) top-left pixel isn't at %v", tt.orientation, tt.red)
+		}
+		if _, g, _, _ := img.At(tt.green.X, tt.green.Y).RGBA(); g != 0xFFFF {
+			t.Errorf("orient(%d) top-right pixel isn't at %v", tt.orientation, tt.green)
+		}
+	}
+}
+
+func TestDecodeImageOrientation(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 32, 16)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		orientation   uint16
+		width, height int
+	}{
+		{orientation: 1, width: 32, height: 16},
+		{orientation: 3, width: 32, height: 16},
+		{orientation: 6, width: 16, height: 32},
+		{orientation: 8, width: 16, height: 32},
+	}
+
+	for _, tt := range tests {
+		data := withExif(buf.Bytes(), exifTIFF(binary.BigEndian, tt.orientation))
+
+		width, height, err := ImageSize(bytes.NewReader(data), int64(len(data)))
+		if err != nil || width != tt.width || height != tt.height {
+			t.Errorf("ImageSize() with orientation %d = %dx%d, %v, want %dx%d", tt.orientation, width, height, err, tt.width, tt.height)
+		}
+
+		img, err := DecodeImage(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("DecodeImage() error = %v", err)
+		}
+		if size := img.Bounds().Size(); size != image.Pt(tt.width, tt.height) {
+			t.Errorf("DecodeImage() with orientation %d size = %v, want %dx%d", tt.orientation, size, tt.width, tt.height)
+		}
+	}
+}
+
+// gradient returns an image whose pixels are as bright as their row.
+func gradient(width, height int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetGray(x, y, color.Gray{Y: uint8(y)})
+		}
+	}
+	return img
+}
+
+func TestFitAspectRatio(t *testing.T) {
+	tests := []struct {
+		name               string
+		width, height      int
+		minRatio, maxRatio float64
+		pad                bool
+		wantW, wantH       int
+		origin             image.Point // where the image starts in the result
+		firstRow           int         // row of the image at the top of a crop
+	}{
+		{name: "fits", width: 100, height: 100, minRatio: 0.8, maxRatio: 1.91, wantW: 100, wantH: 100},
+		{name: "no bounds", width: 100, height: 10, wantW: 100, wantH: 10},
+		{name: "at the minimum", width: 80, height: 100, minRatio: 0.8, maxRatio: 1.91, wantW: 80, wantH: 100},
+		{name: "crop tall", width: 100, height: 200, minRatio: 0.8, maxRatio: 1.91, wantW: 100, wantH: 125, firstRow: 37},
+		{name: "pad tall", width: 100, height: 200, minRatio: 0.8, maxRatio: 1.91, pad: true, wantW: 160, wantH: 200, origin: image.Pt(30, 0)},
+		{name: "crop wide", width: 200, height: 50, minRatio: 0.8, maxRatio: 1.91, wantW: 96, wantH: 50},
+		{name: "pad wide", width: 200, height: 50, minRatio: 0.8, maxRatio: 1.91, pad: true, wantW: 200, wantH: 105, origin: image.Pt(0, 27)},
+		{name: "only a maximum", width: 200, height: 50, maxRatio: 1, wantW: 50, wantH: 50},
+		{name: "only a minimum", width: 50, height: 200, minRatio: 1, pad: true, wantW: 200, wantH: 200, origin: image.Pt(75, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := FitAspectRatio(gradient(tt.width, tt.height), tt.minRatio, tt.maxRatio, tt.pad)
+			if size := img.Bounds().Size(); size != image.Pt(tt.wantW, tt.wantH) {
+				t.Fatalf("FitAspectRatio() size = %v, want %dx%d", size, tt.wantW, tt.wantH)
+			}
+
+			r, _, _, _ := img.At(tt.origin.X, tt.origin.Y).RGBA()
+			if got := int(r >> 8); got != tt.firstRow {
+				t.Errorf("FitAspectRatio() pixel at %v = %d, want row %d", tt.origin, got, tt.firstRow)
+			}
+			if tt.pad && tt.origin != (image.Point{}) {
+				if r, g, b, _ := img.At(0, 0).RGBA(); r != 0xFFFF || g != 0xFFFF || b != 0xFFFF {
+					t.Errorf("FitAspectRatio() padding isn't white")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// maxMovieBoxSize bounds how much of a file is read to find the movie
+// header, the moov box of a regular video is a few megabytes at most.
+const maxMovieBoxSize = 64 << 20
+
+var errNoMovieHeader = errors.New("mp4: movie header not found")
+
+// VideoInfo describes an MP4 or QuickTime file.
+type VideoInfo struct {
+	Duration  time.Duration
+	Width     int // as displayed, rotation applied
+	Height    int
+	FrameRate float64
+	Codec     string // codec of the video track, e.g. h264
+}
+
+// codecNames maps the sample entry types of video tracks to codec names.
+var codecNames = map[string]string{
+	"avc1": "h264", "avc3": "h264",
+	"hvc1": "hevc", "hev1": "hevc",
+	"vp09": "vp9",
+	"av01": "av1",
+	"mp4v": "mpeg4",
+	"apch": "prores", "apcn": "prores", "apcs": "prores", "apco": "prores", "ap4h": "prores",
+}
+
+// MP4Info reads the duration of an MP4 or QuickTime file from its movie
+// header (moov/mvhd), and the size, frame rate and codec of its first video
+// track. Only the box headers and the moov box are read.
+func MP4Info(r io.ReaderAt, size int64) (*VideoInfo, error) {
+	moov, err := readMovieBox(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	duration, err := movieDuration(moov)
+	if err != nil {
+		return nil, err
+	}
+	info := &VideoInfo{Duration: duration}
+
+	for data := moov; ; {
+		trak, rest, ok := nextBox(data, "trak")
+		if !ok {
+			break
+		}
+		data = rest
+		if videoTrack(trak, info) {
+			break
+		}
+	}
+	return info, nil
+}
+
+// readMovieBox returns the payload of the moov box of a file.
+func readMovieBox(r io.ReaderAt, size int64) ([]byte, error) {
+	var offset int64
+	header := make([]byte, 16)
+	for offset+8 <= size {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return nil, err
+		}
+
+		boxSize := int64(binary.BigEndian.Uint32(header[0:4]))
+		headerSize := int64(8)
+		switch boxSize {
+		case 0:
+			boxSize = size - offset
+		case 1:
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return nil, err
+			}
+			boxSize = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if boxSize < headerSize || offset+boxSize > size {
+			return nil, errNoMovieHeader
+		}
+
+		if string(header[4:8]) == "moov" {
+			if boxSize-headerSize > maxMovieBoxSize {
+				return nil, errors.New("mp4: movie box is too large")
+			}
+			moov := make([]byte, boxSize-headerSize)
+			if _, err := r.ReadAt(moov, offset+headerSize); err != nil {
+				return nil, err
+			}
+			return moov, nil
+		}
+		offset += boxSize
+	}
+	return nil, errNoMovieHeader
+}
+
+func movieDuration(moov []byte) (time.Duration, error) {
+	mvhd, ok := findBox(moov, "mvhd")
+	if !ok {
+		return 0, errNoMovieHeader
+	}
+
+	timescale, duration, ok := timing(mvhd)
+	if !ok {
+		return 0, errNoMovieHeader
+	}
+	if timescale == 0 {
+		return 0, errors.New("mp4: movie header has no timescale")
+	}
+	return time.Duration(float64(duration) / float64(timescale) * float64(time.Second)), nil
+}
+
+// timing reads the timescale and duration of a movie (mvhd) or media (mdhd)
+// header, which share their layout up to the duration.
+func timing(header []byte) (uint32, uint64, bool) {
+	if len(header) < 4 {
+		return 0, 0, false
+	}
+	switch header[0] {
+	case 0:
+		if len(header) < 20 {
+			return 0, 0, false
+		}
+		return binary.BigEndian.Uint32(header[12:16]), uint64(binary.BigEndian.Uint32(header[16:20])), true
+	case 1:
+		if len(header) < 32 {
+			return 0, 0, false
+		}
+		return binary.BigEndian.Uint32(header[20:24]), binary.BigEndian.Uint64(header[24:32]), true
+	default:
+		return 0, 0, false
+	}
+}
+
+// videoTrack fills info from a track when it is a video track, and reports
+// whether it was.
+func videoTrack(trak []byte, info *VideoInfo) bool {
+	mdia, ok := findBox(trak, "mdia")
+	if !ok {
+		return false
+	}
+	// The handler type is "vide" for video tracks, after the version, flags
+	// and a reserved field (the component type in QuickTime)
+	hdlr, ok := findBox(mdia, "hdlr")
+	if !ok || len(hdlr) < 12 || string(hdlr[8:12]) != "vide" {
+		return false
+	}
+
+	if tkhd, ok := findBox(trak, "tkhd"); ok {
+		info.Width, info.Height = trackSize(tkhd)
+	}
+
+	stbl, ok := findPath(mdia, "minf", "stbl")
+	if !ok {
+		return true
+	}
+
+	// The first sample entry of stsd is named after the codec
+	if stsd, ok := findBox(stbl, "stsd"); ok && len(stsd) >= 16 {
+		fourcc := string(stsd[12:16])
+		if name, ok := codecNames[fourcc]; ok {
+			info.Codec = name
+		} else {
+			info.Codec = fourcc
+		}
+	}
+
+	// The frame rate is the number of samples over the media duration
+	mdhd, ok := findBox(mdia, "mdhd")
+	if !ok {
+		return true
+	}
+	timescale, duration, ok := timing(mdhd)
+	stts, found := findBox(stbl, "stts")
+	if !ok || !found || timescale == 0 || duration == 0 || len(stts) < 8 {
+		return true
+	}
+
+	var samples uint64
+	entries := stts[8:]
+	for n := binary.BigEndian.Uint32(stts[4:8]); n > 0 && len(entries) >= 8; n-- {
+		samples += uint64(binary.BigEndian.Uint32(entries[0:4]))
+		entries = entries[8:]
+	}
+	info.FrameRate = float64(samples) * float64(timescale) / float64(duration)
+	return true
+}
+
+// trackSize reads the size of a track from its header. The size is stored
+// before the transformation matrix is applied, a rotation of a quarter turn
+// swaps it.
+func trackSize(tkhd []byte) (int, int) {
+	matrix := 40 // after version, flags, times, IDs, layer and volume
+	if len(tkhd) > 0 && tkhd[0] == 1 {
+		matrix = 52
+	}
+	if len(tkhd) < matrix+44 {
+		return 0, 0
+	}
+
+	width := int(binary.BigEndian.Uint32(tkhd[matrix+36:]) >> 16)
+	height := int(binary.BigEndian.Uint32(tkhd[matrix+40:]) >> 16)
+
+	a := int32(binary.BigEndian.Uint32(tkhd[matrix:]))
+	b := int32(binary.BigEndian.Uint32(tkhd[matrix+4:]))
+	if a == 0 && (b == 0x10000 || b == -0x10000) {
+		width, height = height, width
+	}
+	return width, height
+}
+
+// findPath returns the payload of the box at the path of nested box types.
+func findPath(data []byte, path ...string) ([]byte, bool) {
+	for _, boxType := range path {
+		var ok bool
+		if data, ok = findBox(data, boxType); !ok {
+			return nil, false
+		}
+	}
+	return data, true
+}
+
+// findBox returns the payload of the first box of the given type among the
+// boxes in data.
+func findBox(data []byte, boxType string) ([]byte, bool) {
+	payload, _, ok := nextBox(data, boxType)
+	return payload, ok
+}
+
+// nextBox returns the payload of the first box of the given type among the
+// boxes in data, along with the boxes after it.
+func nextBox(data []byte, boxType string) ([]byte, []byte, bool) {
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data[0:4]))
+		header := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return nil, nil, false
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			header = 16
+		}
+		if size < header || size > uint64(len(data)) {
+			return nil, nil, false
+		}
+
+		if string(data[4:8]) == boxType {
+			return data[header:size], data[size:], true
+		}
+		data = data[size:]
+	}
+	return nil, nil, false
+}
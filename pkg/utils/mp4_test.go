@@ -0,0 +1,238 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// box builds an MP4 box with a 32-bit size.
+func box(boxType string, payload ...[]byte) []byte {
+	data := bytes.Join(payload, nil)
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(data)))
+	b = append(b, boxType...)
+	return append(b, data...)
+}
+
+// largeBox builds an MP4 box with a 64-bit size.
+func largeBox(boxType string, payload ...[]byte) []byte {
+	data := bytes.Join(payload, nil)
+	b := binary.BigEndian.AppendUint32(nil, 1)
+	b = append(b, boxType...)
+	b = binary.BigEndian.AppendUint64(b, uint64(16+len(data)))
+	return append(b, data...)
+}
+
+// timingHeader builds the payload of an mvhd or mdhd box.
+func timingHeader(version byte, timescale uint32, duration uint64) []byte {
+	b := []byte{version, 0, 0, 0}
+	if version == 1 {
+		b = append(b, make([]byte, 16)...)
+		b = binary.BigEndian.AppendUint32(b, timescale)
+		b = binary.BigEndian.AppendUint64(b, duration)
+	} else {
+		b = append(b, make([]byte, 8)...)
+		b = binary.BigEndian.AppendUint32(b, timescale)
+		b = binary.BigEndian.AppendUint32(b, uint32(duration))
+	}
+	return append(b, make([]byte, 80)...)
+}
+
+var (
+	identityMatrix = [9]int32{0x10000, 0, 0, 0, 0x10000, 0, 0, 0, 0x40000000}
+	rotate90Matrix = [9]int32{0, 0x10000, 0, -0x10000, 0, 0, 0, 0, 0x40000000}
+)
+
+// trackHeader builds the payload of a tkhd box.
+func trackHeader(version byte, width, height int, matrix [9]int32) []byte {
+	b := []byte{version, 0, 0, 0}
+	if version == 1 {
+		b = append(b, make([]byte, 48)...)
+	} else {
+		b = append(b, make([]byte, 36)...)
+	}
+	for _, v := range matrix {
+		b = binary.BigEndian.AppendUint32(b, uint32(v))
+	}
+	b = binary.BigEndian.AppendUint32(b, uint32(width)<<16)
+	return binary.BigEndian.AppendUint32(b, uint32(height)<<16)
+}
+
+// track builds a trak box of the handler type, with samples of the codec
+// over seconds.
+func track(handler, codec string, tkhd []byte, samples uint32, seconds uint32) []byte {
+	hdlr := append([]byte{0, 0, 0, 0, 0, 0, 0, 0}, handler...)
+	hdlr = append(hdlr, make([]byte, 13)...)
+
+	stsd := []byte{0, 0, 0, 0, 0, 0, 0, 1}
+	stsd = append(stsd, box(codec, make([]byte, 78))...)
+
+	stts := []byte{0, 0, 0, 0, 0, 0, 0, 1}
+	stts = binary.BigEndian.AppendUint32(stts, samples)
+	stts = binary.BigEndian.AppendUint32(stts, 1000)
+
+	return box("trak",
+		box("tkhd", tkhd),
+		box("mdia",
+			box("mdhd", timingHeader(0, 30000, uint64(seconds)*30000)),
+			box("hdlr", hdlr),
+			box("minf", box("stbl", box("stsd", stsd), box("stts", stts))),
+		),
+	)
+}
+
+func videoTrackBox(codec string, tkhd []byte) []byte {
+	return track("vide", codec, tkhd, 300, 10)
+}
+
+func TestMP4Info(t *testing.T) {
+	ftyp := box("ftyp", []byte("isom\x00\x00\x02\x00isomiso2avc1mp41"))
+	mdat := box("mdat", make([]byte, 64))
+	video := videoTrackBox("avc1", trackHeader(0, 1920, 1080, identityMatrix))
+	moov := box("moov", box("mvhd", timingHeader(0, 1000, 10500)), video)
+
+	tests := []struct {
+		name string
+		file []byte
+		want VideoInfo
+	}{
+		{
+			name: "moov after mdat",
+			file: bytes.Join([][]byte{ftyp, mdat, moov}, nil),
+			want: VideoInfo{Duration: 10500 * time.Millisecond, Width: 1920, Height: 1080, FrameRate: 30, Codec: "h264"},
+		},
+		{
+			name: "moov before mdat",
+			file: bytes.Join([][]byte{ftyp, moov, mdat}, nil),
+			want: VideoInfo{Duration: 10500 * time.Millisecond, Width: 1920, Height: 1080, FrameRate: 30, Codec: "h264"},
+		},
+		{
+			name: "64-bit sizes",
+			file: bytes.Join([][]byte{
+				ftyp,
+				largeBox("mdat", make([]byte, 64)),
+				largeBox("moov",
+					box("mvhd", timingHeader(1, 600, 6000)),
+					videoTrackBox("hvc1", trackHeader(1, 3840, 2160, identityMatrix)),
+				),
+			}, nil),
+			want: VideoInfo{Duration: 10 * time.Second, Width: 3840, Height: 2160, FrameRate: 30, Codec: "hevc"},
+		},
+		{
+			name: "moov up to the end of the file",
+			file: bytes.Join([][]byte{ftyp, mdat, {0, 0, 0, 0}, []byte("moov"), moov[8:]}, nil),
+			want: VideoInfo{Duration: 10500 * time.Millisecond, Width: 1920, Height: 1080, FrameRate: 30, Codec: "h264"},
+		},
+		{
+			name: "rotated track",
+			file: bytes.Join([][]byte{ftyp, mdat, box("moov",
+				box("mvhd", timingHeader(0, 1000, 10000)),
+				videoTrackBox("avc1", trackHeader(0, 1920, 1080, rotate90Matrix)),
+			)}, nil),
+			want: VideoInfo{Duration: 10 * time.Second, Width: 1080, Height: 1920, FrameRate: 30, Codec: "h264"},
+		},
+		{
+			name: "audio track first",
+			file: bytes.Join([][]byte{ftyp, mdat, box("moov",
+				box("mvhd", timingHeader(0, 1000, 10000)),
+				track("soun", "mp4a", trackHeader(0, 0, 0, identityMatrix), 430, 10),
+				videoTrackBox("apch", trackHeader(0, 1280, 720, identityMatrix)),
+			)}, nil),
+			want: VideoInfo{Duration: 10 * time.Second, Width: 1280, Height: 720, FrameRate: 30, Codec: "prores"},
+		},
+		{
+			name: "unknown codec",
+			file: bytes.Join([][]byte{ftyp, mdat, box("moov",
+				box("mvhd", timingHeader(0, 1000, 10000)),
+				videoTrackBox("xyz1", trackHeader(0, 640, 480, identityMatrix)),
+			)}, nil),
+			want: VideoInfo{Duration: 10 * time.Second, Width: 640, Height: 480, FrameRate: 30, Codec: "xyz1"},
+		},
+		{
+			name: "no video track",
+			file: bytes.Join([][]byte{ftyp, mdat, box("moov",
+				box("mvhd", timingHeader(0, 1000, 10000)),
+				track("soun", "mp4a", trackHeader(0, 0, 0, identityMatrix), 430, 10),
+			)}, nil),
+			want: VideoInfo{Duration: 10 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := MP4Info(bytes.NewReader(tt.file), int64(len(tt.file)))
+			if err != nil {
+				t.Fatalf("MP4Info() error = %v", err)
+			}
+			if *info != tt.want {
+				t.Errorf("MP4Info() = %+v, want %+v", *info, tt.want)
+			}
+		})
+	}
+}
+
+func TestMP4InfoInvalid(t *testing.T) {
+	ftyp := box("ftyp", []byte("isom\x00\x00\x02\x00"))
+	moov := box("moov", box("mvhd", timingHeader(0, 1000, 10000)))
+
+	tests := []struct {
+		name string
+		file []byte
+	}{
+		{name: "empty", file: nil},
+		{name: "no moov", file: bytes.Join([][]byte{ftyp, box("mdat", make([]byte, 64))}, nil)},
+		{name: "truncated box header", file: bytes.Join([][]byte{ftyp, {0, 0, 0}}, nil)},
+		{name: "truncated moov", file: bytes.Join([][]byte{ftyp, moov[:len(moov)-10]}, nil)},
+		{name: "box past the end", file: bytes.Join([][]byte{ftyp, {0, 0, 1, 0}, []byte("mdat"), moov}, nil)},
+		{name: "box smaller than its header", file: bytes.Join([][]byte{ftyp, {0, 0, 0, 4}, []byte("free"), moov}, nil)},
+		{name: "truncated 64-bit size", file: bytes.Join([][]byte{ftyp, {0, 0, 0, 1}, []byte("mdat"), {0, 0}}, nil)},
+		{name: "zero-size mdat hides moov", file: bytes.Join([][]byte{ftyp, {0, 0, 0, 0}, []byte("mdat"), moov}, nil)},
+		{name: "no mvhd", file: bytes.Join([][]byte{ftyp, box("moov", box("trak"))}, nil)},
+		{name: "truncated mvhd", file: bytes.Join([][]byte{ftyp, box("moov", box("mvhd", timingHeader(0, 1000, 10000)[:16]))}, nil)},
+		{name: "unknown mvhd version", file: bytes.Join([][]byte{ftyp, box("moov", box("mvhd", append([]byte{2}, timingHeader(0, 1000, 10000)[1:]...)))}, nil)},
+		{name: "no timescale", file: bytes.Join([][]byte{ftyp, box("moov", box("mvhd", timingHeader(0, 0, 10000)))}, nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if info, err := MP4Info(bytes.NewReader(tt.file), int64(len(tt.file))); err == nil {
+				t.Errorf("MP4Info() = %+v, want an error", *info)
+			}
+		})
+	}
+}
+
+func TestTrackSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		tkhd          []byte
+		width, height int
+	}{
+		{name: "identity", tkhd: trackHeader(0, 1920, 1080, identityMatrix), width: 1920, height: 1080},
+		{name: "version 1", tkhd: trackHeader(1, 1920, 1080, identityMatrix), width: 1920, height: 1080},
+		{name: "quarter turn", tkhd: trackHeader(0, 1920, 1080, rotate90Matrix), width: 1080, height: 1920},
+		{
+			name:  "three quarter turn",
+			tkhd:  trackHeader(0, 1920, 1080, [9]int32{0, -0x10000, 0, 0x10000, 0, 0, 0, 0, 0x40000000}),
+			width: 1080, height: 1920,
+		},
+		{
+			name:  "half turn",
+			tkhd:  trackHeader(0, 1920, 1080, [9]int32{-0x10000, 0, 0, 0, -0x10000, 0, 0, 0, 0x40000000}),
+			width: 1920, height: 1080,
+		},
+		{name: "version 1 quarter turn", tkhd: trackHeader(1, 1280, 720, rotate90Matrix), width: 720, height: 1280},
+		{name: "truncated", tkhd: trackHeader(0, 1920, 1080, identityMatrix)[:80]},
+		{name: "empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			width, height := trackSize(tt.tkhd)
+			if width != tt.width || height != tt.height {
+				t.Errorf("trackSize() = %dx%d, want %dx%d", width, height, tt.width, tt.height)
+			}
+		})
+	}
+}
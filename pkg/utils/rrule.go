@@ -0,0 +1,285 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+)
+
+// maxRRulePeriods bounds how many periods in a row are walked without an
+// occurrence, so a rule that never matches can't loop forever.
+const maxRRulePeriods = 10000
+
+var rruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// RRuleDay is a BYDAY entry. Nth is only used by monthly rules, e.g. 1MO is
+// the first Monday and -1FR the last Friday of the month.
+type RRuleDay struct {
+	Weekday time.Weekday
+	Nth     int
+}
+
+// RRule is the subset of RFC 5545 recurrence rules supported for posts:
+// FREQ (DAILY, WEEKLY, MONTHLY), INTERVAL, BYDAY, COUNT and UNTIL.
+type RRule struct {
+	Freq     string
+	Interval int
+	ByDay    []RRuleDay
+	Count    int
+	Until    time.Time
+	// UntilLocal tells that Until is a wall clock time in the zone of the
+	// start of the series, as for a date only UNTIL
+	UntilLocal bool
+}
+
+// ParseRRule parses a rule such as "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10". A
+// leading "RRULE:" is accepted.
+func ParseRRule(value string) (*RRule, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	if value == "" {
+		return nil, errors.New("recurrence rule is empty")
+	}
+
+	rule := &RRule{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return nil, fmt.Errorf("invalid recurrence rule part %q", part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(val)
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid recurrence interval %q", val)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid recurrence count %q", val)
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, local, err := parseRRuleUntil(val)
+			if err != nil {
+				return nil, err
+			}
+			rule.Until, rule.UntilLocal = until, local
+		case "BYDAY":
+			for _, day := range strings.Split(strings.ToUpper(val), ",") {
+				d, err := parseRRuleDay(day)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, d)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported recurrence rule part %q", key)
+		}
+	}
+
+	switch rule.Freq {
+	case FreqDaily, FreqWeekly, FreqMonthly:
+	case "":
+		return nil, errors.New("recurrence rule must have a FREQ")
+	default:
+		return nil, fmt.Errorf("unsupported recurrence frequency %q", rule.Freq)
+	}
+
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return nil, errors.New("recurrence rule can't have both COUNT and UNTIL")
+	}
+
+	for _, d := range rule.ByDay {
+		if d.Nth != 0 && rule.Freq != FreqMonthly {
+			return nil, fmt.Errorf("BYDAY with a position is only supported for monthly rules")
+		}
+	}
+
+	return rule, nil
+}
+
+// parseRRuleUntil reads an UNTIL and tells whether it is a wall clock time,
+// which only a UTC time isn't.
+func parseRRuleUntil(value string) (time.Time, bool, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			if layout == "20060102" {
+				// A date only UNTIL includes the whole day
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, layout != "20060102T150405Z", nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("invalid recurrence until %q", value)
+}
+
+func parseRRuleDay(value string) (RRuleDay, error) {
+	if len(value) < 2 {
+		return RRuleDay{}, fmt.Errorf("invalid recurrence day %q", value)
+	}
+
+	weekday, ok := rruleWeekdays[value[len(value)-2:]]
+	if !ok {
+		return RRuleDay{}, fmt.Errorf("invalid recurrence day %q", value)
+	}
+
+	day := RRuleDay{Weekday: weekday}
+	if prefix := value[:len(value)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return RRuleDay{}, fmt.Errorf("invalid recurrence day %q", value)
+		}
+		day.Nth = n
+	}
+	return day, nil
+}
+
+// Occurrence returns the nth (1-based) occurrence of the rule starting at
+// dtstart, which is always the first occurrence. It returns false once the
+// rule ends because of COUNT or UNTIL.
+func (r *RRule) Occurrence(dtstart time.Time, n int) (time.Time, bool) {
+	if n < 1 {
+		return time.Time{}, false
+	}
+	t, _, ok := r.walk(dtstart, func(_ time.Time, i int) bool { return i == n })
+	return t, ok
+}
+
+// After returns the first occurrence of the rule starting at dtstart that
+// comes after t, along with its number. It returns false when the rule ends
+// before.
+func (r *RRule) After(dtstart, t time.Time) (time.Time, int, bool) {
+	return r.walk(dtstart, func(occurrence time.Time, _ int) bool { return occurrence.After(t) })
+}
+
+// walk goes through the occurrences of the rule in order, numbered from 1,
+// and returns the first one match accepts.
+func (r *RRule) walk(dtstart time.Time, match func(t time.Time, n int) bool) (time.Time, int, bool) {
+	if match(dtstart, 1) {
+		return dtstart, 1, true
+	}
+
+	until := r.until(dtstart)
+	n := 1
+	for period, idle := 0, 0; idle < maxRRulePeriods; period, idle = period+1, idle+1 {
+		for _, t := range r.periodTimes(dtstart, period) {
+			if !t.After(dtstart) {
+				continue
+			}
+			if !until.IsZero() && t.After(until) {
+				return time.Time{}, 0, false
+			}
+			n++
+			if r.Count > 0 && n > r.Count {
+				return time.Time{}, 0, false
+			}
+			idle = 0
+			if match(t, n) {
+				return t, n, true
+			}
+		}
+	}
+	return time.Time{}, 0, false
+}
+
+// until returns when a series starting at dtstart ends, zero when it goes on.
+func (r *RRule) until(dtstart time.Time) time.Time {
+	if r.Until.IsZero() || !r.UntilLocal {
+		return r.Until
+	}
+	u := r.Until
+	return time.Date(u.Year(), u.Month(), u.Day(), u.Hour(), u.Minute(), u.Second(), 0, dtstart.Location())
+}
+
+// periodTimes lists, in order, the candidate times of the given period
+// (day, week or month) counted from dtstart.
+func (r *RRule) periodTimes(dtstart time.Time, period int) []time.Time {
+	step := period * r.Interval
+	hour, min, sec := dtstart.Clock()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, 0, dtstart.Location())
+	}
+
+	var times []time.Time
+	switch r.Freq {
+	case FreqDaily:
+		t := dtstart.AddDate(0, 0, step)
+		if len(r.ByDay) == 0 || r.hasWeekday(t.Weekday()) {
+			times = append(times, t)
+		}
+
+	case FreqWeekly:
+		// Weeks start on Monday
+		offset := (int(dtstart.Weekday()) + 6) % 7
+		monday := dtstart.AddDate(0, 0, -offset+7*step)
+		for i := 0; i < 7; i++ {
+			t := monday.AddDate(0, 0, i)
+			if (len(r.ByDay) == 0 && t.Weekday() == dtstart.Weekday()) || r.hasWeekday(t.Weekday()) {
+				times = append(times, t)
+			}
+		}
+
+	case FreqMonthly:
+		first := at(dtstart.Year(), dtstart.Month()+time.Month(step), 1)
+		year, month := first.Year(), first.Month()
+		daysInMonth := first.AddDate(0, 1, -1).Day()
+
+		if len(r.ByDay) == 0 {
+			// Months without that day are skipped, as in RFC 5545
+			if dtstart.Day() <= daysInMonth {
+				times = append(times, at(year, month, dtstart.Day()))
+			}
+			break
+		}
+
+		for _, d := range r.ByDay {
+			var matches []time.Time
+			for day := 1; day <= daysInMonth; day++ {
+				t := at(year, month, day)
+				if t.Weekday() == d.Weekday {
+					matches = append(matches, t)
+				}
+			}
+			switch {
+			case d.Nth == 0:
+				times = append(times, matches...)
+			case d.Nth > 0 && d.Nth <= len(matches):
+				times = append(times, matches[d.Nth-1])
+			case d.Nth < 0 && -d.Nth <= len(matches):
+				times = append(times, matches[len(matches)+d.Nth])
+			}
+		}
+		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	}
+	return times
+}
+
+func (r *RRule) hasWeekday(weekday time.Weekday) bool {
+	for _, d := range r.ByDay {
+		if d.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,320 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRRule(t *testing.T) {
+	tests := []struct {
+		value string
+		want  RRule
+	}{
+		{
+			value: "FREQ=DAILY",
+			want:  RRule{Freq: FreqDaily, Interval: 1},
+		},
+		{
+			value: "RRULE:freq=weekly;interval=2;byday=mo,we",
+			want: RRule{Freq: FreqWeekly, Interval: 2, ByDay: []RRuleDay{
+				{Weekday: time.Monday},
+				{Weekday: time.Wednesday},
+			}},
+		},
+		{
+			value: "FREQ=MONTHLY;BYDAY=1MO,-1FR;COUNT=6",
+			want: RRule{Freq: FreqMonthly, Interval: 1, Count: 6, ByDay: []RRuleDay{
+				{Weekday: time.Monday, Nth: 1},
+				{Weekday: time.Friday, Nth: -1},
+			}},
+		},
+		{
+			value: "FREQ=DAILY;UNTIL=20240105T120000Z",
+			want:  RRule{Freq: FreqDaily, Interval: 1, Until: time.Date(2024, 1, 5, 12, 0, 0, 0, time.UTC)},
+		},
+		{
+			value: "FREQ=DAILY;UNTIL=20240105T120000",
+			want:  RRule{Freq: FreqDaily, Interval: 1, Until: time.Date(2024, 1, 5, 12, 0, 0, 0, time.UTC), UntilLocal: true},
+		},
+		{
+			value: "FREQ=DAILY;UNTIL=20240105",
+			want:  RRule{Freq: FreqDaily, Interval: 1, Until: time.Date(2024, 1, 5, 23, 59, 59, 0, time.UTC), UntilLocal: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			rule, err := ParseRRule(tt.value)
+			if err != nil {
+				t.Fatalf("ParseRRule() error = %v", err)
+			}
+			if !reflect.DeepEqual(*rule, tt.want) {
+				t.Errorf("ParseRRule() = %+v, want %+v", *rule, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRRuleInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"RRULE:",
+		"FREQ",
+		"FREQ=",
+		"INTERVAL=2",
+		"FREQ=YEARLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;INTERVAL=x",
+		"FREQ=DAILY;COUNT=-1",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=DAILY;COUNT=2;UNTIL=20240101",
+		"FREQ=DAILY;BYMONTH=1",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=M",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=0MO",
+		"FREQ=MONTHLY;BYDAY=6MO",
+	}
+
+	for _, value := range tests {
+		t.Run(value, func(t *testing.T) {
+			if rule, err := ParseRRule(value); err == nil {
+				t.Errorf("ParseRRule() = %+v, want an error", *rule)
+			}
+		})
+	}
+}
+
+func TestRRuleOccurrence(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart string
+		want    []string // the first occurrences, at the time of dtstart
+		ends    bool     // no occurrence follows want
+	}{
+		{
+			name:    "daily",
+			rule:    "FREQ=DAILY",
+			dtstart: "2024-02-27",
+			want:    []string{"2024-02-27", "2024-02-28", "2024-02-29", "2024-03-01"},
+		},
+		{
+			name:    "daily interval",
+			rule:    "FREQ=DAILY;INTERVAL=2",
+			dtstart: "2024-01-30",
+			want:    []string{"2024-01-30", "2024-02-01", "2024-02-03", "2024-02-05"},
+		},
+		{
+			name:    "daily by day",
+			rule:    "FREQ=DAILY;BYDAY=MO,FR",
+			dtstart: "2024-01-01",
+			want:    []string{"2024-01-01", "2024-01-05", "2024-01-08", "2024-01-12"},
+		},
+		{
+			name:    "weekly",
+			rule:    "FREQ=WEEKLY",
+			dtstart: "2024-01-03",
+			want:    []string{"2024-01-03", "2024-01-10", "2024-01-17", "2024-01-24"},
+		},
+		{
+			name:    "weekly interval by day",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH",
+			dtstart: "2024-01-04",
+			want:    []string{"2024-01-04", "2024-01-16", "2024-01-18", "2024-01-30", "2024-02-01"},
+		},
+		{
+			name:    "weekly by day before dtstart",
+			rule:    "FREQ=WEEKLY;BYDAY=MO,SU",
+			dtstart: "2024-01-03",
+			want:    []string{"2024-01-03", "2024-01-07", "2024-01-08", "2024-01-14"},
+		},
+		{
+			name:    "monthly",
+			rule:    "FREQ=MONTHLY",
+			dtstart: "2024-01-15",
+			want:    []string{"2024-01-15", "2024-02-15", "2024-03-15"},
+		},
+		{
+			name:    "monthly skips short months",
+			rule:    "FREQ=MONTHLY",
+			dtstart: "2024-01-31",
+			want:    []string{"2024-01-31", "2024-03-31", "2024-05-31", "2024-07-31"},
+		},
+		{
+			name:    "monthly interval",
+			rule:    "FREQ=MONTHLY;INTERVAL=3",
+			dtstart: "2023-11-15",
+			want:    []string{"2023-11-15", "2024-02-15", "2024-05-15"},
+		},
+		{
+			name:    "monthly last friday",
+			rule:    "FREQ=MONTHLY;BYDAY=-1FR",
+			dtstart: "2024-01-26",
+			want:    []string{"2024-01-26", "2024-02-23", "2024-03-29", "2024-04-26"},
+		},
+		{
+			name:    "monthly fifth monday",
+			rule:    "FREQ=MONTHLY;BYDAY=5MO",
+			dtstart: "2024-01-29",
+			want:    []string{"2024-01-29", "2024-04-29", "2024-07-29"},
+		},
+		{
+			name:    "monthly first monday and last sunday",
+			rule:    "FREQ=MONTHLY;BYDAY=1MO,-1SU",
+			dtstart: "2024-03-04",
+			want:    []string{"2024-03-04", "2024-03-31", "2024-04-01", "2024-04-28"},
+		},
+		{
+			name:    "monthly every tuesday",
+			rule:    "FREQ=MONTHLY;INTERVAL=2;BYDAY=TU",
+			dtstart: "2024-01-23",
+			want:    []string{"2024-01-23", "2024-01-30", "2024-03-05", "2024-03-12"},
+		},
+		{
+			name:    "count",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: "2024-01-01",
+			want:    []string{"2024-01-01", "2024-01-02", "2024-01-03"},
+			ends:    true,
+		},
+		{
+			name:    "count by day",
+			rule:    "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=3",
+			dtstart: "2024-01-01",
+			want:    []string{"2024-01-01", "2024-01-03", "2024-01-08"},
+			ends:    true,
+		},
+		{
+			name:    "until date",
+			rule:    "FREQ=WEEKLY;UNTIL=20240117",
+			dtstart: "2024-01-03",
+			want:    []string{"2024-01-03", "2024-01-10", "2024-01-17"},
+			ends:    true,
+		},
+		{
+			name:    "until time",
+			rule:    "FREQ=DAILY;UNTIL=20240103T080000Z",
+			dtstart: "2024-01-01",
+			want:    []string{"2024-01-01", "2024-01-02"},
+			ends:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRRule() error = %v", err)
+			}
+			dtstart := occurrenceTime(t, tt.dtstart)
+
+			for i, date := range tt.want {
+				got, ok := rule.Occurrence(dtstart, i+1)
+				if want := occurrenceTime(t, date); !ok || !got.Equal(want) {
+					t.Errorf("Occurrence(%d) = %v, %v, want %v", i+1, got, ok, want)
+				}
+			}
+			if got, ok := rule.Occurrence(dtstart, len(tt.want)+1); ok == tt.ends {
+				t.Errorf("Occurrence(%d) = %v, %v, want ok %v", len(tt.want)+1, got, ok, !tt.ends)
+			}
+		})
+	}
+}
+
+func TestRRuleOccurrenceOutOfRange(t *testing.T) {
+	rule, err := ParseRRule("FREQ=DAILY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := rule.Occurrence(time.Now(), 0); ok {
+		t.Errorf("Occurrence(0) = %v, want none", got)
+	}
+}
+
+func TestRRuleOccurrenceKeepsLocalTime(t *testing.T) {
+	zone, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip(err)
+	}
+	rule, err := ParseRRule("FREQ=DAILY")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Daylight saving time starts on March 31 2024 in Paris
+	dtstart := time.Date(2024, 3, 30, 9, 0, 0, 0, zone)
+	got, _ := rule.Occurrence(dtstart, 2)
+	if want := time.Date(2024, 3, 31, 9, 0, 0, 0, zone); !got.Equal(want) {
+		t.Errorf("Occurrence(2) = %v, want %v", got, want)
+	}
+}
+
+func TestRRuleUntilDateInZone(t *testing.T) {
+	zone, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	rule, err := ParseRRule("FREQ=DAILY;UNTIL=20240103")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 21:00 in New York is already the next day in UTC
+	dtstart := time.Date(2024, 1, 1, 21, 0, 0, 0, zone)
+	if got, ok := rule.Occurrence(dtstart, 3); !ok || !got.Equal(time.Date(2024, 1, 3, 21, 0, 0, 0, zone)) {
+		t.Errorf("Occurrence(3) = %v, %v, want January 3 at 21:00", got, ok)
+	}
+	if got, ok := rule.Occurrence(dtstart, 4); ok {
+		t.Errorf("Occurrence(4) = %v, want none", got)
+	}
+}
+
+func TestRRuleAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		after string
+		want  string
+		n     int
+		ends  bool
+	}{
+		{name: "before the start", rule: "FREQ=DAILY", after: "2023-12-31", want: "2024-01-01", n: 1},
+		{name: "at an occurrence", rule: "FREQ=DAILY", after: "2024-01-03", want: "2024-01-04", n: 4},
+		{name: "by day", rule: "FREQ=WEEKLY;BYDAY=MO,FR", after: "2024-01-09", want: "2024-01-12", n: 4},
+		{name: "decades later", rule: "FREQ=DAILY", after: "2060-01-01", want: "2060-01-02", n: 13151},
+		{name: "past the count", rule: "FREQ=DAILY;COUNT=3", after: "2024-01-03", ends: true},
+		{name: "past until", rule: "FREQ=DAILY;UNTIL=20240105", after: "2024-01-05", ends: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRRule() error = %v", err)
+			}
+
+			got, n, ok := rule.After(occurrenceTime(t, "2024-01-01"), occurrenceTime(t, tt.after))
+			if tt.ends {
+				if ok {
+					t.Errorf("After() = %v, %d, want none", got, n)
+				}
+				return
+			}
+			if want := occurrenceTime(t, tt.want); !ok || !got.Equal(want) || n != tt.n {
+				t.Errorf("After() = %v, %d, %v, want %v, %d", got, n, ok, want, tt.n)
+			}
+		})
+	}
+}
+
+// occurrenceTime returns 9:00 UTC on a date.
+func occurrenceTime(t *testing.T, date string) time.Time {
+	t.Helper()
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return day.Add(9 * time.Hour)
+}